@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// collectionSpec defines a collection to be generated for a struct type
+type collectionSpec struct {
+	TypeName   string // name of a struct type that holds data of records, e.g. "User"
+	VarName    string // name of a variable that holds the collection, e.g. "Users"
+	Collection string // name of the collection in DB, e.g. "users"
+	IDKind     string // name of a reflect.Kind constant for IDs of records, e.g. "String"
+	Fields     []fieldSpec
+}
+
+// fieldSpec defines a field of a collection
+type fieldSpec struct {
+	GoName   string // name of a struct field, e.g. "Email"
+	Name     string // name of a field in DB, e.g. "email"
+	Type     string // Go type of field values, e.g. "string"
+	Required bool
+	Default  string // Go literal of a default value, empty if there is no default value
+}
+
+// idKinds maps values of the -id flag to reflect.Kind constants
+var idKinds = map[string]string{
+	"string": "String",
+	"int":    "Int",
+	"int32":  "Int32",
+	"int64":  "Int64",
+	"uint":   "Uint",
+	"uint32": "Uint32",
+	"uint64": "Uint64",
+}
+
+var basicTypes = map[string]bool{
+	"bool": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true, "rune": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true, "byte": true,
+	"float32": true, "float64": true,
+}
+
+// sourcePackage holds parsed Go files of a package with struct types to generate collections for
+type sourcePackage struct {
+	fset      *token.FileSet
+	name      string
+	types     map[string]*ast.TypeSpec
+	typeFiles map[string]*ast.File
+	imports   map[string]string // package name => import path of packages referenced by types of fields
+}
+
+// parseSourcePackage parses non-test Go files of a directory, skipping a file with generated code
+func parseSourcePackage(dir, skipFile string) (*sourcePackage, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	p := &sourcePackage{
+		fset:      token.NewFileSet(),
+		types:     make(map[string]*ast.TypeSpec),
+		typeFiles: make(map[string]*ast.File),
+		imports:   make(map[string]string),
+	}
+	for _, fileName := range fileNames {
+		if strings.HasSuffix(fileName, "_test.go") || filepath.Base(fileName) == skipFile {
+			continue
+		}
+		file, err := parser.ParseFile(p.fset, fileName, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if p.name == "" {
+			p.name = file.Name.Name
+		} else if p.name != file.Name.Name {
+			return nil, fmt.Errorf("found packages %s and %s in %s", p.name, file.Name.Name, dir)
+		}
+		for _, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					p.types[typeSpec.Name.Name] = typeSpec
+					p.typeFiles[typeSpec.Name.Name] = file
+				}
+			}
+		}
+	}
+	if p.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// collectFields returns fields of a struct type defined by `dalgo` tags, e.g. `dalgo:"email,required,default=n/a"`
+func (p *sourcePackage) collectFields(typeName string) ([]fieldSpec, error) {
+	fields, err := p.structFields(typeName, 0)
+	if err != nil {
+		return nil, err
+	}
+	goNames := make(map[string]bool, len(fields))
+	names := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if f.GoName == "Fields" {
+			return nil, fmt.Errorf("%s.%s: field name conflicts with the generated Fields() method", typeName, f.GoName)
+		}
+		if goNames[f.GoName] {
+			return nil, fmt.Errorf("%s: duplicate field %s", typeName, f.GoName)
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("%s: duplicate field name %q", typeName, f.Name)
+		}
+		goNames[f.GoName], names[f.Name] = true, true
+	}
+	return fields, nil
+}
+
+func (p *sourcePackage) structFields(typeName string, depth int) (fields []fieldSpec, err error) {
+	typeSpec, ok := p.types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("type %s is not found in package %s", typeName, p.name)
+	}
+	if typeSpec.TypeParams != nil {
+		return nil, fmt.Errorf("generic type %s is not supported", typeName)
+	}
+	structType, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct type", typeName)
+	}
+	if depth > 10 {
+		return nil, fmt.Errorf("too deep embedding of struct %s", typeName)
+	}
+	file := p.typeFiles[typeName]
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid tag %s: %w", typeName, field.Tag.Value, err)
+			}
+			tag = reflect.StructTag(s)
+		}
+		name, options := parseDalgoTag(tag)
+		if name == "-" {
+			continue
+		}
+		if len(field.Names) == 0 { // embedded struct
+			embedded := field.Type
+			if star, ok := embedded.(*ast.StarExpr); ok {
+				embedded = star.X
+			}
+			ident, ok := embedded.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("%s: embedded field of type %s is not supported", typeName, p.typeString(field.Type))
+			}
+			if !ast.IsExported(ident.Name) {
+				continue
+			}
+			embeddedFields, err := p.structFields(ident.Name, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", typeName, err)
+			}
+			fields = append(fields, embeddedFields...)
+			continue
+		}
+		for _, fieldName := range field.Names {
+			if !fieldName.IsExported() {
+				continue
+			}
+			f := fieldSpec{GoName: fieldName.Name, Name: name, Type: p.typeString(field.Type)}
+			if f.Name == "" {
+				f.Name = f.GoName
+			}
+			for _, option := range options {
+				if option == "required" {
+					f.Required = true
+				} else if value, ok := strings.CutPrefix(option, "default="); ok {
+					if f.Default, err = p.defaultLiteral(field.Type, value); err != nil {
+						return nil, fmt.Errorf("%s.%s: %w", typeName, f.GoName, err)
+					}
+				}
+			}
+			if err = p.addImports(file, field.Type); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", typeName, f.GoName, err)
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// parseDalgoTag splits a `dalgo` tag into a name & options the same way as the dal package does
+func parseDalgoTag(tag reflect.StructTag) (name string, options []string) {
+	s, ok := tag.Lookup("dalgo")
+	if !ok {
+		return "", nil
+	}
+	parts := strings.Split(s, ",")
+	return parts[0], parts[1:]
+}
+
+func (p *sourcePackage) typeString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, p.fset, expr); err != nil {
+		panic(err) // expressions of a parsed file are always printable
+	}
+	return buf.String()
+}
+
+// basicType returns a predeclared type underlying a type expression or an empty string
+func (p *sourcePackage) basicType(expr ast.Expr, depth int) string {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || depth > 10 {
+		return ""
+	}
+	if typeSpec, ok := p.types[ident.Name]; ok {
+		return p.basicType(typeSpec.Type, depth+1)
+	}
+	if basicTypes[ident.Name] {
+		return ident.Name
+	}
+	return ""
+}
+
+// defaultLiteral converts a default value from a tag to a Go literal, only types with underlying basic types are supported
+func (p *sourcePackage) defaultLiteral(expr ast.Expr, value string) (string, error) {
+	basicType := p.basicType(expr, 0)
+	var err error
+	switch {
+	case basicType == "":
+		return "", fmt.Errorf("default value is not supported for type %s", p.typeString(expr))
+	case basicType == "string":
+		return strconv.Quote(value), nil
+	case basicType == "bool":
+		_, err = strconv.ParseBool(value)
+	case strings.HasPrefix(basicType, "float"):
+		_, err = strconv.ParseFloat(value, 64)
+	case strings.HasPrefix(basicType, "uint") || basicType == "byte":
+		_, err = strconv.ParseUint(value, 0, 64)
+	default:
+		_, err = strconv.ParseInt(value, 0, 64)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid default value %q for type %s: %w", value, p.typeString(expr), err)
+	}
+	return value, nil
+}
+
+// addImports registers imports of packages referenced by a type expression
+func (p *sourcePackage) addImports(file *ast.File, expr ast.Expr) (err error) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		pkg, ok := selector.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if importName(spec) == pkg.Name {
+				p.imports[pkg.Name] = path
+				return false
+			}
+		}
+		err = fmt.Errorf("import of package %s is not found", pkg.Name)
+		return false
+	})
+	return err
+}
+
+var versionSuffix = regexp.MustCompile(`^v\d+$`)
+
+// importName returns a name of an imported package, guessed from its path if the import is not named
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	path, _ := strconv.Unquote(spec.Path.Value)
+	elements := strings.Split(path, "/")
+	name := elements[len(elements)-1]
+	if versionSuffix.MatchString(name) && len(elements) > 1 {
+		name = elements[len(elements)-2]
+	}
+	name, _, _ = strings.Cut(name, ".")
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+type importSpec struct {
+	Name string // empty if matches the last element of the path
+	Path string
+}
+
+// generate renders Go code of collections
+func (p *sourcePackage) generate(collections []collectionSpec) ([]byte, error) {
+	stdImports := []importSpec{{Path: "reflect"}}
+	otherImports := []importSpec{{Path: "github.com/dal-go/dalgo/dal"}, {Path: "github.com/dal-go/dalgo/orm"}}
+	for name, path := range p.imports {
+		spec := importSpec{Path: path}
+		if name != path[strings.LastIndex(path, "/")+1:] {
+			spec.Name = name
+		}
+		if !strings.Contains(strings.Split(path, "/")[0], ".") {
+			if path != "reflect" {
+				stdImports = append(stdImports, spec)
+			}
+		} else if path != "github.com/dal-go/dalgo/dal" && path != "github.com/dal-go/dalgo/orm" {
+			otherImports = append(otherImports, spec)
+		}
+	}
+	for _, imports := range [][]importSpec{stdImports, otherImports} {
+		sort.Slice(imports, func(i, j int) bool {
+			return imports[i].Path < imports[j].Path
+		})
+	}
+	var buf bytes.Buffer
+	err := codeTemplate.Execute(&buf, struct {
+		Package      string
+		StdImports   []importSpec
+		OtherImports []importSpec
+		Collections  []collectionSpec
+	}{p.name, stdImports, otherImports, collections})
+	if err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w\n%s", err, buf.String())
+	}
+	return code, nil
+}
+
+var codeTemplate = template.Must(template.New("code").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(
+	`// Code generated by dalgo-gen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .StdImports}}
+	{{with .Name}}{{.}} {{end}}{{quote .Path}}
+{{- end}}
+{{range .OtherImports}}
+	{{with .Name}}{{.}} {{end}}{{quote .Path}}
+{{- end}}
+)
+{{range .Collections}}
+// {{.TypeName}}Fields defines fields of {{.TypeName}} records
+type {{.TypeName}}Fields struct {
+{{- range .Fields}}
+	{{.GoName}} orm.FieldDefinition[{{.Type}}]
+{{- end}}
+}
+
+// Fields returns all fields of {{.TypeName}} records
+func (v {{.TypeName}}Fields) Fields() []orm.Field {
+	return []orm.Field{
+{{- range .Fields}}
+		v.{{.GoName}},
+{{- end}}
+	}
+}
+
+var _ orm.Collection = (*{{.TypeName}}Collection)(nil)
+
+// {{.TypeName}}Collection defines the {{quote .Collection}} collection of {{.TypeName}} records
+type {{.TypeName}}Collection struct {
+	Field {{.TypeName}}Fields
+}
+
+func (v {{.TypeName}}Collection) Fields() []orm.Field {
+	return v.Field.Fields()
+}
+
+func (v {{.TypeName}}Collection) CollectionRef() dal.CollectionRef {
+	return dal.CollectionRef{Name: {{quote .Collection}}}
+}
+
+func (v {{.TypeName}}Collection) Query() dal.QueryBuilder {
+	return dal.From(v.CollectionRef().Name)
+}
+
+func (v {{.TypeName}}Collection) IDKind() reflect.Kind {
+	return reflect.{{.IDKind}}
+}
+
+// Update validates updates of a {{.TypeName}} record, e.g. that required fields are not deleted
+func (v {{.TypeName}}Collection) Update(key *dal.Key, updates ...dal.Update) (orm.RecordUpdate, error) {
+	return orm.NewRecordUpdate(v, key, updates...)
+}
+
+// {{.VarName}} is used to query {{.TypeName}} records
+var {{.VarName}} = {{.TypeName}}Collection{
+	Field: {{.TypeName}}Fields{
+{{- range .Fields}}
+		{{.GoName}}: orm.NewField[{{.Type}}]({{quote .Name}}
+			{{- if .Required}}, orm.Required[{{.Type}}](){{end}}
+			{{- if .Default}}, orm.Default[{{.Type}}]({{.Default}}){{end}}),
+{{- end}}
+	},
+}
+{{end}}`))
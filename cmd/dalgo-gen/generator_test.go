@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_Example(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "user_dalgo.go")
+	err := run([]string{"-type=User", "-collection=users", "-dir=internal/example", "-output=" + output}, &bytes.Buffer{})
+	assert.Nil(t, err)
+	generated, err := os.ReadFile(output)
+	assert.Nil(t, err)
+	expected, err := os.ReadFile("internal/example/user_dalgo.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(generated), "internal/example/user_dalgo.go should be regenerated by `go generate`")
+}
+
+func TestParseFlags(t *testing.T) {
+	cfg, err := parseFlags([]string{"-type=User, Order", "-id=int64", "-dir=models"}, &bytes.Buffer{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"User", "Order"}, cfg.types)
+	assert.Equal(t, "int64", cfg.idKind)
+	assert.Equal(t, filepath.Join("models", "user_dalgo.go"), cfg.output)
+
+	for _, args := range [][]string{
+		{},
+		{"-type=User,Order", "-collection=users"},
+		{"-type=User,Order", "-var=Users"},
+		{"-type=User", "-id=float64"},
+		{"-unknown"},
+	} {
+		_, err = parseFlags(args, &bytes.Buffer{})
+		assert.Error(t, err, args)
+	}
+}
+
+func parseTestSource(t *testing.T, source string) *sourcePackage {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "models.go"), []byte(source), 0o644))
+	pkg, err := parseSourcePackage(dir, "")
+	assert.Nil(t, err)
+	return pkg
+}
+
+func TestCollectFields(t *testing.T) {
+	pkg := parseTestSource(t, `package models
+
+import (
+	geo "example.com/geo/v2"
+	"time"
+)
+
+type Kind int
+
+type Base struct {
+	ID string
+}
+
+type Item struct {
+	*Base
+	Title    string        `+"`"+`dalgo:"title,required,min=3,default=untitled"`+"`"+`
+	Kind     Kind          `+"`"+`dalgo:"kind,default=0x10"`+"`"+`
+	Price    float64       `+"`"+`dalgo:"price,default=1.5"`+"`"+`
+	Active   bool          `+"`"+`dalgo:",default=true"`+"`"+`
+	Location *geo.Point    `+"`"+`dalgo:"location"`+"`"+`
+	TTL      time.Duration `+"`"+`dalgo:"ttl"`+"`"+`
+	A, B     uint8
+}
+
+type NotStruct string
+
+type InvalidDefault struct {
+	Count int `+"`"+`dalgo:"count,default=many"`+"`"+`
+}
+
+type UnsupportedDefault struct {
+	Tags []string `+"`"+`dalgo:"tags,default=a"`+"`"+`
+}
+
+type DuplicateName struct {
+	A string `+"`"+`dalgo:"a"`+"`"+`
+	B string `+"`"+`dalgo:"a"`+"`"+`
+}
+
+type ConflictingName struct {
+	Fields []string
+}
+
+type Generic[T any] struct {
+	Value T
+}
+`)
+	fields, err := pkg.collectFields("Item")
+	assert.Nil(t, err)
+	assert.Equal(t, []fieldSpec{
+		{GoName: "ID", Name: "ID", Type: "string"},
+		{GoName: "Title", Name: "title", Type: "string", Required: true, Default: `"untitled"`},
+		{GoName: "Kind", Name: "kind", Type: "Kind", Default: "0x10"},
+		{GoName: "Price", Name: "price", Type: "float64", Default: "1.5"},
+		{GoName: "Active", Name: "Active", Type: "bool", Default: "true"},
+		{GoName: "Location", Name: "location", Type: "*geo.Point"},
+		{GoName: "TTL", Name: "ttl", Type: "time.Duration"},
+		{GoName: "A", Name: "A", Type: "uint8"},
+		{GoName: "B", Name: "B", Type: "uint8"},
+	}, fields)
+	assert.Equal(t, map[string]string{"geo": "example.com/geo/v2", "time": "time"}, pkg.imports)
+
+	code, err := pkg.generate([]collectionSpec{{TypeName: "Item", VarName: "Items", Collection: "items", IDKind: "String", Fields: fields}})
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), "\"time\"\n\n\tgeo \"example.com/geo/v2\"")
+	assert.Contains(t, string(code), `Title:    orm.NewField[string]("title", orm.Required[string](), orm.Default[string]("untitled")),`)
+
+	for _, typeName := range []string{"Unknown", "NotStruct", "InvalidDefault", "UnsupportedDefault", "DuplicateName", "ConflictingName", "Generic"} {
+		_, err = pkg.collectFields(typeName)
+		assert.Error(t, err, typeName)
+	}
+}
+
+func TestImportName(t *testing.T) {
+	for path, expected := range map[string]string{
+		`"time"`:                    "time",
+		`"github.com/dal-go/dalgo"`: "dalgo",
+		`"gopkg.in/yaml.v3"`:        "yaml",
+		`"example.com/geo/v2"`:      "geo",
+		`"github.com/a/go-redis"`:   "redis",
+	} {
+		assert.Equal(t, expected, importName(&ast.ImportSpec{Path: &ast.BasicLit{Value: path}}), path)
+	}
+	assert.Equal(t, "alias", importName(&ast.ImportSpec{Name: ast.NewIdent("alias"), Path: &ast.BasicLit{Value: `"time"`}}))
+}
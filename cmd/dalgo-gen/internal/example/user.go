@@ -0,0 +1,29 @@
+// Package example demonstrates code generated by dalgo-gen
+package example
+
+import (
+	"time"
+)
+
+//go:generate go run github.com/dal-go/dalgo/cmd/dalgo-gen -type=User -collection=users
+
+// Status is a status of a user
+type Status string
+
+// Timestamps are common fields of records
+type Timestamps struct {
+	CreatedAt time.Time `dalgo:"createdAt,required"`
+	UpdatedAt time.Time `dalgo:"updatedAt"`
+}
+
+// User is data of a user record
+type User struct {
+	Timestamps
+	Email   string   `dalgo:"email,required"`
+	Name    string   `dalgo:"name"`
+	Status  Status   `dalgo:"status,required,default=active"`
+	Age     int      `dalgo:"age,default=18"`
+	Tags    []string `dalgo:"tags"`
+	Secret  string   `dalgo:"-"`
+	comment string
+}
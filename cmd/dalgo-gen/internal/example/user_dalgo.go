@@ -0,0 +1,76 @@
+// Code generated by dalgo-gen; DO NOT EDIT.
+
+package example
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/dalgo/orm"
+)
+
+// UserFields defines fields of User records
+type UserFields struct {
+	CreatedAt orm.FieldDefinition[time.Time]
+	UpdatedAt orm.FieldDefinition[time.Time]
+	Email     orm.FieldDefinition[string]
+	Name      orm.FieldDefinition[string]
+	Status    orm.FieldDefinition[Status]
+	Age       orm.FieldDefinition[int]
+	Tags      orm.FieldDefinition[[]string]
+}
+
+// Fields returns all fields of User records
+func (v UserFields) Fields() []orm.Field {
+	return []orm.Field{
+		v.CreatedAt,
+		v.UpdatedAt,
+		v.Email,
+		v.Name,
+		v.Status,
+		v.Age,
+		v.Tags,
+	}
+}
+
+var _ orm.Collection = (*UserCollection)(nil)
+
+// UserCollection defines the "users" collection of User records
+type UserCollection struct {
+	Field UserFields
+}
+
+func (v UserCollection) Fields() []orm.Field {
+	return v.Field.Fields()
+}
+
+func (v UserCollection) CollectionRef() dal.CollectionRef {
+	return dal.CollectionRef{Name: "users"}
+}
+
+func (v UserCollection) Query() dal.QueryBuilder {
+	return dal.From(v.CollectionRef().Name)
+}
+
+func (v UserCollection) IDKind() reflect.Kind {
+	return reflect.String
+}
+
+// Update validates updates of a User record, e.g. that required fields are not deleted
+func (v UserCollection) Update(key *dal.Key, updates ...dal.Update) (orm.RecordUpdate, error) {
+	return orm.NewRecordUpdate(v, key, updates...)
+}
+
+// Users is used to query User records
+var Users = UserCollection{
+	Field: UserFields{
+		CreatedAt: orm.NewField[time.Time]("createdAt", orm.Required[time.Time]()),
+		UpdatedAt: orm.NewField[time.Time]("updatedAt"),
+		Email:     orm.NewField[string]("email", orm.Required[string]()),
+		Name:      orm.NewField[string]("name"),
+		Status:    orm.NewField[Status]("status", orm.Required[Status](), orm.Default[Status]("active")),
+		Age:       orm.NewField[int]("age", orm.Default[int](18)),
+		Tags:      orm.NewField[[]string]("tags"),
+	},
+}
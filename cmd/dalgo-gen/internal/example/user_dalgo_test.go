@@ -0,0 +1,36 @@
+package example
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/dalgo/orm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsers(t *testing.T) {
+	assert.Equal(t, "users", Users.CollectionRef().Name)
+	assert.Equal(t, reflect.String, Users.IDKind())
+	assert.Len(t, Users.Fields(), 7)
+	assert.True(t, Users.Field.Email.IsRequired())
+	assert.False(t, Users.Field.Name.IsRequired())
+	assert.Equal(t, Status("active"), Users.Field.Status.DefaultValue())
+	assert.Equal(t, 18, Users.Field.Age.DefaultValue())
+	assert.Equal(t, "time.Time", Users.Field.CreatedAt.Type())
+
+	query := Users.Query().
+		Where(Users.Field.Email.EqualTo("test@example.com")).
+		SelectKeysOnly(Users.IDKind())
+	assert.Equal(t, "SELECT * FROM [users] WHERE email = 'test@example.com'", query.String())
+}
+
+func TestUsers_Update(t *testing.T) {
+	key := dal.NewKeyWithID("users", "u1")
+	_, err := Users.Update(key, Users.Field.Email.Delete())
+	assert.ErrorIs(t, err, orm.ErrInvalidUpdate)
+	update, err := Users.Update(key, Users.Field.Name.Set("Ann"), orm.Increment(Users.Field.Age, 1), orm.ArrayUnion(Users.Field.Tags, "vip"))
+	assert.Nil(t, err)
+	assert.Len(t, update.Updates, 3)
+}
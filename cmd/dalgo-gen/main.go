@@ -0,0 +1,123 @@
+// Command dalgo-gen generates orm collections for Go structs with `dalgo` tags.
+//
+// It is intended to be used with `go generate`, for example:
+//
+//	//go:generate go run github.com/dal-go/dalgo/cmd/dalgo-gen -type=User -collection=users
+//	type User struct {
+//		Email string `dalgo:"email,required"`
+//		Role  string `dalgo:"role,default=user"`
+//	}
+//
+// For each type it generates:
+//   - a `<Type>Fields` struct with a typed orm.FieldDefinition for each exported field;
+//   - a `<Type>Collection` struct that implements orm.Collection & has Query(), IDKind() & Update() methods;
+//   - a variable with the collection, named by the -var flag.
+//
+// Names of fields are taken from `dalgo` tags, fields tagged by `dalgo:"-"` & unexported fields are skipped.
+// The `required` option of a tag marks a field as required & `default=<value>` sets its default value.
+// Fields of embedded structs defined in the same package are included.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "dalgo-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// config holds command line flags
+type config struct {
+	types      []string
+	collection string
+	varName    string
+	idKind     string
+	dir        string
+	output     string
+}
+
+func parseFlags(args []string, stderr io.Writer) (cfg config, err error) {
+	flags := flag.NewFlagSet("dalgo-gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	types := flags.String("type", "", "comma-separated list of struct type names, required")
+	flags.StringVar(&cfg.collection, "collection", "", "name of a collection in DB, defaults to the variable name; requires a single type")
+	flags.StringVar(&cfg.varName, "var", "", "name of a variable with a collection, defaults to the type name with an 's' suffix; requires a single type")
+	flags.StringVar(&cfg.idKind, "id", "string", "kind of record IDs: "+strings.Join(sortedKeys(idKinds), ", "))
+	flags.StringVar(&cfg.dir, "dir", ".", "directory of a package with the types")
+	flags.StringVar(&cfg.output, "output", "", "name of an output file, defaults to <type>_dalgo.go in the package directory")
+	if err = flags.Parse(args); err != nil {
+		return cfg, err
+	}
+	if *types == "" {
+		return cfg, errors.New("-type flag is required")
+	}
+	for _, t := range strings.Split(*types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			cfg.types = append(cfg.types, t)
+		}
+	}
+	if len(cfg.types) > 1 && (cfg.collection != "" || cfg.varName != "") {
+		return cfg, errors.New("-collection & -var flags can be used with a single type only")
+	}
+	if _, ok := idKinds[cfg.idKind]; !ok {
+		return cfg, fmt.Errorf("unsupported -id kind %q", cfg.idKind)
+	}
+	if cfg.output == "" {
+		cfg.output = filepath.Join(cfg.dir, strings.ToLower(cfg.types[0])+"_dalgo.go")
+	}
+	return cfg, nil
+}
+
+func run(args []string, stderr io.Writer) error {
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+	pkg, err := parseSourcePackage(cfg.dir, filepath.Base(cfg.output))
+	if err != nil {
+		return err
+	}
+	collections := make([]collectionSpec, len(cfg.types))
+	for i, typeName := range cfg.types {
+		c := collectionSpec{
+			TypeName:   typeName,
+			VarName:    cfg.varName,
+			Collection: cfg.collection,
+			IDKind:     idKinds[cfg.idKind],
+		}
+		if c.VarName == "" {
+			c.VarName = typeName + "s"
+		}
+		if c.Collection == "" {
+			c.Collection = c.VarName
+		}
+		if c.Fields, err = pkg.collectFields(typeName); err != nil {
+			return err
+		}
+		collections[i] = c
+	}
+	code, err := pkg.generate(collections)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.output, code, 0o644)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
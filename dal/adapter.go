@@ -10,16 +10,32 @@ type Adapter interface {
 	Version() string
 }
 
+// AdapterOption defines an option for NewAdapter
+type AdapterOption func(a *adapter)
+
+// WithBatchLimits declares limits of multi-record operations supported by an adapter
+func WithBatchLimits(limits BatchLimits) AdapterOption {
+	return func(a *adapter) {
+		a.batchLimits = limits
+	}
+}
+
 // NewAdapter creates new client info. Former ClientInfo.
-func NewAdapter(name, version string) Adapter {
-	return adapter{name: name, version: version}
+func NewAdapter(name, version string, options ...AdapterOption) Adapter {
+	a := adapter{name: name, version: version}
+	for _, o := range options {
+		o(&a)
+	}
+	return a
 }
 
 var _ Adapter = (*adapter)(nil)
+var _ BatchLimitsProvider = (*adapter)(nil)
 
 type adapter struct {
-	name    string
-	version string
+	name        string
+	version     string
+	batchLimits BatchLimits
 }
 
 func (v adapter) Equals(other Adapter) bool {
@@ -37,3 +53,7 @@ func (v adapter) Name() string {
 func (v adapter) Version() string {
 	return v.version
 }
+
+func (v adapter) BatchLimits() BatchLimits {
+	return v.batchLimits
+}
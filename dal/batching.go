@@ -1,16 +1,194 @@
 package dal
 
-//
-//func CreateEntityHoldersWithIntIDs(ids []int64, newRecordWithOnlyKey func() RecordWithIntID) (records []Record) {
-//	records = make([]Record, len(ids))
-//	for i := range records {
-//		record := newRecordWithOnlyKey()
-//		id := ids[i]
-//		if id == 0 {
-//			panic(fmt.Sprintf("ids[%v] == 0", i))
-//		}
-//		record.SetIntID(ids[i])
-//		records[i] = record
-//	}
-//	return
-//}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BatchLimits defines max number of records that can be passed to a single call of a multi-record operation.
+// A zero value means there is no limit for an operation.
+type BatchLimits struct {
+	GetMulti    int
+	SetMulti    int
+	DeleteMulti int
+	UpdateMulti int
+	InsertMulti int
+
+	// Concurrency is max number of batches processed in parallel outside of transactions.
+	// Values less than 2 mean batches are processed one by one.
+	// Inside transactions batches are always processed serially.
+	Concurrency int
+}
+
+// BatchLimitsProvider is implemented by adapters that have limits on size of multi-record operations.
+// For example Firestore allows up to 500 writes per a batch.
+type BatchLimitsProvider interface {
+	BatchLimits() BatchLimits
+}
+
+// GetBatchLimits returns batch limits declared by an adapter or zero limits if the adapter does not declare them
+func GetBatchLimits(adapter Adapter) BatchLimits {
+	if provider, ok := adapter.(BatchLimitsProvider); ok {
+		return provider.BatchLimits()
+	}
+	return BatchLimits{}
+}
+
+// RunInBatches splits items into batches of at most `size` items and calls `f` for each of them.
+// If concurrency is greater than 1 up to `concurrency` batches are processed in parallel.
+// All batches are processed even if some of them fail, errors are returned joined in order of batches.
+// If all failed batches return a *MultiError they are merged into a single *MultiError
+// with record indexes relative to the `items` slice.
+// A non-positive size means all items are passed to `f` in a single batch.
+func RunInBatches[T any](ctx context.Context, items []T, size, concurrency int, f func(ctx context.Context, batch []T) error) error {
+	if size <= 0 || len(items) <= size {
+		return f(ctx, items)
+	}
+	batchesCount := (len(items) + size - 1) / size
+	errs := make([]error, batchesCount)
+	batchErrs := make([]error, batchesCount)
+	run := func(i int) {
+		start := i * size
+		end := min(start+size, len(items))
+		if err := ctx.Err(); err != nil {
+			errs[i] = fmt.Errorf("batch #%d of items [%d:%d] is not processed: %w", i, start, end, err)
+			return
+		}
+		if err := f(ctx, items[start:end]); err != nil {
+			batchErrs[i] = err
+			errs[i] = fmt.Errorf("batch #%d of items [%d:%d] failed: %w", i, start, end, err)
+		}
+	}
+	if concurrency < 2 {
+		for i := 0; i < batchesCount; i++ {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, concurrency)
+		for i := 0; i < batchesCount; i++ {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(i int) {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+	if multiErr := mergeBatchMultiErrors(errs, batchErrs, size); multiErr != nil {
+		return multiErr
+	}
+	return errors.Join(errs...)
+}
+
+// mergeBatchMultiErrors returns a single MultiError if every failed batch returned a *MultiError
+func mergeBatchMultiErrors(errs, batchErrs []error, size int) error {
+	var merged MultiError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		var multiErr *MultiError
+		if !errors.As(batchErrs[i], &multiErr) {
+			return nil
+		}
+		for _, recordErr := range multiErr.Errors {
+			recordErr.Index += i * size
+			merged.Errors = append(merged.Errors, recordErr)
+		}
+	}
+	return merged.ErrorOrNil()
+}
+
+// NewBatchingDB wraps a DB so GetMulti calls and multi-record operations of transactions
+// are split into batches that do not exceed the given limits.
+// Use GetBatchLimits(db.Adapter()) to get limits declared by the adapter.
+func NewBatchingDB(db DB, limits BatchLimits) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	return batchingDB{DB: db, limits: limits}
+}
+
+type batchingDB struct {
+	DB
+	limits BatchLimits
+}
+
+func (v batchingDB) GetMulti(ctx context.Context, records []Record) error {
+	return RunInBatches(ctx, records, v.limits.GetMulti, v.limits.Concurrency, v.DB.GetMulti)
+}
+
+func (v batchingDB) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return f(ctx, NewBatchingReadTransaction(tx, v.limits))
+	}, options...)
+}
+
+func (v batchingDB) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return f(ctx, NewBatchingReadwriteTransaction(tx, v.limits))
+	}, options...)
+}
+
+// NewBatchingReadTransaction wraps a readonly transaction so GetMulti calls are split into batches.
+// Batches are processed serially.
+func NewBatchingReadTransaction(tx ReadTransaction, limits BatchLimits) ReadTransaction {
+	if tx == nil {
+		panic("tx is a required parameter, got nil")
+	}
+	return batchingReadTransaction{ReadTransaction: tx, limits: limits}
+}
+
+type batchingReadTransaction struct {
+	ReadTransaction
+	limits BatchLimits
+}
+
+func (v batchingReadTransaction) GetMulti(ctx context.Context, records []Record) error {
+	return RunInBatches(ctx, records, v.limits.GetMulti, 1, v.ReadTransaction.GetMulti)
+}
+
+// NewBatchingReadwriteTransaction wraps a readwrite transaction so multi-record operations are split into batches.
+// Batches are processed serially.
+func NewBatchingReadwriteTransaction(tx ReadwriteTransaction, limits BatchLimits) ReadwriteTransaction {
+	if tx == nil {
+		panic("tx is a required parameter, got nil")
+	}
+	return batchingReadwriteTransaction{ReadwriteTransaction: tx, limits: limits}
+}
+
+type batchingReadwriteTransaction struct {
+	ReadwriteTransaction
+	limits BatchLimits
+}
+
+func (v batchingReadwriteTransaction) GetMulti(ctx context.Context, records []Record) error {
+	return RunInBatches(ctx, records, v.limits.GetMulti, 1, v.ReadwriteTransaction.GetMulti)
+}
+
+func (v batchingReadwriteTransaction) SetMulti(ctx context.Context, records []Record) error {
+	return RunInBatches(ctx, records, v.limits.SetMulti, 1, v.ReadwriteTransaction.SetMulti)
+}
+
+func (v batchingReadwriteTransaction) DeleteMulti(ctx context.Context, keys []*Key) error {
+	return RunInBatches(ctx, keys, v.limits.DeleteMulti, 1, v.ReadwriteTransaction.DeleteMulti)
+}
+
+func (v batchingReadwriteTransaction) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) error {
+	return RunInBatches(ctx, keys, v.limits.UpdateMulti, 1, func(ctx context.Context, keys []*Key) error {
+		return v.ReadwriteTransaction.UpdateMulti(ctx, keys, updates, preconditions...)
+	})
+}
+
+func (v batchingReadwriteTransaction) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	return RunInBatches(ctx, records, v.limits.InsertMulti, 1, func(ctx context.Context, records []Record) error {
+		return v.ReadwriteTransaction.InsertMulti(ctx, records, opts...)
+	})
+}
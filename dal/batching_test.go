@@ -0,0 +1,172 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBatchLimits(t *testing.T) {
+	assert.Equal(t, BatchLimits{}, GetBatchLimits(NewAdapter("a", "v1")))
+	limits := BatchLimits{SetMulti: 500, Concurrency: 4}
+	assert.Equal(t, limits, GetBatchLimits(NewAdapter("a", "v1", WithBatchLimits(limits))))
+}
+
+func TestRunInBatches(t *testing.T) {
+	ctx := context.Background()
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+
+	t.Run("no_limit", func(t *testing.T) {
+		var batches [][]int
+		err := RunInBatches(ctx, items, 0, 1, func(ctx context.Context, batch []int) error {
+			batches = append(batches, batch)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, [][]int{items}, batches)
+	})
+
+	t.Run("serial", func(t *testing.T) {
+		var batches [][]int
+		err := RunInBatches(ctx, items, 3, 1, func(ctx context.Context, batch []int) error {
+			batches = append(batches, batch)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, batches)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var mutex sync.Mutex
+		var running, maxRunning int32
+		processed := make([]int, len(items))
+		err := RunInBatches(ctx, items, 2, 2, func(ctx context.Context, batch []int) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			mutex.Lock()
+			if n > maxRunning {
+				maxRunning = n
+			}
+			for _, item := range batch {
+				processed[item-1] = item * 10
+			}
+			mutex.Unlock()
+			if batch[0] == 3 || batch[0] == 7 {
+				return fmt.Errorf("failed at %d", batch[0])
+			}
+			return nil
+		})
+		assert.LessOrEqual(t, maxRunning, int32(2))
+		assert.Equal(t, []int{10, 20, 30, 40, 50, 60, 70}, processed)
+		assert.EqualError(t, err, "batch #1 of items [2:4] failed: failed at 3\nbatch #3 of items [6:7] failed: failed at 7")
+	})
+
+	t.Run("cancelled_context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := RunInBatches(ctx, items, 5, 1, func(ctx context.Context, batch []int) error {
+			calls++
+			cancel()
+			return nil
+		})
+		assert.Equal(t, 1, calls)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func TestNewBatchingDB(t *testing.T) {
+	ctx := context.Background()
+	assert.Panics(t, func() {
+		NewBatchingDB(nil, BatchLimits{})
+	})
+	newRecords := func(count int) []Record {
+		records := make([]Record, count)
+		for i := range records {
+			records[i] = NewRecordWithData(NewKeyWithID("c1", fmt.Sprintf("r%d", i)), &map[string]any{"i": i})
+		}
+		return records
+	}
+
+	t.Run("readwrite_transaction", func(t *testing.T) {
+		memDB := newMemoryDB()
+		db := NewBatchingDB(memDB, BatchLimits{GetMulti: 3, SetMulti: 2, DeleteMulti: 2, UpdateMulti: 4, InsertMulti: 3, Concurrency: 10})
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			records := newRecords(5)
+			if err := tx.InsertMulti(ctx, records); err != nil {
+				return err
+			}
+			if err := tx.SetMulti(ctx, records); err != nil {
+				return err
+			}
+			keys := make([]*Key, len(records))
+			for i, r := range records {
+				keys[i] = r.Key()
+			}
+			if err := tx.UpdateMulti(ctx, keys, []Update{{Field: "u", Value: 1}}); err != nil {
+				return err
+			}
+			if err := tx.GetMulti(ctx, newRecords(5)); err != nil {
+				return err
+			}
+			return tx.DeleteMulti(ctx, keys[:3])
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"InsertMulti:3", "InsertMulti:2",
+			"SetMulti:2", "SetMulti:2", "SetMulti:1",
+			"UpdateMulti:4", "UpdateMulti:1",
+			"GetMulti:3", "GetMulti:2",
+			"DeleteMulti:2", "DeleteMulti:1",
+		}, memDB.calls)
+		assert.Len(t, memDB.records, 2)
+		assert.Equal(t, map[string]any{"i": float64(4), "u": float64(1)}, memDB.getData(NewKeyWithID("c1", "r4")))
+	})
+
+	t.Run("get_multi_outside_transaction", func(t *testing.T) {
+		memDB := newMemoryDB()
+		for i := 0; i < 7; i++ {
+			memDB.putData(NewKeyWithID("c1", fmt.Sprintf("r%d", i)), map[string]any{"i": i})
+		}
+		db := NewBatchingDB(memDB, BatchLimits{GetMulti: 2, Concurrency: 3})
+		records := make([]Record, 7)
+		for i := range records {
+			records[i] = NewRecordWithData(NewKeyWithID("c1", fmt.Sprintf("r%d", i)), new(map[string]any))
+		}
+		assert.Nil(t, db.GetMulti(ctx, records))
+		assert.Len(t, memDB.calls, 4)
+		for i, r := range records {
+			assert.Equal(t, map[string]any{"i": float64(i)}, *r.Data().(*map[string]any))
+		}
+	})
+
+	t.Run("readonly_transaction", func(t *testing.T) {
+		memDB := newMemoryDB()
+		db := NewBatchingDB(memDB, BatchLimits{GetMulti: 4})
+		err := db.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+			return tx.GetMulti(ctx, newRecords(5))
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"GetMulti:4", "GetMulti:1"}, memDB.calls)
+	})
+}
+
+func TestRunInBatches_mergesMultiErrors(t *testing.T) {
+	keys := []*Key{NewKeyWithID("c1", "k0"), NewKeyWithID("c1", "k1"), NewKeyWithID("c1", "k2"), NewKeyWithID("c1", "k3")}
+	errFailed := errors.New("failed")
+	err := RunInBatches(context.Background(), keys, 2, 2, func(ctx context.Context, batch []*Key) error {
+		var multiErr MultiError
+		multiErr.Add(1, batch[1], errFailed)
+		return multiErr.ErrorOrNil()
+	})
+	var multiErr *MultiError
+	assert.True(t, errors.As(err, &multiErr))
+	assert.Equal(t, []RecordError{
+		{Index: 1, Key: keys[1], Err: errFailed},
+		{Index: 3, Key: keys[3], Err: errFailed},
+	}, multiErr.Errors)
+}
@@ -0,0 +1,70 @@
+package dal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChangeSinkFunc is a ChangeSink implemented by a callback
+type ChangeSinkFunc func(ctx context.Context, events []ChangeEvent) error
+
+// Publish implements ChangeSink
+func (f ChangeSinkFunc) Publish(ctx context.Context, events []ChangeEvent) error {
+	return f(ctx, events)
+}
+
+// NewChannelChangeSink creates a sink that sends events of a transaction to a channel as a single batch,
+// so a receiver gets either all events of a committed transaction or none of them.
+// Publishing blocks until the batch is received or the context is done.
+func NewChannelChangeSink(ch chan<- []ChangeEvent) ChangeSink {
+	if ch == nil {
+		panic("ch is a required parameter, got nil")
+	}
+	return channelChangeSink{ch: ch}
+}
+
+type channelChangeSink struct {
+	ch chan<- []ChangeEvent
+}
+
+func (s channelChangeSink) Publish(ctx context.Context, events []ChangeEvent) error {
+	select {
+	case s.ch <- append([]ChangeEvent(nil), events...):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d events have not been sent: %w", len(events), ctx.Err())
+	}
+}
+
+// NewJSONLChangeSink creates a sink that writes events as JSON lines (see ChangeEvent.MarshalJSON()).
+// Events of a transaction are written by a single Write call, so a file is never left with a part of a transaction
+// unless the writer itself fails in the middle of a write.
+func NewJSONLChangeSink(w io.Writer) ChangeSink {
+	if w == nil {
+		panic("w is a required parameter, got nil")
+	}
+	return &jsonlChangeSink{w: w}
+}
+
+type jsonlChangeSink struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (s *jsonlChangeSink) Publish(_ context.Context, events []ChangeEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to encode change event for key=%v: %w", event.Key, err)
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,58 @@
+package dal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChannelChangeSink(t *testing.T) {
+	assert.Panics(t, func() {
+		NewChannelChangeSink(nil)
+	})
+	events := []ChangeEvent{
+		{Op: ChangeOpSet, Key: NewKeyWithID("users", "u1")},
+		{Op: ChangeOpDelete, Key: NewKeyWithID("users", "u2")},
+	}
+
+	t.Run("received", func(t *testing.T) {
+		ch := make(chan []ChangeEvent, 1)
+		sink := NewChannelChangeSink(ch)
+		assert.Nil(t, sink.Publish(context.Background(), events))
+		assert.Equal(t, events, <-ch)
+	})
+
+	t.Run("context_done", func(t *testing.T) {
+		ch := make(chan []ChangeEvent) // nobody receives
+		sink := NewChannelChangeSink(ch)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := sink.Publish(ctx, events)
+		assert.ErrorIs(t, err, context.Canceled)
+		select {
+		case <-ch:
+			t.Fatal("no events should be sent")
+		default:
+		}
+	})
+}
+
+func TestNewJSONLChangeSink(t *testing.T) {
+	assert.Panics(t, func() {
+		NewJSONLChangeSink(nil)
+	})
+	var buf bytes.Buffer
+	sink := NewJSONLChangeSink(&buf)
+	err := sink.Publish(context.Background(), []ChangeEvent{
+		{Op: ChangeOpInsert, Key: NewKeyWithID("users", "u1"), After: map[string]any{"name": "Ann"}, TxID: "tx1"},
+		{Op: ChangeOpDelete, Key: NewKeyWithID("users", "u2"), TxID: "tx1"},
+	})
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"op":"insert","key":"users/u1","after":{"name":"Ann"},"txID":"tx1","timestamp":"0001-01-01T00:00:00Z"}`, lines[0])
+	assert.JSONEq(t, `{"op":"delete","key":"users/u2","txID":"tx1","timestamp":"0001-01-01T00:00:00Z"}`, lines[1])
+}
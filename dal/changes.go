@@ -1,39 +1,187 @@
 package dal
 
-// Changes accumulates DB changes
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Changes is a unit of work that accumulates DB changes & writes them in a single go by Commit().
+// Changes are tracked per key and conflicting operations are collapsed:
+//   - insert or set followed by delete is a no-op or a delete respectively;
+//   - delete followed by insert or set is a set;
+//   - insert or set followed by set keeps the last record (an insert stays an insert);
+//   - updates are accumulated and applied after an insert or a set of the same record;
+//   - set or delete drops previously queued updates.
+//
+// Lookups by key are done using canonical keys (see Key.Canonical()), so there are no linear scans.
+// Incomplete keys (with nil IDs to be generated on insert) are tracked by identity, so such records never collide.
 type Changes struct {
-	records []Record
+	records []Record                 // records to be inserted or set, in order of registration
+	keys    map[string]*recordChange // changes by canonical keys, see changeKey()
+}
+
+type changeOp int
+
+const (
+	changeOpNone changeOp = iota // just updates of an existing record
+	changeOpInsert
+	changeOpSet
+	changeOpDelete
+)
+
+type recordChange struct {
+	key     *Key
+	op      changeOp
+	record  Record // for inserts & sets
+	updates []Update
+}
+
+// index returns changes by canonical keys and builds it if needed
+func (changes *Changes) index() map[string]*recordChange {
+	if changes.keys == nil {
+		changes.keys = make(map[string]*recordChange, len(changes.records))
+		for _, r := range changes.records {
+			changes.keys[changeKey(r.Key())] = &recordChange{key: r.Key(), op: changeOpSet, record: r}
+		}
+	}
+	return changes.keys
+}
+
+func (changes *Changes) get(key *Key) (change *recordChange, canonical string) {
+	canonical = changeKey(key)
+	return changes.index()[canonical], canonical
+}
+
+// changeKey returns a canonical key, incomplete keys are distinguished by pointers
+func changeKey(key *Key) string {
+	if key.ID == nil {
+		return fmt.Sprintf("%s@%p", key.Canonical(), key)
+	}
+	return key.Canonical()
 }
 
 // IsChanged returns true if entity changed
 func (changes *Changes) IsChanged(record Record) bool {
-	for _, r := range changes.records {
-		if r == record {
-			return true
-		} else if EqualKeys(r.Key(), record.Key()) {
-			return true
-		}
+	if record == nil || len(changes.records) == 0 && len(changes.keys) == 0 {
+		return false
 	}
-	return false
+	change, _ := changes.get(record.Key())
+	return change != nil
 }
 
-// FlagAsChanged flags a record as changed
+// FlagAsChanged flags a record as changed, so it will be written by Commit() using Set
 func (changes *Changes) FlagAsChanged(record Record) {
 	if record == nil {
 		panic("record == nil")
 	}
 	record.MarkAsChanged()
-	for _, r := range changes.records {
+	changes.Set(record)
+}
+
+// Insert queues records for insert.
+// Returns an error if a record with the same key is already queued for insert, set or update.
+// If a record with the same key is queued for delete, it is replaced with a set.
+func (changes *Changes) Insert(records ...Record) error {
+	for _, record := range records {
+		if record == nil {
+			panic("record == nil")
+		}
+		change, canonical := changes.get(record.Key())
+		switch {
+		case change == nil:
+			changes.keys[canonical] = &recordChange{key: record.Key(), op: changeOpInsert, record: record}
+			changes.records = append(changes.records, record)
+		case change.op == changeOpDelete:
+			change.op, change.record = changeOpSet, record
+			changes.records = append(changes.records, record)
+		default:
+			return fmt.Errorf("record with key=%v is already queued for changes, can't insert it", record.Key())
+		}
+	}
+	return nil
+}
+
+// Set queues records to be written as a whole. Queued updates of the same records are dropped.
+func (changes *Changes) Set(records ...Record) {
+	for _, record := range records {
+		if record == nil {
+			panic("record == nil")
+		}
+		change, canonical := changes.get(record.Key())
+		if change == nil {
+			changes.keys[canonical] = &recordChange{key: record.Key(), op: changeOpSet, record: record}
+			changes.records = append(changes.records, record)
+			continue
+		}
+		change.updates = nil
+		switch change.op {
+		case changeOpInsert, changeOpSet:
+			changes.replaceRecord(change.record, record)
+		default:
+			change.op = changeOpSet
+			changes.records = append(changes.records, record)
+		}
+		change.record = record
+	}
+}
+
+// Update queues updates of a record. Updates of the same record are accumulated.
+// Returns an error if the record is queued for delete.
+func (changes *Changes) Update(key *Key, updates ...Update) error {
+	if key == nil {
+		panic("key == nil")
+	}
+	change, canonical := changes.get(key)
+	if change == nil {
+		change = &recordChange{key: key, op: changeOpNone}
+		changes.keys[canonical] = change
+	} else if change.op == changeOpDelete {
+		return fmt.Errorf("record with key=%v is queued for delete, can't update it", key)
+	}
+	change.updates = append(change.updates, updates...)
+	return nil
+}
+
+// Delete queues records for delete.
+// A record queued for insert is just removed from the changes, queued updates are dropped.
+func (changes *Changes) Delete(keys ...*Key) {
+	for _, key := range keys {
+		if key == nil {
+			panic("key == nil")
+		}
+		change, canonical := changes.get(key)
+		if change == nil {
+			changes.keys[canonical] = &recordChange{key: key, op: changeOpDelete}
+			continue
+		}
+		if change.record != nil {
+			changes.replaceRecord(change.record, nil)
+		}
+		if change.op == changeOpInsert {
+			delete(changes.keys, canonical)
+			continue
+		}
+		change.op, change.record, change.updates = changeOpDelete, nil, nil
+	}
+}
+
+// replaceRecord replaces or removes (if `with` is nil) a record in the list of records to be inserted or set
+func (changes *Changes) replaceRecord(record, with Record) {
+	for i, r := range changes.records {
 		if r == record {
-			return
-		} else if EqualKeys(record.Key(), r.Key()) {
+			if with == nil {
+				changes.records = append(changes.records[:i], changes.records[i+1:]...)
+			} else {
+				changes.records[i] = with
+			}
 			return
 		}
 	}
-	changes.records = append(changes.records, record)
 }
 
-// Records returns list of entity holders
+// Records returns list of records to be inserted or set
 func (changes *Changes) Records() (records []Record) {
 	records = make([]Record, len(changes.records))
 	copy(records, changes.records)
@@ -43,14 +191,109 @@ func (changes *Changes) Records() (records []Record) {
 
 // HasChanges returns true if there are changes
 func (changes *Changes) HasChanges() bool {
-	return len(changes.records) > 0
-	//// Records are always marked as changed
-	//for _, r := range changes.records {
-	//	if r.HasChanged() {
-	//		return true
-	//	}
-	//}
-	//return false
+	return len(changes.records) > 0 || len(changes.keys) > 0
+}
+
+// Commit writes all queued changes within a transaction & resets the changes on success.
+// Changes are written in a deterministic order: InsertMulti, SetMulti, updates & DeleteMulti,
+// records within each operation are ordered by keys (see CompareKeys) & then by order of registration.
+// Records with identical updates are updated by a single UpdateMulti call.
+// Updates are validated before anything is written.
+func (changes *Changes) Commit(ctx context.Context, tx ReadwriteTransaction) error {
+	var inserts, sets []Record
+	var updated, deletes []*recordChange
+	for _, record := range changes.records { // in order of registration for records with incomplete keys
+		if change, _ := changes.get(record.Key()); change.op == changeOpInsert {
+			inserts = append(inserts, record)
+		} else {
+			sets = append(sets, record)
+		}
+	}
+	for _, change := range changes.index() {
+		for _, u := range change.updates {
+			if err := u.Validate(); err != nil {
+				return fmt.Errorf("invalid update of record %v: %w", change.key, err)
+			}
+		}
+		if change.op == changeOpDelete {
+			deletes = append(deletes, change)
+		}
+		if len(change.updates) > 0 {
+			updated = append(updated, change)
+		}
+	}
+	sortRecordsByKey(inserts)
+	sortRecordsByKey(sets)
+	sortChangesByKey(updated)
+	sortChangesByKey(deletes)
+
+	if len(inserts) > 0 {
+		if err := tx.InsertMulti(ctx, inserts); err != nil {
+			return fmt.Errorf("failed to insert %d records: %w", len(inserts), err)
+		}
+	}
+	if len(sets) > 0 {
+		if err := tx.SetMulti(ctx, sets); err != nil {
+			return fmt.Errorf("failed to set %d records: %w", len(sets), err)
+		}
+	}
+	for _, group := range groupChangesByUpdates(updated) {
+		updates := group[0].updates
+		var err error
+		if len(group) == 1 {
+			err = tx.Update(ctx, group[0].key, updates)
+		} else {
+			keys := make([]*Key, len(group))
+			for i, change := range group {
+				keys[i] = change.key
+			}
+			err = tx.UpdateMulti(ctx, keys, updates)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update %d records: %w", len(group), err)
+		}
+	}
+	if len(deletes) > 0 {
+		keys := make([]*Key, len(deletes))
+		for i, change := range deletes {
+			keys[i] = change.key
+		}
+		if err := tx.DeleteMulti(ctx, keys); err != nil {
+			return fmt.Errorf("failed to delete %d records: %w", len(deletes), err)
+		}
+	}
+	*changes = Changes{}
+	return nil
+}
+
+// groupChangesByUpdates groups changes with identical updates preserving order of first occurrences
+func groupChangesByUpdates(updated []*recordChange) (groups [][]*recordChange) {
+	for _, change := range updated {
+		found := false
+		for i, group := range groups {
+			if reflect.DeepEqual(group[0].updates, change.updates) {
+				groups[i] = append(group, change)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []*recordChange{change})
+		}
+	}
+	return groups
+}
+
+func sortRecordsByKey(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return CompareKeys(records[i].Key(), records[j].Key()) < 0
+	})
+}
+
+func sortChangesByKey(changes []*recordChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return CompareKeys(changes[i].key, changes[j].key) < 0
+	})
 }
 
 // Remove as records are always marked as changed
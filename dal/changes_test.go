@@ -1,6 +1,12 @@
 package dal
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestChanges_IsChanged(t *testing.T) {
 	type test struct {
@@ -198,3 +204,138 @@ func TestChanges_HasChanges(t *testing.T) {
 //		t.Errorf("second record should be r3changed, got %v", unchanged[1])
 //	}
 //}
+
+func TestChanges_compositeKeys(t *testing.T) {
+	newRecord := func(group int) Record {
+		return NewRecord(NewKeyWithFields("memberships",
+			FieldVal{Name: "user", Value: "u1"},
+			FieldVal{Name: "group", Value: group},
+		))
+	}
+	var changes Changes
+	changes.FlagAsChanged(newRecord(1))
+	changes.FlagAsChanged(newRecord(1))
+	changes.FlagAsChanged(newRecord(2))
+	if count := len(changes.Records()); count != 2 {
+		t.Errorf("expected 2 records, got %d", count)
+	}
+	if !changes.IsChanged(newRecord(2)) {
+		t.Error("expected record to be changed")
+	}
+	if changes.IsChanged(newRecord(3)) {
+		t.Error("expected record not to be changed")
+	}
+}
+
+func TestChanges_collapse(t *testing.T) {
+	newRecord := func(id string) Record {
+		return NewRecordWithData(NewKeyWithID("c1", id), &map[string]any{"id": id})
+	}
+	upd := Update{Field: "f", Value: 1}
+
+	t.Run("insert_then_delete_is_noop", func(t *testing.T) {
+		var changes Changes
+		assert.Nil(t, changes.Insert(newRecord("r1")))
+		changes.Delete(NewKeyWithID("c1", "r1"))
+		assert.False(t, changes.HasChanges())
+		assert.Empty(t, changes.Records())
+	})
+	t.Run("set_then_delete_is_delete", func(t *testing.T) {
+		var changes Changes
+		changes.Set(newRecord("r1"))
+		assert.Nil(t, changes.Update(NewKeyWithID("c1", "r1"), upd))
+		changes.Delete(NewKeyWithID("c1", "r1"))
+		assert.True(t, changes.HasChanges())
+		assert.Empty(t, changes.Records())
+		change, _ := changes.get(NewKeyWithID("c1", "r1"))
+		assert.Equal(t, changeOpDelete, change.op)
+		assert.Nil(t, change.updates)
+	})
+	t.Run("delete_then_insert_is_set", func(t *testing.T) {
+		var changes Changes
+		changes.Delete(NewKeyWithID("c1", "r1"))
+		r1 := newRecord("r1")
+		assert.Nil(t, changes.Insert(r1))
+		change, _ := changes.get(r1.Key())
+		assert.Equal(t, changeOpSet, change.op)
+		assert.Equal(t, []Record{r1}, changes.Records())
+	})
+	t.Run("insert_then_set_stays_insert", func(t *testing.T) {
+		var changes Changes
+		assert.Nil(t, changes.Insert(newRecord("r1")))
+		r1 := newRecord("r1")
+		changes.Set(r1)
+		change, _ := changes.get(r1.Key())
+		assert.Equal(t, changeOpInsert, change.op)
+		assert.Equal(t, []Record{r1}, changes.Records())
+	})
+	t.Run("conflicts", func(t *testing.T) {
+		var changes Changes
+		changes.Set(newRecord("r1"))
+		assert.NotNil(t, changes.Insert(newRecord("r1")))
+		changes.Delete(NewKeyWithID("c1", "r2"))
+		assert.NotNil(t, changes.Update(NewKeyWithID("c1", "r2"), upd))
+	})
+	t.Run("updates_are_accumulated", func(t *testing.T) {
+		var changes Changes
+		key := NewKeyWithID("c1", "r1")
+		assert.Nil(t, changes.Update(key, upd))
+		assert.Nil(t, changes.Update(key, Update{Field: "g", Value: 2}))
+		change, _ := changes.get(key)
+		assert.Equal(t, []Update{upd, {Field: "g", Value: 2}}, change.updates)
+		assert.True(t, changes.IsChanged(NewRecord(key)))
+	})
+	t.Run("inserts_with_incomplete_keys", func(t *testing.T) {
+		var changes Changes
+		r1 := NewRecordWithData(NewIncompleteKey("c1", reflect.String, nil), &map[string]any{"n": 1})
+		r2 := NewRecordWithData(NewIncompleteKey("c1", reflect.String, nil), &map[string]any{"n": 2})
+		assert.Nil(t, changes.Insert(r1, r2))
+		assert.Equal(t, []Record{r1, r2}, changes.Records())
+		assert.True(t, changes.IsChanged(r2))
+		assert.False(t, changes.IsChanged(NewRecord(NewIncompleteKey("c1", reflect.String, nil))))
+		changes.Delete(r1.Key())
+		assert.Equal(t, []Record{r2}, changes.Records())
+	})
+}
+
+func TestChanges_Commit(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	for _, id := range []string{"u1", "u2", "u3", "d1", "d2"} {
+		memDB.putData(NewKeyWithID("c1", id), map[string]any{"id": id})
+	}
+	newRecord := func(id string) Record {
+		return NewRecordWithData(NewKeyWithID("c1", id), &map[string]any{"id": id, "new": true})
+	}
+	var changes Changes
+	assert.Nil(t, changes.Insert(newRecord("i2"), newRecord("i1")))
+	changes.Set(newRecord("s1"))
+	assert.Nil(t, changes.Update(NewKeyWithID("c1", "u2"), Update{Field: "x", Value: 1}))
+	assert.Nil(t, changes.Update(NewKeyWithID("c1", "u1"), Update{Field: "x", Value: 1}))
+	assert.Nil(t, changes.Update(NewKeyWithID("c1", "u3"), Update{Field: "y", Value: 2}))
+	assert.Nil(t, changes.Update(NewKeyWithID("c1", "i1"), Update{Field: "z", Value: 3}))
+	changes.Delete(NewKeyWithID("c1", "d2"), NewKeyWithID("c1", "d1"))
+
+	err := memDB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return changes.Commit(ctx, tx)
+	})
+	assert.Nil(t, err)
+	assert.False(t, changes.HasChanges())
+	assert.Equal(t, []string{"InsertMulti:2", "SetMulti:1", "Update:1", "UpdateMulti:2", "Update:1", "DeleteMulti:2"}, memDB.calls)
+	assert.Equal(t, map[string]any{"id": "i1", "new": true, "z": float64(3)}, memDB.getData(NewKeyWithID("c1", "i1")))
+	assert.Equal(t, map[string]any{"id": "u1", "x": float64(1)}, memDB.getData(NewKeyWithID("c1", "u1")))
+	assert.Nil(t, memDB.getData(NewKeyWithID("c1", "d1")))
+
+	t.Run("invalid_update", func(t *testing.T) {
+		memDB.calls = nil
+		var changes Changes
+		changes.Set(newRecord("s2"))
+		assert.Nil(t, changes.Update(NewKeyWithID("c1", "u1"), Update{}))
+		err := memDB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return changes.Commit(ctx, tx)
+		})
+		assert.NotNil(t, err)
+		assert.Empty(t, memDB.calls)
+		assert.True(t, changes.HasChanges())
+	})
+}
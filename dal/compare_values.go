@@ -0,0 +1,142 @@
+package dal
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// valueTypeRank defines an order of values of different types.
+// It follows the Firestore ordering: null < bool < number < timestamp < string < bytes < other.
+type valueTypeRank int
+
+const (
+	rankNull valueTypeRank = iota
+	rankBool
+	rankNumber
+	rankTime
+	rankString
+	rankBytes
+	rankOther
+)
+
+func rankOfValue(v reflect.Value) valueTypeRank {
+	if !v.IsValid() {
+		return rankNull
+	}
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		return rankTime
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return rankBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return rankNumber
+	case reflect.String:
+		return rankString
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return rankBytes
+		}
+	default:
+		// no specific rank
+	}
+	return rankOther
+}
+
+// indirectValue dereferences pointers & interfaces, nil pointers are returned as an invalid reflect.Value
+func indirectValue(v any) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// compareValues compares 2 values and returns -1, 0 or 1.
+// Values of different types are ordered by type rank (see valueTypeRank),
+// numbers of different types are compared by value.
+func compareValues(a, b any) int {
+	va, vb := indirectValue(a), indirectValue(b)
+	ra, rb := rankOfValue(va), rankOfValue(vb)
+	if ra != rb {
+		return compareOrdered(ra, rb)
+	}
+	switch ra {
+	case rankNull:
+		return 0
+	case rankBool:
+		return compareBools(va.Bool(), vb.Bool())
+	case rankNumber:
+		return compareNumbers(va, vb)
+	case rankTime:
+		return va.Interface().(time.Time).Compare(vb.Interface().(time.Time))
+	case rankString:
+		return strings.Compare(va.String(), vb.String())
+	case rankBytes:
+		return bytes.Compare(va.Bytes(), vb.Bytes())
+	default:
+		return strings.Compare(fmt.Sprintf("%v", va.Interface()), fmt.Sprintf("%v", vb.Interface()))
+	}
+}
+
+func compareOrdered[T int | int64 | uint64 | float64 | valueTypeRank](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareNumbers(a, b reflect.Value) int {
+	switch {
+	case a.CanInt() && b.CanInt():
+		return compareOrdered(a.Int(), b.Int())
+	case a.CanUint() && b.CanUint():
+		return compareOrdered(a.Uint(), b.Uint())
+	case a.CanInt() && b.CanUint():
+		if a.Int() < 0 {
+			return -1
+		}
+		return compareOrdered(uint64(a.Int()), b.Uint())
+	case a.CanUint() && b.CanInt():
+		if b.Int() < 0 {
+			return 1
+		}
+		return compareOrdered(a.Uint(), uint64(b.Int()))
+	default:
+		return compareOrdered(numberToFloat64(a), numberToFloat64(b))
+	}
+}
+
+func numberToFloat64(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
@@ -0,0 +1,44 @@
+package dal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	s := "b"
+	for _, tt := range []struct {
+		name     string
+		a, b     any
+		expected int
+	}{
+		{name: "nils", a: nil, b: nil, expected: 0},
+		{name: "nil_vs_bool", a: nil, b: false, expected: -1},
+		{name: "bool_vs_number", a: true, b: 0, expected: -1},
+		{name: "number_vs_time", a: 1, b: now, expected: -1},
+		{name: "time_vs_string", a: now, b: "", expected: -1},
+		{name: "string_vs_bytes", a: "z", b: []byte("a"), expected: -1},
+		{name: "bools", a: true, b: false, expected: 1},
+		{name: "equal_bools", a: true, b: true, expected: 0},
+		{name: "ints", a: 1, b: 2, expected: -1},
+		{name: "int_vs_int64", a: 3, b: int64(3), expected: 0},
+		{name: "uints", a: uint(3), b: uint8(2), expected: 1},
+		{name: "negative_int_vs_uint", a: -1, b: uint(0), expected: -1},
+		{name: "uint_vs_negative_int", a: uint(0), b: -1, expected: 1},
+		{name: "int_vs_uint", a: 5, b: uint(3), expected: 1},
+		{name: "uint_vs_int", a: uint(5), b: 7, expected: -1},
+		{name: "int_vs_float", a: 2, b: 1.5, expected: 1},
+		{name: "times", a: now, b: now.Add(time.Second), expected: -1},
+		{name: "strings", a: "a", b: "b", expected: -1},
+		{name: "string_vs_pointer", a: "b", b: &s, expected: 0},
+		{name: "bytes", a: []byte("b"), b: []byte("a"), expected: 1},
+		{name: "others", a: []int{1}, b: []int{2}, expected: -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compareValues(tt.a, tt.b))
+			assert.Equal(t, -tt.expected, compareValues(tt.b, tt.a))
+		})
+	}
+}
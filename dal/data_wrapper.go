@@ -19,6 +19,16 @@ type RecordAfterLoadHook interface {
 	AfterLoad(ctx context.Context, key *Key) (err error)
 }
 
+// recordDataBeforeSaveHook is implemented by a DataWrapper created by MakeRecordData
+type recordDataBeforeSaveHook interface {
+	BeforeSave(ctx context.Context, db DB, key *Key) (err error)
+}
+
+// recordDataAfterLoadHook is implemented by a DataWrapper created by MakeRecordData
+type recordDataAfterLoadHook interface {
+	AfterLoad(ctx context.Context, db DB, key *Key) (err error)
+}
+
 type RecordHook = func(ctx context.Context, record Record) error
 
 type RecordDataHook = func(ctx context.Context, db DB, key *Key, data any) (err error)
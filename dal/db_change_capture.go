@@ -0,0 +1,469 @@
+package dal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrChangesNotPublished indicates a transaction has been committed but its change events failed to be published
+var ErrChangesNotPublished = errors.New("change events of committed transaction have not been published")
+
+// ChangeOp is a type of write operation captured by a ChangeEvent
+type ChangeOp string
+
+const (
+	ChangeOpInsert ChangeOp = "insert"
+	ChangeOpSet    ChangeOp = "set"
+	ChangeOpUpdate ChangeOp = "update"
+	ChangeOpDelete ChangeOp = "delete"
+)
+
+// ChangeEvent describes a single committed write to a record
+type ChangeEvent struct {
+	Op  ChangeOp
+	Key *Key
+
+	// Before is data of a record before the write, populated only if CaptureBeforeImages() option is used.
+	// It is nil for inserts & writes of records that did not exist.
+	Before map[string]any
+
+	// After is data of a record after the write, populated for inserts & sets,
+	// and for updates if CaptureBeforeImages() option is used. For updates it is computed
+	// by applying the updates to the before image, so it is nil for updates with transforms
+	// (e.g. Increment()) or ServerTimestamp values. It is nil for deletes.
+	After map[string]any
+
+	// Updates are populated for updates only
+	Updates []Update
+
+	// TxID is the same for all events of a transaction
+	TxID string
+
+	// Timestamp is a time of the commit, the same for all events of a transaction
+	Timestamp time.Time
+}
+
+type changeEventJSON struct {
+	Op        ChangeOp           `json:"op"`
+	Key       string             `json:"key"`
+	Before    map[string]any     `json:"before,omitempty"`
+	After     map[string]any     `json:"after,omitempty"`
+	Updates   []changeUpdateJSON `json:"updates,omitempty"`
+	TxID      string             `json:"txID"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+type changeUpdateJSON struct {
+	Field  string `json:"field"`
+	Value  any    `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. A key is written as a string (see Key.String()),
+// an update as {"field": "a.b", "value": 1} or as {"field": "a.b", "delete": true} for DeleteField.
+func (e ChangeEvent) MarshalJSON() ([]byte, error) {
+	v := changeEventJSON{
+		Op:        e.Op,
+		Before:    e.Before,
+		After:     e.After,
+		TxID:      e.TxID,
+		Timestamp: e.Timestamp,
+	}
+	if e.Key != nil {
+		v.Key = e.Key.String()
+	}
+	if len(e.Updates) > 0 {
+		v.Updates = make([]changeUpdateJSON, len(e.Updates))
+		for i, u := range e.Updates {
+			field := u.Field
+			if field == "" {
+				field = strings.Join(u.FieldPath, ".")
+			}
+			if u.Value == DeleteField {
+				v.Updates[i] = changeUpdateJSON{Field: field, Delete: true}
+			} else {
+				v.Updates[i] = changeUpdateJSON{Field: field, Value: u.Value}
+			}
+		}
+	}
+	return json.Marshal(v)
+}
+
+// ChangeSink receives change events of committed transactions.
+// Publish is called once per transaction with all its events in order of writes.
+type ChangeSink interface {
+	Publish(ctx context.Context, events []ChangeEvent) error
+}
+
+// ChangeCaptureOption configures a DB created by NewDBWithChangeCapture()
+type ChangeCaptureOption func(options *changeCaptureOptions)
+
+type changeCaptureOptions struct {
+	beforeImages bool
+	newTxID      func() string
+	now          func() time.Time
+}
+
+// CaptureBeforeImages makes events to have Before data (and After data for updates).
+// Before images are taken from records read by Get() & GetMulti() of a transaction
+// and from its previous writes. Records that are not known yet are read right before a write
+// at the cost of an additional read, which is possible only until the first write of a transaction
+// as some databases (e.g. Firestore & Datastore) reject reads after writes.
+// Afterwards a write of an unknown record fails with ErrNotSupported,
+// so a worker should read records it writes before its first write.
+func CaptureBeforeImages() ChangeCaptureOption {
+	return func(options *changeCaptureOptions) {
+		options.beforeImages = true
+	}
+}
+
+// WithTxIDGenerator sets a generator of transaction IDs, by default a ULID is used
+func WithTxIDGenerator(newTxID func() string) ChangeCaptureOption {
+	if newTxID == nil {
+		panic("newTxID is a required parameter, got nil")
+	}
+	return func(options *changeCaptureOptions) {
+		options.newTxID = newTxID
+	}
+}
+
+// NewDBWithChangeCapture wraps a DB so every committed write of a readwrite transaction is published
+// to the sink as a ChangeEvent.
+// Events of a transaction are published by a single Publish call after a successful commit,
+// events of failed (rolled back) transactions & of failed attempts of retried transactions are never published.
+// If publishing fails, RunReadwriteTransaction returns an error that matches ErrChangesNotPublished
+// while the transaction itself stays committed.
+func NewDBWithChangeCapture(db DB, sink ChangeSink, options ...ChangeCaptureOption) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	if sink == nil {
+		panic("sink is a required parameter, got nil")
+	}
+	v := dbWithChangeCapture{DB: db, sink: sink, options: changeCaptureOptions{newTxID: NewULID, now: time.Now}}
+	for _, option := range options {
+		option(&v.options)
+	}
+	return v
+}
+
+type dbWithChangeCapture struct {
+	DB
+	sink    ChangeSink
+	options changeCaptureOptions
+}
+
+func (v dbWithChangeCapture) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	var tx *readwriteTransactionWithChangeCapture // a worker can be called multiple times if a transaction is retried
+	err := v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, rwTx ReadwriteTransaction) error {
+		tx = &readwriteTransactionWithChangeCapture{ReadwriteTransaction: rwTx, db: v}
+		return f(ctx, tx)
+	}, options...)
+	if err != nil || tx == nil || len(tx.events) == 0 {
+		return err
+	}
+	txID, timestamp := v.options.newTxID(), v.options.now()
+	for i := range tx.events {
+		tx.events[i].TxID, tx.events[i].Timestamp = txID, timestamp
+	}
+	if err = v.sink.Publish(ctx, tx.events); err != nil {
+		return fmt.Errorf("%w: txID=%s: %w", ErrChangesNotPublished, txID, err)
+	}
+	return nil
+}
+
+type readwriteTransactionWithChangeCapture struct {
+	ReadwriteTransaction
+	db     dbWithChangeCapture
+	mutex  sync.Mutex
+	events []ChangeEvent
+
+	images    map[string]map[string]any // known data of records by canonical key, nil for records that do not exist
+	hasWrites bool
+}
+
+func (tx *readwriteTransactionWithChangeCapture) capture(events ...ChangeEvent) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	tx.events = append(tx.events, events...)
+}
+
+func (tx *readwriteTransactionWithChangeCapture) Get(ctx context.Context, record Record) error {
+	if err := tx.ReadwriteTransaction.Get(ctx, record); err != nil {
+		return err
+	}
+	tx.remember([]Record{record})
+	return nil
+}
+
+func (tx *readwriteTransactionWithChangeCapture) GetMulti(ctx context.Context, records []Record) error {
+	if err := tx.ReadwriteTransaction.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	tx.remember(records)
+	return nil
+}
+
+// remember keeps images of read records to be used as before images of later writes
+func (tx *readwriteTransactionWithChangeCapture) remember(records []Record) {
+	if !tx.db.options.beforeImages {
+		return
+	}
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.images == nil {
+		tx.images = make(map[string]map[string]any, len(records))
+	}
+	for _, r := range records {
+		key := r.Key()
+		if key == nil {
+			continue
+		}
+		if _, known := tx.images[key.Canonical()]; known {
+			continue // the record could be written by the transaction already
+		}
+		if exists, err := recordStatus(r); err != nil {
+			continue
+		} else if exists {
+			tx.images[key.Canonical()] = recordImage(r)
+		} else {
+			tx.images[key.Canonical()] = nil
+		}
+	}
+}
+
+// written records images of records after a write, a nil image of an existing record means it is unknown
+func (tx *readwriteTransactionWithChangeCapture) written(keys []*Key, images []map[string]any, exist bool) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	tx.hasWrites = true
+	if !tx.db.options.beforeImages {
+		return
+	}
+	if tx.images == nil {
+		tx.images = make(map[string]map[string]any, len(keys))
+	}
+	for i, key := range keys {
+		if image := imageAt(images, i); image != nil || !exist {
+			tx.images[key.Canonical()] = image
+		} else {
+			delete(tx.images, key.Canonical())
+		}
+	}
+}
+
+// beforeImages returns current data of records if before images are requested, otherwise returns nil.
+// Records that are not known to the transaction are read, which is possible only before the first write.
+func (tx *readwriteTransactionWithChangeCapture) beforeImages(ctx context.Context, keys []*Key) ([]map[string]any, error) {
+	if !tx.db.options.beforeImages {
+		return nil, nil
+	}
+	tx.mutex.Lock()
+	var unknown []Record
+	for _, key := range keys {
+		if _, known := tx.images[key.Canonical()]; !known {
+			unknown = append(unknown, NewRecordWithData(key, new(map[string]any)))
+		}
+	}
+	hasWrites := tx.hasWrites
+	tx.mutex.Unlock()
+	if len(unknown) > 0 {
+		if hasWrites {
+			return nil, fmt.Errorf("%w: can not read before image of record %v after writes of a transaction, read it before the first write",
+				ErrNotSupported, unknown[0].Key())
+		}
+		if err := tx.ReadwriteTransaction.GetMulti(ctx, unknown); err != nil {
+			return nil, fmt.Errorf("failed to read records to capture changes: %w", err)
+		}
+		tx.remember(unknown)
+	}
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	images := make([]map[string]any, len(keys))
+	for i, key := range keys {
+		images[i] = tx.images[key.Canonical()]
+	}
+	return images, nil
+}
+
+// applyUpdates returns a copy of an image with applied updates.
+// It returns nil if the result can not be computed without a read, e.g. for transforms.
+func applyUpdates(image map[string]any, updates []Update) map[string]any {
+	if image == nil {
+		return nil
+	}
+	result, _ := snapshotValue(reflect.ValueOf(image)).(map[string]any)
+	for _, u := range updates {
+		if _, isTransform := u.Value.(Transform); isTransform || u.Value == ServerTimestamp {
+			return nil
+		}
+		path := u.FieldPath
+		if u.Field != "" {
+			path = strings.Split(u.Field, ".")
+		}
+		if len(path) == 0 {
+			return nil
+		}
+		m := result
+		for _, name := range path[:len(path)-1] {
+			child, ok := m[name].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				m[name] = child
+			}
+			m = child
+		}
+		if name := path[len(path)-1]; u.Value == DeleteField {
+			delete(m, name)
+		} else {
+			m[name] = snapshotValue(reflect.ValueOf(u.Value))
+		}
+	}
+	return result
+}
+
+func imageAt(images []map[string]any, i int) map[string]any {
+	if images == nil {
+		return nil
+	}
+	return images[i]
+}
+
+// recordImage returns a deep copy of record data, so later changes of the record do not affect events
+func recordImage(r Record) map[string]any {
+	data, err := snapshotData(recordDataOrNil(r))
+	if err != nil {
+		b, err := json.Marshal(recordDataOrNil(r))
+		if err != nil || json.Unmarshal(b, &data) != nil {
+			return nil
+		}
+	}
+	return data
+}
+
+func recordKeys(records []Record) []*Key {
+	keys := make([]*Key, len(records))
+	for i, r := range records {
+		keys[i] = r.Key()
+	}
+	return keys
+}
+
+func (tx *readwriteTransactionWithChangeCapture) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	return tx.InsertMulti(ctx, []Record{record}, opts...)
+}
+
+func (tx *readwriteTransactionWithChangeCapture) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) (err error) {
+	if len(records) == 1 {
+		err = tx.ReadwriteTransaction.Insert(ctx, records[0], opts...)
+	} else {
+		err = tx.ReadwriteTransaction.InsertMulti(ctx, records, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	events := make([]ChangeEvent, len(records))
+	after := make([]map[string]any, len(records))
+	for i, r := range records { // keys are read after insert as IDs can be generated by the insert
+		after[i] = recordImage(r)
+		events[i] = ChangeEvent{Op: ChangeOpInsert, Key: r.Key(), After: after[i]}
+	}
+	tx.written(recordKeys(records), after, true)
+	tx.capture(events...)
+	return nil
+}
+
+func (tx *readwriteTransactionWithChangeCapture) Set(ctx context.Context, record Record) error {
+	return tx.SetMulti(ctx, []Record{record})
+}
+
+func (tx *readwriteTransactionWithChangeCapture) SetMulti(ctx context.Context, records []Record) error {
+	keys := recordKeys(records)
+	before, err := tx.beforeImages(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if len(records) == 1 {
+		err = tx.ReadwriteTransaction.Set(ctx, records[0])
+	} else {
+		err = tx.ReadwriteTransaction.SetMulti(ctx, records)
+	}
+	if err != nil {
+		return err
+	}
+	events := make([]ChangeEvent, len(records))
+	after := make([]map[string]any, len(records))
+	for i, r := range records {
+		after[i] = recordImage(r)
+		events[i] = ChangeEvent{Op: ChangeOpSet, Key: r.Key(), Before: imageAt(before, i), After: after[i]}
+	}
+	tx.written(keys, after, true)
+	tx.capture(events...)
+	return nil
+}
+
+func (tx *readwriteTransactionWithChangeCapture) Update(ctx context.Context, key *Key, updates []Update, preconditions ...Precondition) error {
+	return tx.UpdateMulti(ctx, []*Key{key}, updates, preconditions...)
+}
+
+func (tx *readwriteTransactionWithChangeCapture) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) error {
+	before, err := tx.beforeImages(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 1 {
+		err = tx.ReadwriteTransaction.Update(ctx, keys[0], updates, preconditions...)
+	} else {
+		err = tx.ReadwriteTransaction.UpdateMulti(ctx, keys, updates, preconditions...)
+	}
+	if err != nil {
+		return err
+	}
+	updates = append([]Update(nil), updates...) // events should not be affected by changes of the slice
+	events := make([]ChangeEvent, len(keys))
+	var after []map[string]any
+	if before != nil {
+		after = make([]map[string]any, len(keys))
+	}
+	for i, key := range keys {
+		if before != nil {
+			after[i] = applyUpdates(before[i], updates)
+		}
+		events[i] = ChangeEvent{Op: ChangeOpUpdate, Key: key, Before: imageAt(before, i), After: imageAt(after, i), Updates: updates}
+	}
+	tx.written(keys, after, true)
+	tx.capture(events...)
+	return nil
+}
+
+func (tx *readwriteTransactionWithChangeCapture) Delete(ctx context.Context, key *Key) error {
+	return tx.DeleteMulti(ctx, []*Key{key})
+}
+
+func (tx *readwriteTransactionWithChangeCapture) DeleteMulti(ctx context.Context, keys []*Key) error {
+	before, err := tx.beforeImages(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 1 {
+		err = tx.ReadwriteTransaction.Delete(ctx, keys[0])
+	} else {
+		err = tx.ReadwriteTransaction.DeleteMulti(ctx, keys)
+	}
+	if err != nil {
+		return err
+	}
+	events := make([]ChangeEvent, len(keys))
+	for i, key := range keys {
+		events[i] = ChangeEvent{Op: ChangeOpDelete, Key: key, Before: imageAt(before, i)}
+	}
+	tx.written(keys, nil, false)
+	tx.capture(events...)
+	return nil
+}
@@ -0,0 +1,224 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testChangeUser struct {
+	Name string `json:"name" dalgo:"name"`
+}
+
+func newTestChangeCaptureDB(memDB *memoryDB, options ...ChangeCaptureOption) (DB, *[][]ChangeEvent) {
+	var published [][]ChangeEvent
+	sink := ChangeSinkFunc(func(ctx context.Context, events []ChangeEvent) error {
+		published = append(published, events)
+		return nil
+	})
+	options = append([]ChangeCaptureOption{WithTxIDGenerator(func() string { return "tx1" })}, options...)
+	return NewDBWithChangeCapture(memDB, sink, options...), &published
+}
+
+func TestNewDBWithChangeCapture(t *testing.T) {
+	sink := ChangeSinkFunc(func(ctx context.Context, events []ChangeEvent) error { return nil })
+	assert.Panics(t, func() {
+		NewDBWithChangeCapture(nil, sink)
+	})
+	assert.Panics(t, func() {
+		NewDBWithChangeCapture(newMemoryDB(), nil)
+	})
+	assert.Panics(t, func() {
+		WithTxIDGenerator(nil)
+	})
+}
+
+func TestDBWithChangeCapture_RunReadwriteTransaction(t *testing.T) {
+	ctx := context.Background()
+	u1, u2, u3 := NewKeyWithID("users", "u1"), NewKeyWithID("users", "u2"), NewKeyWithID("users", "u3")
+
+	t.Run("committed", func(t *testing.T) {
+		memDB := newMemoryDB()
+		memDB.putData(u2, testChangeUser{Name: "Bob"})
+		memDB.putData(u3, testChangeUser{Name: "Eve"})
+		db, published := newTestChangeCaptureDB(memDB)
+		user := &testChangeUser{Name: "Ann"}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Insert(ctx, NewRecordWithData(u1, user)); err != nil {
+				return err
+			}
+			user.Name = "Changed after insert"
+			if err := tx.Update(ctx, u2, []Update{{Field: "name", Value: "Bobby"}}); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, u3)
+		})
+		assert.Nil(t, err)
+		assert.Len(t, *published, 1)
+		events := (*published)[0]
+		assert.Len(t, events, 3)
+		for _, event := range events {
+			assert.Equal(t, "tx1", event.TxID)
+			assert.Equal(t, events[0].Timestamp, event.Timestamp)
+			assert.False(t, event.Timestamp.IsZero())
+		}
+		assert.Equal(t, ChangeOpInsert, events[0].Op)
+		assert.Equal(t, u1, events[0].Key)
+		assert.Equal(t, map[string]any{"name": "Ann"}, events[0].After)
+		assert.Equal(t, ChangeOpUpdate, events[1].Op)
+		assert.Equal(t, []Update{{Field: "name", Value: "Bobby"}}, events[1].Updates)
+		assert.Nil(t, events[1].Before)
+		assert.Equal(t, ChangeOpDelete, events[2].Op)
+		assert.Equal(t, u3, events[2].Key)
+	})
+
+	t.Run("before_images", func(t *testing.T) {
+		memDB := newMemoryDB()
+		memDB.putData(u1, testChangeUser{Name: "Ann"})
+		memDB.putData(u2, testChangeUser{Name: "Bob"})
+		db, published := newTestChangeCaptureDB(memDB, CaptureBeforeImages())
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Get(ctx, NewRecordWithData(u2, new(testChangeUser))); err != nil {
+				return err
+			}
+			if err := tx.SetMulti(ctx, []Record{
+				NewRecordWithData(u1, &testChangeUser{Name: "Anna"}),
+				NewRecordWithData(u3, &testChangeUser{Name: "Eve"}),
+			}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, u2, []Update{{Field: "name", Value: "Bobby"}}); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, u1)
+		})
+		assert.Nil(t, err)
+		events := (*published)[0]
+		assert.Len(t, events, 4)
+		assert.Equal(t, map[string]any{"name": "Ann"}, events[0].Before)
+		assert.Equal(t, map[string]any{"name": "Anna"}, events[0].After)
+		assert.Nil(t, events[1].Before)
+		assert.Equal(t, map[string]any{"name": "Bob"}, events[2].Before)
+		assert.Equal(t, map[string]any{"name": "Bobby"}, events[2].After)
+		assert.Equal(t, map[string]any{"name": "Anna"}, events[3].Before)
+		assert.Nil(t, events[3].After)
+	})
+
+	t.Run("no_reads_after_writes", func(t *testing.T) {
+		memDB := newMemoryDB()
+		memDB.putData(u1, map[string]any{"name": "Ann", "address": map[string]any{"city": "Paris"}})
+		db, published := newTestChangeCaptureDB(memDB, CaptureBeforeImages())
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Update(ctx, u1, []Update{{Field: "address.city", Value: "Rome"}}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, u1, []Update{{Field: "name", Value: DeleteField}}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, u1, []Update{{Field: "visits", Value: Increment(1)}}); err != nil {
+				return err
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"GetMulti:1", "Update:1", "Update:1", "Update:1"}, memDB.calls)
+		events := (*published)[0]
+		assert.Len(t, events, 3)
+		assert.Equal(t, map[string]any{"name": "Ann", "address": map[string]any{"city": "Paris"}}, events[0].Before)
+		assert.Equal(t, map[string]any{"name": "Ann", "address": map[string]any{"city": "Rome"}}, events[0].After)
+		assert.Equal(t, events[0].After, events[1].Before)
+		assert.Equal(t, map[string]any{"address": map[string]any{"city": "Rome"}}, events[1].After)
+		assert.Equal(t, events[1].After, events[2].Before)
+		assert.Nil(t, events[2].After, "result of a transform is unknown without a read")
+	})
+
+	t.Run("unknown_record_after_writes", func(t *testing.T) {
+		memDB := newMemoryDB()
+		memDB.putData(u2, testChangeUser{Name: "Bob"})
+		db, published := newTestChangeCaptureDB(memDB, CaptureBeforeImages())
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Set(ctx, NewRecordWithData(u1, &testChangeUser{Name: "Ann"})); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, u2)
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+		assert.Empty(t, *published)
+		assert.Equal(t, []string{"GetMulti:1", "Set:1"}, memDB.calls)
+	})
+
+	t.Run("keys_with_ids_of_different_types", func(t *testing.T) {
+		memDB := newMemoryDB()
+		db, _ := newTestChangeCaptureDB(memDB, CaptureBeforeImages())
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Set(ctx, NewRecordWithData(NewKeyWithID("users", "1"), &testChangeUser{Name: "Ann"})); err != nil {
+				return err
+			}
+			return tx.Set(ctx, NewRecordWithData(NewKeyWithID("users", 1), &testChangeUser{Name: "Bob"}))
+		})
+		assert.ErrorIs(t, err, ErrNotSupported, "image of users/\"1\" should not be used for users/1")
+	})
+
+	t.Run("rolled_back", func(t *testing.T) {
+		db, published := newTestChangeCaptureDB(newMemoryDB())
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Set(ctx, NewRecordWithData(u1, &testChangeUser{Name: "Ann"})); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		assert.EqualError(t, err, "rollback")
+		assert.Empty(t, *published)
+	})
+
+	t.Run("failed_write", func(t *testing.T) {
+		memDB := newMemoryDB()
+		memDB.err = errors.New("write failed")
+		db, published := newTestChangeCaptureDB(memDB)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			_ = tx.Set(ctx, NewRecordWithData(u1, &testChangeUser{Name: "Ann"}))
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Empty(t, *published)
+	})
+
+	t.Run("publish_failed", func(t *testing.T) {
+		sink := ChangeSinkFunc(func(ctx context.Context, events []ChangeEvent) error {
+			return errors.New("sink is down")
+		})
+		memDB := newMemoryDB()
+		db := NewDBWithChangeCapture(memDB, sink)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Set(ctx, NewRecordWithData(u1, &testChangeUser{Name: "Ann"}))
+		})
+		assert.ErrorIs(t, err, ErrChangesNotPublished)
+		assert.ErrorContains(t, err, "sink is down")
+		assert.NotNil(t, memDB.getData(u1), "transaction should stay committed")
+	})
+}
+
+func TestChangeEvent_MarshalJSON(t *testing.T) {
+	event := ChangeEvent{
+		Op:  ChangeOpUpdate,
+		Key: NewKeyWithID("users", "u1"),
+		Updates: []Update{
+			{FieldPath: FieldPath{"address", "city"}, Value: "Dublin"},
+			{Field: "phone", Value: DeleteField},
+		},
+		TxID:      "tx1",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	b, err := event.MarshalJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"op": "update",
+		"key": "users/u1",
+		"updates": [{"field": "address.city", "value": "Dublin"}, {"field": "phone", "delete": true}],
+		"txID": "tx1",
+		"timestamp": "2024-01-02T03:04:05Z"
+	}`, string(b))
+}
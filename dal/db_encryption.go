@@ -0,0 +1,911 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEncryptedFieldCondition indicates a query has a condition on an encrypted field that can't be evaluated by a DB
+var ErrEncryptedFieldCondition = errors.New("condition on encrypted field")
+
+// ErrEncryptionNotConfigured indicates a collection is not configured for a DB created by NewDBWithEncryption()
+var ErrEncryptionNotConfigured = errors.New("encryption is not configured")
+
+// EncryptionOption configures a DB created by NewDBWithEncryption()
+type EncryptionOption func(v *dbWithEncryption)
+
+// EncryptRecordsOf configures a collection to encrypt fields tagged by `dalgo:",encrypted"` & `dalgo:",deterministic"`
+// in a struct type of records data, e.g. EncryptRecordsOf("users", User{}).
+func EncryptRecordsOf(collection string, data any) EncryptionOption {
+	if collection == "" {
+		panic("collection is a required parameter, got empty string")
+	}
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("data should be a struct or a pointer to a struct, got %T", data))
+	}
+	return func(v *dbWithEncryption) {
+		v.fields.addCollection(collection)
+		for path, deterministic := range taggedEncryptedFields(t) {
+			v.fields.add(collection, path, deterministic)
+		}
+	}
+}
+
+// EncryptFields sets dot separated paths of fields of a collection to be encrypted
+// in addition to fields tagged by `dalgo:",encrypted"`.
+func EncryptFields(collection string, paths ...string) EncryptionOption {
+	return encryptFields(collection, paths, false)
+}
+
+// EncryptFieldsDeterministically sets dot separated paths of fields of a collection to be encrypted deterministically
+// in addition to fields tagged by `dalgo:",deterministic"`. Equal values have equal ciphertexts,
+// so such fields can be used in equality conditions of queries, but it reveals which records have equal values.
+// Equality conditions are encrypted by the current key, so they do not match values written before a key rotation.
+func EncryptFieldsDeterministically(collection string, paths ...string) EncryptionOption {
+	return encryptFields(collection, paths, true)
+}
+
+func encryptFields(collection string, paths []string, deterministic bool) EncryptionOption {
+	if collection == "" {
+		panic("collection is a required parameter, got empty string")
+	}
+	if len(paths) == 0 {
+		panic("at least 1 field path is required")
+	}
+	return func(v *dbWithEncryption) {
+		v.fields.addCollection(collection)
+		for _, path := range paths {
+			v.fields.add(collection, path, deterministic)
+		}
+	}
+}
+
+// PlaintextCollections allows access to collections that have no encrypted fields
+func PlaintextCollections(collections ...string) EncryptionOption {
+	if len(collections) == 0 {
+		panic("at least 1 collection is required")
+	}
+	return func(v *dbWithEncryption) {
+		for _, collection := range collections {
+			v.fields.addCollection(collection)
+		}
+	}
+}
+
+// AllowPlaintextValues makes values of encrypted fields that are not encrypted to be read as is,
+// so encryption can be enabled for existing data. Values are encrypted when records are written again.
+// It is intended for a migration period only: by default such values fail reads with ErrDecryptionFailed,
+// so a value written bypassing encryption is not silently accepted.
+func AllowPlaintextValues() EncryptionOption {
+	return func(v *dbWithEncryption) {
+		v.allowPlaintext = true
+	}
+}
+
+// NewDBWithEncryption wraps a DB so values of sensitive fields are stored encrypted with AES-GCM.
+// Each collection accessed through the DB should be configured by options:
+//   - EncryptRecordsOf() for fields tagged by `dalgo` struct tags options in a type of records data;
+//   - EncryptFields() & EncryptFieldsDeterministically() for fields listed explicitly;
+//   - PlaintextCollections() for collections without encrypted fields.
+//
+// For example:
+//
+//	type User struct {
+//		Email string `dalgo:"email,deterministic"` // can be used in equality conditions
+//		Phone string `dalgo:"phone,encrypted"`
+//	}
+//
+//	db = dal.NewDBWithEncryption(db, keys, dal.EncryptRecordsOf("users", User{}))
+//
+// Operations on collections that are not configured fail with ErrEncryptionNotConfigured,
+// as well as writes of records with tagged fields that are not configured for their collection,
+// so sensitive values are never written in plaintext by mistake.
+//
+// Encrypted fields should be strings or byte slices (or pointers to them).
+// Values are encrypted in a copy of records data before writes, so data of records is not modified,
+// and are decrypted in place after reads.
+//
+// An encrypted value keeps ID of its key (see EncryptionKeyProvider), so keys can be rotated:
+// new values are encrypted by the current key, existing values are re-encrypted when records are written again.
+// Values that are not encrypted fail reads unless AllowPlaintextValues() is passed.
+//
+// Randomly encrypted values are bound to keys of their records, so they can't be copied to other records.
+// Records with such fields should have complete keys on insert or IDs generated by WithRandomID().
+// Deterministically encrypted values are bound to a collection as they should be equal across records.
+//
+// Queries with conditions on encrypted fields fail with ErrEncryptedFieldCondition,
+// except equality (==, In) conditions on deterministically encrypted fields that are encrypted by the current key.
+// Note that records encrypted by previous keys are not matched by such conditions until they are re-encrypted.
+func NewDBWithEncryption(db DB, keys EncryptionKeyProvider, options ...EncryptionOption) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	if keys == nil {
+		panic("keys is a required parameter, got nil")
+	}
+	v := dbWithEncryption{DB: db, keys: keys, fields: encryptedFields{}}
+	for _, option := range options {
+		option(&v)
+	}
+	return v
+}
+
+type dbWithEncryption struct {
+	DB
+	keys           EncryptionKeyProvider
+	fields         encryptedFields
+	allowPlaintext bool
+}
+
+// encryptedFields keeps paths of encrypted fields by collections, it is not modified after a DB is created
+type encryptedFields map[string]map[string]bool // collection => path => deterministic
+
+func (f encryptedFields) addCollection(collection string) {
+	if _, ok := f[collection]; !ok {
+		f[collection] = make(map[string]bool)
+	}
+}
+
+func (f encryptedFields) add(collection, path string, deterministic bool) {
+	if previous, ok := f[collection][path]; ok && previous != deterministic {
+		panic(fmt.Sprintf("field %s of collection %s is configured both as encrypted randomly & deterministically", path, collection))
+	}
+	f[collection][path] = deterministic
+}
+
+// get returns encrypted fields of a collection or ErrEncryptionNotConfigured
+func (f encryptedFields) get(collection string) (map[string]bool, error) {
+	fields, ok := f[collection]
+	if !ok {
+		return nil, fmt.Errorf("%w for collection %q", ErrEncryptionNotConfigured, collection)
+	}
+	return fields, nil
+}
+
+// forRecord returns encrypted fields of a record & checks that fields tagged in its data type are configured
+func (f encryptedFields) forRecord(record Record) (map[string]bool, error) {
+	collection := record.Key().Collection()
+	fields, err := f.get(collection)
+	if err != nil {
+		return nil, err
+	}
+	if t := recordDataType(record); t != nil && t.Kind() == reflect.Struct {
+		for path, deterministic := range taggedEncryptedFields(t) {
+			if configured, ok := fields[path]; !ok || configured != deterministic {
+				return nil, fmt.Errorf("%w: field %s of %v tagged for encryption is not configured the same way for collection %q, see EncryptRecordsOf()",
+					ErrEncryptionNotConfigured, path, t, collection)
+			}
+		}
+	}
+	return fields, nil
+}
+
+var encryptedFieldsByType sync.Map // reflect.Type => map[string]bool
+
+// taggedEncryptedFields returns paths of struct fields tagged as `encrypted` or `deterministic`, including nested structs
+func taggedEncryptedFields(t reflect.Type) map[string]bool {
+	if cached, ok := encryptedFieldsByType.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+	fields := make(map[string]bool)
+	collectTaggedEncryptedFields(t, "", fields, map[reflect.Type]bool{})
+	cached, _ := encryptedFieldsByType.LoadOrStore(t, fields)
+	return cached.(map[string]bool)
+}
+
+func collectTaggedEncryptedFields(t reflect.Type, prefix string, fields map[string]bool, visited map[reflect.Type]bool) {
+	if visited[t] {
+		return // recursive types
+	}
+	visited[t] = true
+	defer delete(visited, t)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, options := parseDalgoTag(field.Tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		switch {
+		case slices.Contains(options, "deterministic"):
+			fields[prefix+name] = true
+		case slices.Contains(options, "encrypted"):
+			fields[prefix+name] = false
+		default:
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+				collectTaggedEncryptedFields(ft, prefix+name+".", fields, visited)
+			}
+		}
+	}
+}
+
+type fieldTransform = func(path string, deterministic bool, value string) (string, error)
+
+// sortedPaths returns paths in a stable order, so errors are reproducible
+func sortedPaths(paths map[string]bool) []string {
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// transformFields replaces values of fields of record data in place, missing & nil fields are skipped
+func transformFields(data any, paths map[string]bool, transform fieldTransform) error {
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	root := reflect.ValueOf(data)
+	for _, path := range sortedPaths(paths) {
+		target, found, err := fieldTarget(root, strings.Split(path, "."), false)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		if found {
+			if err = transformField(target, path, paths[path], transform); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// transformedCopy returns a copy of record data with transformed values of fields,
+// containers on paths to the fields are copied, so the data is not modified
+func transformedCopy(data any, paths map[string]bool, transform fieldTransform) (any, error) {
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	if data == nil {
+		return nil, nil
+	}
+	root := shallowCopy(reflect.ValueOf(data))
+	for _, path := range sortedPaths(paths) {
+		target, found, err := fieldTarget(root, strings.Split(path, "."), true)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", path, err)
+		}
+		if found {
+			if err = transformField(target, path, paths[path], transform); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return root.Interface(), nil
+}
+
+func transformField(target fieldRef, path string, deterministic bool, transform fieldTransform) error {
+	original, isBytes, ok := target.get()
+	if !ok {
+		return fmt.Errorf("%w: encrypted field %s should be a string or a byte slice, got %v", ErrNotSupported, path, target.typeName())
+	}
+	if target.isNil() {
+		return nil
+	}
+	transformed, err := transform(path, deterministic, original)
+	if err != nil {
+		return err
+	}
+	target.set(transformed, isBytes)
+	return nil
+}
+
+// shallowCopy copies a struct, a map (not its items) or a value referenced by a pointer or an interface.
+// A copy of a struct is addressable.
+func shallowCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(shallowCopy(v.Elem()))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(shallowCopy(v.Elem()))
+		return i
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return m
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		return s
+	default:
+		return v
+	}
+}
+
+// fieldRef is a settable reference to a struct field or a map item
+type fieldRef struct {
+	value reflect.Value // value of a struct field
+	m     reflect.Value // map if the field is a map item
+	key   reflect.Value
+}
+
+func (f fieldRef) current() reflect.Value {
+	if f.m.IsValid() {
+		return f.m.MapIndex(f.key)
+	}
+	return f.value
+}
+
+func (f fieldRef) isNil() bool {
+	v := f.current()
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Slice && v.IsNil()
+}
+
+func (f fieldRef) typeName() string {
+	return fmt.Sprint(indirectReflectValue(f.current()).Type())
+}
+
+func (f fieldRef) get() (s string, isBytes bool, ok bool) {
+	if f.isNil() {
+		return "", false, true
+	}
+	return stringOrBytes(f.current())
+}
+
+// stringOrBytes returns a value of a string or a byte slice (or a pointer to them)
+func stringOrBytes(v reflect.Value) (s string, isBytes bool, ok bool) {
+	v = indirectReflectValue(v)
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String(), false, true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return string(v.Bytes()), true, true
+	default:
+		return "", false, false
+	}
+}
+
+func (f fieldRef) set(s string, isBytes bool) {
+	if f.m.IsValid() {
+		var item any = s
+		if isBytes {
+			item = []byte(s)
+		}
+		f.m.SetMapIndex(f.key, reflect.ValueOf(item))
+		return
+	}
+	v := f.value
+	if v.Kind() == reflect.Interface {
+		if isBytes {
+			v.Set(reflect.ValueOf([]byte(s)))
+		} else {
+			v.Set(reflect.ValueOf(s))
+		}
+		return
+	}
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if isBytes {
+		v.SetBytes([]byte(s))
+	} else {
+		v.SetString(s)
+	}
+}
+
+var errFieldNotAddressable = errors.New("encrypted field is not addressable, pass a pointer")
+
+// fieldTarget finds a field by path in a struct or in a map with string keys.
+// With copyPath containers on the path are copied, so the value should be a copy (see shallowCopy).
+func fieldTarget(v reflect.Value, path []string, copyPath bool) (target fieldRef, found bool, err error) {
+	v = indirectReflectValue(v)
+	if !v.IsValid() {
+		return target, false, nil
+	}
+	name := path[0]
+	switch v.Kind() {
+	case reflect.Struct:
+		index, ok := structFieldIndex(v.Type(), name)
+		if !ok {
+			return target, false, nil
+		}
+		fv, err := v.FieldByIndexErr(index)
+		if err != nil {
+			return target, false, nil // nil embedded pointer
+		}
+		if !fv.CanSet() {
+			return target, false, errFieldNotAddressable
+		}
+		if copyPath {
+			fv.Set(shallowCopy(fv))
+		}
+		if len(path) == 1 {
+			return fieldRef{value: fv}, true, nil
+		}
+		if fv.Kind() == reflect.Interface && indirectReflectValue(fv).Kind() == reflect.Struct && fv.Elem().Kind() != reflect.Pointer {
+			return target, false, errFieldNotAddressable
+		}
+		return fieldTarget(fv, path[1:], copyPath)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return target, false, nil
+		}
+		key := reflect.ValueOf(name).Convert(v.Type().Key())
+		item := v.MapIndex(key)
+		if !item.IsValid() {
+			return target, false, nil
+		}
+		if len(path) == 1 {
+			return fieldRef{m: v, key: key}, true, nil
+		}
+		if item.Kind() == reflect.Struct || item.Kind() == reflect.Interface && indirectReflectValue(item).Kind() == reflect.Struct && item.Elem().Kind() != reflect.Pointer {
+			return target, false, errFieldNotAddressable // a map item holds a copy of a struct
+		}
+		if copyPath {
+			item = shallowCopy(item)
+			v.SetMapIndex(key, item)
+		}
+		return fieldTarget(item, path[1:], copyPath)
+	default:
+		return target, false, nil
+	}
+}
+
+func (v dbWithEncryption) encryptFunc(ctx context.Context, key *Key) fieldTransform {
+	return func(path string, deterministic bool, value string) (string, error) {
+		return encryptValue(ctx, v.keys, key, path, []byte(value), deterministic)
+	}
+}
+
+func (v dbWithEncryption) decryptFunc(ctx context.Context, key *Key) fieldTransform {
+	return func(path string, _ bool, value string) (string, error) {
+		if v.allowPlaintext && !IsEncryptedValue(value) {
+			return value, nil // not encrypted yet, see AllowPlaintextValues()
+		}
+		plaintext, err := decryptValue(ctx, v.keys, key, path, value)
+		return string(plaintext), err
+	}
+}
+
+// recordWithData overrides data of a record, all other methods are delegated to the record
+type recordWithData struct {
+	Record
+	data any
+}
+
+func (r recordWithData) Data() any {
+	return r.data
+}
+
+func (r recordWithData) SetError(err error) Record {
+	r.Record.SetError(err)
+	return r
+}
+
+func hasRandomizedFields(fields map[string]bool) bool {
+	for _, deterministic := range fields {
+		if !deterministic {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptRecords returns records with encrypted copies of data, data of original records is not modified
+func (v dbWithEncryption) encryptRecords(ctx context.Context, records []Record) ([]Record, error) {
+	encrypted := make([]Record, len(records))
+	for i, record := range records {
+		fields, err := v.fields.forRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			encrypted[i] = record
+			continue
+		}
+		if record.Key().ID == nil && hasRandomizedFields(fields) {
+			return nil, fmt.Errorf("%w: encryption of fields of record with incomplete key %v, use WithRandomID() to generate IDs",
+				ErrNotSupported, record.Key())
+		}
+		data, err := transformedCopy(recordDataOrNil(record), fields, v.encryptFunc(ctx, record.Key()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt fields of record %v: %w", record.Key(), err)
+		}
+		encrypted[i] = recordWithData{Record: record, data: data}
+	}
+	return encrypted, nil
+}
+
+// decryptRecords decrypts data of retrieved records in place
+func (v dbWithEncryption) decryptRecords(ctx context.Context, records ...Record) error {
+	for _, record := range records {
+		if exists, err := recordStatus(record); err != nil || !exists {
+			continue
+		}
+		fields, err := v.fields.forRecord(record)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if err = transformFields(recordDataOrNil(record), fields, v.decryptFunc(ctx, record.Key())); err != nil {
+			return fmt.Errorf("failed to decrypt fields of record %v: %w", record.Key(), err)
+		}
+	}
+	return nil
+}
+
+// encryptUpdates returns a copy of updates with values of encrypted fields encrypted
+func (v dbWithEncryption) encryptUpdates(ctx context.Context, key *Key, updates []Update) ([]Update, error) {
+	fields, err := v.fields.get(key.Collection())
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return updates, nil
+	}
+	encrypted := make([]Update, len(updates))
+	for i, u := range updates {
+		encrypted[i] = u
+		path := u.Field
+		if path == "" {
+			path = strings.Join(u.FieldPath, ".")
+		}
+		for field, deterministic := range fields {
+			switch {
+			case field == path:
+				if u.Value == DeleteField || !indirectValue(u.Value).IsValid() {
+					continue
+				}
+				value, isBytes, ok := stringOrBytes(reflect.ValueOf(u.Value))
+				if !ok {
+					return nil, fmt.Errorf("%w: encrypted field %s should be a string or a byte slice, got %T", ErrNotSupported, field, u.Value)
+				}
+				ciphertext, err := encryptValue(ctx, v.keys, key, field, []byte(value), deterministic)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encrypt update of record %v: %w", key, err)
+				}
+				if isBytes {
+					encrypted[i].Value = []byte(ciphertext)
+				} else {
+					encrypted[i].Value = ciphertext
+				}
+			case strings.HasPrefix(field, path+"."):
+				if u.Value != DeleteField && u.Value != nil {
+					return nil, fmt.Errorf("%w: update of field %s of record %v that contains encrypted field %s", ErrNotSupported, path, key, field)
+				}
+			}
+		}
+	}
+	return encrypted, nil
+}
+
+// encryptQuery encrypts values of equality conditions on deterministically encrypted fields
+// & rejects other conditions on encrypted fields
+func (v dbWithEncryption) encryptQuery(ctx context.Context, query Query) (Query, error) {
+	from := query.From()
+	if from == nil {
+		return nil, fmt.Errorf("%w for a query without a collection", ErrEncryptionNotConfigured)
+	}
+	fields, err := v.fields.get(from.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || query.Where() == nil {
+		return query, nil
+	}
+	where, err := v.encryptCondition(ctx, &Key{collection: from.Name}, fields, query.Where())
+	if err != nil {
+		return nil, err
+	}
+	if q, ok := query.(theQuery); ok {
+		q.where = where
+		return q, nil
+	}
+	return queryWithWhere{Query: query, where: where}, nil
+}
+
+// encryptCondition encrypts values of conditions, deterministic ciphertexts depend on a collection of a key only
+func (v dbWithEncryption) encryptCondition(ctx context.Context, key *Key, fields map[string]bool, condition Condition) (Condition, error) {
+	switch c := condition.(type) {
+	case GroupCondition:
+		conditions := make([]Condition, len(c.conditions))
+		for i, child := range c.conditions {
+			var err error
+			if conditions[i], err = v.encryptCondition(ctx, key, fields, child); err != nil {
+				return nil, err
+			}
+		}
+		return GroupCondition{operator: c.operator, conditions: conditions}, nil
+	case Comparison:
+		field, ok := c.Left.(FieldRef)
+		if !ok {
+			return c, nil
+		}
+		deterministic, encrypted := fields[field.Name]
+		if !encrypted {
+			return c, nil
+		}
+		if !deterministic || c.Operator != Equal && c.Operator != In {
+			return nil, fmt.Errorf("%w: %v", ErrEncryptedFieldCondition, c)
+		}
+		right, err := v.encryptExpression(ctx, key, field.Name, c.Right)
+		if err != nil {
+			return nil, err
+		}
+		return Comparison{Operator: c.Operator, Left: c.Left, Right: right}, nil
+	default:
+		return condition, nil
+	}
+}
+
+func (v dbWithEncryption) encryptExpression(ctx context.Context, key *Key, path string, expression Expression) (Expression, error) {
+	constant, ok := expression.(Constant)
+	if !ok {
+		return nil, fmt.Errorf("%w: expression %v", ErrEncryptedFieldCondition, expression)
+	}
+	encrypt := func(s string) (string, error) {
+		return encryptValue(ctx, v.keys, key, path, []byte(s), true)
+	}
+	switch value := constant.Value.(type) {
+	case string:
+		encrypted, err := encrypt(value)
+		return Constant{Value: encrypted}, err
+	case []byte:
+		encrypted, err := encrypt(string(value))
+		return Constant{Value: []byte(encrypted)}, err
+	case []string: // values of In operator
+		values := make([]string, len(value))
+		for i, item := range value {
+			var err error
+			if values[i], err = encrypt(item); err != nil {
+				return nil, err
+			}
+		}
+		return Constant{Value: values}, nil
+	case []any: // values of In operator
+		values := make([]any, len(value))
+		for i, item := range value {
+			s, isString := item.(string)
+			if !isString {
+				return nil, fmt.Errorf("%w: value of type %T for encrypted field %s", ErrEncryptedFieldCondition, item, path)
+			}
+			var err error
+			if values[i], err = encrypt(s); err != nil {
+				return nil, err
+			}
+		}
+		return Constant{Value: values}, nil
+	default:
+		return nil, fmt.Errorf("%w: value of type %T for encrypted field %s", ErrEncryptedFieldCondition, value, path)
+	}
+}
+
+// checkConfigured fails before reads of records of collections that are not configured
+func (v dbWithEncryption) checkConfigured(records ...Record) error {
+	for _, record := range records {
+		if _, err := v.fields.get(record.Key().Collection()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v dbWithEncryption) get(ctx context.Context, session Getter, record Record) error {
+	if err := v.checkConfigured(record); err != nil {
+		return err
+	}
+	if err := session.Get(ctx, record); err != nil {
+		return err
+	}
+	return v.decryptRecords(ctx, record)
+}
+
+func (v dbWithEncryption) getMulti(ctx context.Context, session MultiGetter, records []Record) error {
+	if err := v.checkConfigured(records...); err != nil {
+		return err
+	}
+	if err := session.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	return v.decryptRecords(ctx, records...)
+}
+
+func (v dbWithEncryption) queryReader(ctx context.Context, session QueryExecutor, query Query) (Reader, error) {
+	query, err := v.encryptQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := session.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return afterLoadReader{Reader: reader, afterLoad: func(record Record) error {
+		return v.decryptRecords(ctx, record)
+	}}, nil
+}
+
+func (v dbWithEncryption) Get(ctx context.Context, record Record) error {
+	return v.get(ctx, v.DB, record)
+}
+
+func (v dbWithEncryption) GetMulti(ctx context.Context, records []Record) error {
+	return v.getMulti(ctx, v.DB, records)
+}
+
+func (v dbWithEncryption) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return v.queryReader(ctx, v.DB, query)
+}
+
+func (v dbWithEncryption) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := v.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (v dbWithEncryption) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return f(ctx, readTransactionWithEncryption{ReadTransaction: tx, db: v})
+	}, options...)
+}
+
+func (v dbWithEncryption) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return f(ctx, readwriteTransactionWithEncryption{ReadwriteTransaction: tx, db: v})
+	}, options...)
+}
+
+type readTransactionWithEncryption struct {
+	ReadTransaction
+	db dbWithEncryption
+}
+
+func (tx readTransactionWithEncryption) Get(ctx context.Context, record Record) error {
+	return tx.db.get(ctx, tx.ReadTransaction, record)
+}
+
+func (tx readTransactionWithEncryption) GetMulti(ctx context.Context, records []Record) error {
+	return tx.db.getMulti(ctx, tx.ReadTransaction, records)
+}
+
+func (tx readTransactionWithEncryption) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.db.queryReader(ctx, tx.ReadTransaction, query)
+}
+
+func (tx readTransactionWithEncryption) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+type readwriteTransactionWithEncryption struct {
+	ReadwriteTransaction
+	db dbWithEncryption
+}
+
+func (tx readwriteTransactionWithEncryption) Get(ctx context.Context, record Record) error {
+	return tx.db.get(ctx, tx.ReadwriteTransaction, record)
+}
+
+func (tx readwriteTransactionWithEncryption) GetMulti(ctx context.Context, records []Record) error {
+	return tx.db.getMulti(ctx, tx.ReadwriteTransaction, records)
+}
+
+func (tx readwriteTransactionWithEncryption) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.db.queryReader(ctx, tx.ReadwriteTransaction, query)
+}
+
+func (tx readwriteTransactionWithEncryption) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (tx readwriteTransactionWithEncryption) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	if NewInsertOptions(opts...).IDGenerator() != nil { // IDs are needed to encrypt values
+		return NewRandomIDInserter(tx).Insert(ctx, record, opts...)
+	}
+	encrypted, err := tx.db.encryptRecords(ctx, []Record{record})
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.Insert(ctx, encrypted[0], opts...)
+}
+
+func (tx readwriteTransactionWithEncryption) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	if NewInsertOptions(opts...).IDGenerator() != nil { // IDs are needed to encrypt values
+		return NewRandomIDInserter(tx).InsertMulti(ctx, records, opts...)
+	}
+	encrypted, err := tx.db.encryptRecords(ctx, records)
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.InsertMulti(ctx, encrypted, opts...)
+}
+
+func (tx readwriteTransactionWithEncryption) Set(ctx context.Context, record Record) error {
+	encrypted, err := tx.db.encryptRecords(ctx, []Record{record})
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.Set(ctx, encrypted[0])
+}
+
+func (tx readwriteTransactionWithEncryption) SetMulti(ctx context.Context, records []Record) error {
+	encrypted, err := tx.db.encryptRecords(ctx, records)
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.SetMulti(ctx, encrypted)
+}
+
+func (tx readwriteTransactionWithEncryption) Update(ctx context.Context, key *Key, updates []Update, preconditions ...Precondition) error {
+	updates, err := tx.db.encryptUpdates(ctx, key, updates)
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.Update(ctx, key, updates, preconditions...)
+}
+
+// UpdateMulti encrypts updates of encrypted fields. With randomized encryption records get different ciphertexts,
+// so in such a case records are updated one by one.
+func (tx readwriteTransactionWithEncryption) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) error {
+	if len(keys) == 0 {
+		return tx.ReadwriteTransaction.UpdateMulti(ctx, keys, updates, preconditions...)
+	}
+	encrypted := make([][]Update, len(keys))
+	same := true
+	for i, key := range keys {
+		var err error
+		if encrypted[i], err = tx.db.encryptUpdates(ctx, key, updates); err != nil {
+			return err
+		}
+		same = same && reflect.DeepEqual(encrypted[i], encrypted[0])
+	}
+	if same {
+		return tx.ReadwriteTransaction.UpdateMulti(ctx, keys, encrypted[0], preconditions...)
+	}
+	for i, key := range keys {
+		if err := tx.ReadwriteTransaction.Update(ctx, key, encrypted[i], preconditions...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package dal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEncryptedAddress struct {
+	Street string `json:"street" dalgo:"street,encrypted"`
+	City   string `json:"city" dalgo:"city"`
+}
+
+type testEncryptedUser struct {
+	Name    string                `json:"name" dalgo:"name"`
+	Email   string                `json:"email" dalgo:"email,deterministic"`
+	Phone   *string               `json:"phone" dalgo:"phone,encrypted"`
+	Secret  []byte                `json:"secret" dalgo:"secret,encrypted"`
+	Address *testEncryptedAddress `json:"address" dalgo:"address"`
+}
+
+func TestNewDBWithEncryption(t *testing.T) {
+	keys := newTestEncryptionKeys("k1")
+	assert.Panics(t, func() {
+		NewDBWithEncryption(nil, keys)
+	})
+	assert.Panics(t, func() {
+		NewDBWithEncryption(newMemoryDB(), nil)
+	})
+	assert.Panics(t, func() {
+		EncryptFields("", "a")
+	})
+	assert.Panics(t, func() {
+		EncryptFields("users")
+	})
+	assert.Panics(t, func() {
+		NewDBWithEncryption(newMemoryDB(), keys, EncryptFields("users", "a"), EncryptFieldsDeterministically("users", "a"))
+	})
+	assert.Panics(t, func() {
+		EncryptRecordsOf("users", "not a struct")
+	})
+	assert.Panics(t, func() {
+		PlaintextCollections()
+	})
+}
+
+func Test_taggedEncryptedFields(t *testing.T) {
+	assert.Equal(t, map[string]bool{
+		"email":          true,
+		"phone":          false,
+		"secret":         false,
+		"address.street": false,
+	}, taggedEncryptedFields(reflect.TypeOf(testEncryptedUser{})))
+}
+
+func TestDBWithEncryption(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), EncryptRecordsOf("users", testEncryptedUser{}), EncryptFields("notes", "text"))
+	u1 := NewKeyWithID("users", "u1")
+	phone := "+353"
+	user := &testEncryptedUser{
+		Name:    "Ann",
+		Email:   "ann@example.com",
+		Phone:   &phone,
+		Secret:  []byte("s3cr3t"),
+		Address: &testEncryptedAddress{Street: "Main st", City: "Dublin"},
+	}
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		if err := tx.Set(ctx, NewRecordWithData(u1, user)); err != nil {
+			return err
+		}
+		return tx.Insert(ctx, NewRecordWithData(NewKeyWithID("notes", "n1"), map[string]any{"text": "hello", "tag": "x"}))
+	})
+	assert.Nil(t, err)
+
+	// Data of records is restored after writes
+	assert.Equal(t, "ann@example.com", user.Email)
+	assert.Equal(t, "+353", phone)
+	assert.Equal(t, "s3cr3t", string(user.Secret))
+	assert.Equal(t, "Main st", user.Address.Street)
+
+	stored := memDB.getData(u1)
+	assert.Equal(t, "Ann", stored["name"])
+	assert.True(t, strings.HasPrefix(stored["email"].(string), "dalgo:denc:k1:"))
+	assert.True(t, strings.HasPrefix(stored["phone"].(string), "dalgo:enc:k1:"))
+	assert.NotContains(t, stored["secret"], "s3cr3t")
+	assert.True(t, strings.HasPrefix(stored["address"].(map[string]any)["street"].(string), "dalgo:enc:k1:"))
+	assert.Equal(t, "Dublin", stored["address"].(map[string]any)["city"])
+	note := memDB.getData(NewKeyWithID("notes", "n1"))
+	assert.True(t, IsEncryptedValue(note["text"].(string)))
+	assert.Equal(t, "x", note["tag"])
+
+	t.Run("Get", func(t *testing.T) {
+		record := NewRecordWithData(u1, new(testEncryptedUser))
+		assert.Nil(t, db.Get(ctx, record))
+		loaded := record.Data().(*testEncryptedUser)
+		assert.Equal(t, user.Email, loaded.Email)
+		assert.Equal(t, "+353", *loaded.Phone)
+		assert.Equal(t, "s3cr3t", string(loaded.Secret))
+		assert.Equal(t, "Main st", loaded.Address.Street)
+
+		notes := []Record{NewRecordWithData(NewKeyWithID("notes", "n1"), new(map[string]any))}
+		assert.Nil(t, db.GetMulti(ctx, notes))
+		assert.Equal(t, "hello", (*notes[0].Data().(*map[string]any))["text"])
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Update(ctx, u1, []Update{
+				{Field: "email", Value: "anna@example.com"},
+				{Field: "name", Value: "Anna"},
+			})
+		})
+		assert.Nil(t, err)
+		stored := memDB.getData(u1)
+		assert.Equal(t, "Anna", stored["name"])
+		assert.True(t, strings.HasPrefix(stored["email"].(string), "dalgo:denc:k1:"))
+
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Update(ctx, u1, []Update{{Field: "address", Value: map[string]any{"street": "plain"}}})
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+	})
+
+	t.Run("query_conditions", func(t *testing.T) {
+		reader, err := db.QueryReader(ctx, From("users").WhereField("email", Equal, "anna@example.com").SelectKeysOnly(reflect.String))
+		assert.Nil(t, err)
+		assert.NotNil(t, reader)
+
+		v := db.(dbWithEncryption)
+		query, err := v.encryptQuery(ctx, From("users").
+			WhereField("email", Equal, "anna@example.com").
+			WhereField("name", Equal, "Anna").
+			SelectKeysOnly(reflect.String))
+		assert.Nil(t, err)
+		conditions := query.Where().(GroupCondition).Conditions()
+		assert.Equal(t, memDB.getData(u1)["email"], conditions[0].(Comparison).Right.(Constant).Value)
+		assert.Equal(t, "Anna", conditions[1].(Comparison).Right.(Constant).Value)
+
+		_, err = db.QueryReader(ctx, From("users").WhereField("phone", Equal, "+353").SelectKeysOnly(reflect.String))
+		assert.ErrorIs(t, err, ErrEncryptedFieldCondition)
+		_, err = db.QueryReader(ctx, From("users").WhereField("email", GreaterThen, "a").SelectKeysOnly(reflect.String))
+		assert.ErrorIs(t, err, ErrEncryptedFieldCondition)
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		rotated := NewDBWithEncryption(memDB, newTestEncryptionKeys("k2"), EncryptRecordsOf("users", &testEncryptedUser{}))
+		record := NewRecordWithData(u1, new(testEncryptedUser))
+		err := rotated.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Get(ctx, record); err != nil {
+				return err
+			}
+			return tx.Set(ctx, record)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "anna@example.com", record.Data().(*testEncryptedUser).Email)
+		assert.True(t, strings.HasPrefix(memDB.getData(u1)["phone"].(string), "dalgo:enc:k2:"))
+	})
+
+	t.Run("not_string", func(t *testing.T) {
+		db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), EncryptFields("counters", "count"))
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Set(ctx, NewRecordWithData(NewKeyWithID("counters", "c1"), map[string]any{"count": 1}))
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+	})
+}
+
+func TestDBWithEncryption_NotConfigured(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), PlaintextCollections("notes"))
+	u1 := NewKeyWithID("users", "u1")
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Update(ctx, u1, []Update{{Field: "email", Value: "ann@example.com"}})
+	})
+	assert.ErrorIs(t, err, ErrEncryptionNotConfigured)
+	_, err = db.QueryReader(ctx, From("users").WhereField("phone", Equal, "+353").SelectKeysOnly(reflect.String))
+	assert.ErrorIs(t, err, ErrEncryptionNotConfigured)
+	assert.ErrorIs(t, db.Get(ctx, NewRecordWithData(u1, new(testEncryptedUser))), ErrEncryptionNotConfigured)
+
+	// a plaintext collection can't hold records of a type with tagged fields
+	err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, NewRecordWithData(NewKeyWithID("notes", "n1"), &testEncryptedUser{Email: "ann@example.com"}))
+	})
+	assert.ErrorIs(t, err, ErrEncryptionNotConfigured)
+	assert.Nil(t, memDB.getData(NewKeyWithID("notes", "n1")))
+
+	err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, NewRecordWithData(NewKeyWithID("notes", "n1"), map[string]any{"text": "hello"}))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", memDB.getData(NewKeyWithID("notes", "n1"))["text"])
+}
+
+func TestDBWithEncryption_UpdateBeforeWrites(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	u1 := NewKeyWithID("users", "u1")
+	memDB.putData(u1, map[string]any{"name": "Ann"})
+	db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), EncryptRecordsOf("users", testEncryptedUser{}))
+
+	// no records have been read or written yet, fields are known from the configured type
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Update(ctx, u1, []Update{{Field: "address.street", Value: "Main st"}})
+	})
+	assert.Nil(t, err)
+	assert.True(t, IsEncryptedValue(memDB.getData(u1)["address"].(map[string]any)["street"].(string)))
+
+	_, err = db.QueryReader(ctx, From("users").WhereField("phone", Equal, "+353").SelectKeysOnly(reflect.String))
+	assert.ErrorIs(t, err, ErrEncryptedFieldCondition)
+}
+
+func TestDBWithEncryption_DataIsNotModified(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"),
+		EncryptRecordsOf("users", testEncryptedUser{}),
+		EncryptFields("docs", "title", "body", "meta.secret", "item.value"),
+	)
+
+	t.Run("struct_by_value", func(t *testing.T) {
+		u1 := NewKeyWithID("users", "u1")
+		user := testEncryptedUser{Email: "ann@example.com", Address: &testEncryptedAddress{Street: "Main st"}}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Set(ctx, NewRecordWithData(u1, user))
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "ann@example.com", user.Email)
+		assert.Equal(t, "Main st", user.Address.Street)
+		assert.True(t, IsEncryptedValue(memDB.getData(u1)["email"].(string)))
+		assert.True(t, IsEncryptedValue(memDB.getData(u1)["address"].(map[string]any)["street"].(string)))
+	})
+
+	t.Run("error_midway", func(t *testing.T) {
+		meta := map[string]any{"secret": "s3cr3t"}
+		data := map[string]any{"title": "hello", "body": 1, "meta": meta}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Insert(ctx, NewRecordWithData(NewKeyWithID("docs", "d1"), data))
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+		assert.Equal(t, map[string]any{"title": "hello", "body": 1, "meta": map[string]any{"secret": "s3cr3t"}}, data)
+
+		data["body"] = "text"
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Insert(ctx, NewRecordWithData(NewKeyWithID("docs", "d1"), data))
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "s3cr3t", meta["secret"])
+		assert.Equal(t, "text", data["body"])
+		stored := memDB.getData(NewKeyWithID("docs", "d1"))
+		assert.True(t, IsEncryptedValue(stored["meta"].(map[string]any)["secret"].(string)))
+	})
+
+	t.Run("not_addressable", func(t *testing.T) {
+		data := map[string]any{"item": struct{ Value string }{Value: "v"}}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Set(ctx, NewRecordWithData(NewKeyWithID("docs", "d2"), data))
+		})
+		assert.ErrorIs(t, err, errFieldNotAddressable)
+		assert.Nil(t, memDB.getData(NewKeyWithID("docs", "d2")))
+	})
+}
+
+func TestDBWithEncryption_boundToRecords(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), EncryptFields("notes", "text"))
+	n1, n2 := NewKeyWithID("notes", "n1"), NewKeyWithID("notes", "n2")
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, NewRecordWithData(n1, map[string]any{"text": "hello"}))
+	})
+	assert.Nil(t, err)
+
+	t.Run("copied_value", func(t *testing.T) {
+		memDB.putData(n2, memDB.getData(n1))
+		err := db.Get(ctx, NewRecordWithData(n2, new(map[string]any)))
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("plaintext_value", func(t *testing.T) {
+		memDB.putData(n2, map[string]any{"text": "plain"})
+		err := db.Get(ctx, NewRecordWithData(n2, new(map[string]any)))
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+		migrating := NewDBWithEncryption(memDB, newTestEncryptionKeys("k1"), EncryptFields("notes", "text"), AllowPlaintextValues())
+		record := NewRecordWithData(n2, new(map[string]any))
+		assert.Nil(t, migrating.Get(ctx, record))
+		assert.Equal(t, "plain", (*record.Data().(*map[string]any))["text"])
+	})
+
+	t.Run("incomplete_key", func(t *testing.T) {
+		record := NewRecordWithData(NewIncompleteKey("notes", reflect.String, nil), map[string]any{"text": "hi"})
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Insert(ctx, record)
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+
+		generateID := func(ctx context.Context, record Record) error {
+			record.Key().ID = "n3"
+			return nil
+		}
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Insert(ctx, record, WithRandomID(generateID, 1))
+		})
+		assert.Nil(t, err)
+		loaded := NewRecordWithData(NewKeyWithID("notes", "n3"), new(map[string]any))
+		assert.Nil(t, db.Get(ctx, loaded))
+		assert.Equal(t, "hi", (*loaded.Data().(*map[string]any))["text"])
+	})
+}
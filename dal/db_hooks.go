@@ -0,0 +1,322 @@
+package dal
+
+import (
+	"context"
+	"sync"
+)
+
+// NewDBWithHooks wraps a DB so hooks of the registry are called automatically:
+//   - before & after insert, set, update and delete operations of readwrite transactions;
+//   - after load of records by Get, GetMulti & queries (both on DB level and within transactions);
+//   - after commit of a readwrite transaction.
+//
+// Before insert & set it also calls BeforeSave() and after load it calls AfterLoad(),
+// so hooks & validation defined by record data itself are invoked as well.
+func NewDBWithHooks(db DB, hooks *HookRegistry) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	if hooks == nil {
+		panic("hooks is a required parameter, got nil")
+	}
+	return dbWithHooks{DB: db, hooks: hooks}
+}
+
+type dbWithHooks struct {
+	DB
+	hooks *HookRegistry
+}
+
+func (v dbWithHooks) Get(ctx context.Context, record Record) error {
+	if err := v.DB.Get(ctx, record); err != nil {
+		return err
+	}
+	return v.afterLoad(ctx, record)
+}
+
+func (v dbWithHooks) GetMulti(ctx context.Context, records []Record) error {
+	if err := v.DB.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	return v.afterLoad(ctx, records...)
+}
+
+func (v dbWithHooks) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	reader, err := v.DB.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return v.newAfterLoadReader(ctx, reader), nil
+}
+
+func (v dbWithHooks) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := v.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (v dbWithHooks) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return f(ctx, readTransactionWithHooks{ReadTransaction: tx, db: v})
+	}, options...)
+}
+
+func (v dbWithHooks) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	var txs []*readwriteTransactionWithHooks // a worker can be called multiple times if a transaction is retried
+	err := v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		txWithHooks := &readwriteTransactionWithHooks{ReadwriteTransaction: tx, db: v}
+		txs = append(txs, txWithHooks)
+		return f(ctx, txWithHooks)
+	}, options...)
+	if err != nil || len(txs) == 0 {
+		return err
+	}
+	return v.hooks.callCommitHooks(ctx, txs[len(txs)-1].changed)
+}
+
+func (v dbWithHooks) afterLoad(ctx context.Context, records ...Record) error {
+	for _, record := range records {
+		if exists, err := recordStatus(record); err != nil || !exists {
+			continue
+		}
+		if err := AfterLoad(ctx, v.DB, record); err != nil {
+			return err
+		}
+		if err := v.hooks.callRecordHooks(ctx, afterLoadEvent, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v dbWithHooks) newAfterLoadReader(ctx context.Context, reader Reader) Reader {
+	return afterLoadReader{Reader: reader, afterLoad: func(record Record) error {
+		return v.afterLoad(ctx, record)
+	}}
+}
+
+type afterLoadReader struct {
+	Reader
+	afterLoad func(record Record) error
+}
+
+func (r afterLoadReader) Next() (Record, error) {
+	record, err := r.Reader.Next()
+	if err != nil {
+		return record, err
+	}
+	if err = r.afterLoad(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+type readTransactionWithHooks struct {
+	ReadTransaction
+	db dbWithHooks
+}
+
+func (tx readTransactionWithHooks) Get(ctx context.Context, record Record) error {
+	if err := tx.ReadTransaction.Get(ctx, record); err != nil {
+		return err
+	}
+	return tx.db.afterLoad(ctx, record)
+}
+
+func (tx readTransactionWithHooks) GetMulti(ctx context.Context, records []Record) error {
+	if err := tx.ReadTransaction.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	return tx.db.afterLoad(ctx, records...)
+}
+
+func (tx readTransactionWithHooks) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	reader, err := tx.ReadTransaction.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.db.newAfterLoadReader(ctx, reader), nil
+}
+
+func (tx readTransactionWithHooks) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+type readwriteTransactionWithHooks struct {
+	ReadwriteTransaction
+	db      dbWithHooks
+	mutex   sync.Mutex
+	changed []changedRecord
+}
+
+func (tx *readwriteTransactionWithHooks) trackRecords(records ...Record) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	for _, record := range records {
+		tx.changed = append(tx.changed, changedRecord{key: record.Key(), dataType: recordDataType(record)})
+	}
+}
+
+func (tx *readwriteTransactionWithHooks) trackKeys(keys ...*Key) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	for _, key := range keys {
+		tx.changed = append(tx.changed, changedRecord{key: key})
+	}
+}
+
+func (tx *readwriteTransactionWithHooks) Get(ctx context.Context, record Record) error {
+	if err := tx.ReadwriteTransaction.Get(ctx, record); err != nil {
+		return err
+	}
+	return tx.db.afterLoad(ctx, record)
+}
+
+func (tx *readwriteTransactionWithHooks) GetMulti(ctx context.Context, records []Record) error {
+	if err := tx.ReadwriteTransaction.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	return tx.db.afterLoad(ctx, records...)
+}
+
+func (tx *readwriteTransactionWithHooks) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	reader, err := tx.ReadwriteTransaction.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.db.newAfterLoadReader(ctx, reader), nil
+}
+
+func (tx *readwriteTransactionWithHooks) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (tx *readwriteTransactionWithHooks) beforeSave(ctx context.Context, event recordHookEvent, records []Record) error {
+	for _, record := range records {
+		if err := BeforeSave(ctx, tx.db.DB, record); err != nil {
+			return err
+		}
+		if err := tx.db.hooks.callRecordHooks(ctx, event, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *readwriteTransactionWithHooks) afterSave(ctx context.Context, event recordHookEvent, records []Record) error {
+	tx.trackRecords(records...)
+	for _, record := range records {
+		if err := tx.db.hooks.callRecordHooks(ctx, event, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *readwriteTransactionWithHooks) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	records := []Record{record}
+	if err := tx.beforeSave(ctx, beforeInsertEvent, records); err != nil {
+		return err
+	}
+	if err := tx.ReadwriteTransaction.Insert(ctx, record, opts...); err != nil {
+		return err
+	}
+	return tx.afterSave(ctx, afterInsertEvent, records)
+}
+
+func (tx *readwriteTransactionWithHooks) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	if err := tx.beforeSave(ctx, beforeInsertEvent, records); err != nil {
+		return err
+	}
+	if err := tx.ReadwriteTransaction.InsertMulti(ctx, records, opts...); err != nil {
+		return err
+	}
+	return tx.afterSave(ctx, afterInsertEvent, records)
+}
+
+func (tx *readwriteTransactionWithHooks) Set(ctx context.Context, record Record) error {
+	records := []Record{record}
+	if err := tx.beforeSave(ctx, beforeSetEvent, records); err != nil {
+		return err
+	}
+	if err := tx.ReadwriteTransaction.Set(ctx, record); err != nil {
+		return err
+	}
+	return tx.afterSave(ctx, afterSetEvent, records)
+}
+
+func (tx *readwriteTransactionWithHooks) SetMulti(ctx context.Context, records []Record) error {
+	if err := tx.beforeSave(ctx, beforeSetEvent, records); err != nil {
+		return err
+	}
+	if err := tx.ReadwriteTransaction.SetMulti(ctx, records); err != nil {
+		return err
+	}
+	return tx.afterSave(ctx, afterSetEvent, records)
+}
+
+func (tx *readwriteTransactionWithHooks) Update(ctx context.Context, key *Key, updates []Update, preconditions ...Precondition) error {
+	return tx.UpdateMulti(ctx, []*Key{key}, updates, preconditions...)
+}
+
+func (tx *readwriteTransactionWithHooks) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) (err error) {
+	hooks := tx.db.hooks
+	for _, key := range keys {
+		if err = hooks.callUpdateHooks(ctx, &hooks.beforeUpdate, key, updates); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 1 {
+		err = tx.ReadwriteTransaction.Update(ctx, keys[0], updates, preconditions...)
+	} else {
+		err = tx.ReadwriteTransaction.UpdateMulti(ctx, keys, updates, preconditions...)
+	}
+	if err != nil {
+		return err
+	}
+	tx.trackKeys(keys...)
+	for _, key := range keys {
+		if err = hooks.callUpdateHooks(ctx, &hooks.afterUpdate, key, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *readwriteTransactionWithHooks) Delete(ctx context.Context, key *Key) error {
+	return tx.DeleteMulti(ctx, []*Key{key})
+}
+
+func (tx *readwriteTransactionWithHooks) DeleteMulti(ctx context.Context, keys []*Key) (err error) {
+	hooks := tx.db.hooks
+	for _, key := range keys {
+		if err = hooks.callKeyHooks(ctx, &hooks.beforeDelete, key); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 1 {
+		err = tx.ReadwriteTransaction.Delete(ctx, keys[0])
+	} else {
+		err = tx.ReadwriteTransaction.DeleteMulti(ctx, keys)
+	}
+	if err != nil {
+		return err
+	}
+	tx.trackKeys(keys...)
+	for _, key := range keys {
+		if err = hooks.callKeyHooks(ctx, &hooks.afterDelete, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testHookValidatedData struct {
+	Title string `json:"title"`
+}
+
+func (v *testHookValidatedData) Validate() error {
+	if v.Title == "" {
+		return errors.New("title is required")
+	}
+	return nil
+}
+
+func TestNewDBWithHooks(t *testing.T) {
+	assert.Panics(t, func() {
+		NewDBWithHooks(nil, NewHookRegistry())
+	})
+	assert.Panics(t, func() {
+		NewDBWithHooks(newMemoryDB(), nil)
+	})
+}
+
+func TestDBWithHooks_readwriteTransaction(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	memDB.putData(NewKeyWithID("users", "u0"), testHookUser{Name: "Zed"})
+	registry := NewHookRegistry()
+	db := NewDBWithHooks(memDB, registry)
+
+	var events []string
+	recordHook := func(event string) RecordHook {
+		return func(ctx context.Context, record Record) error {
+			events = append(events, fmt.Sprintf("%s:%v", event, record.Key()))
+			return nil
+		}
+	}
+	keyHook := func(event string) KeyHook {
+		return func(ctx context.Context, key *Key) error {
+			events = append(events, fmt.Sprintf("%s:%v", event, key))
+			return nil
+		}
+	}
+	updateHook := func(event string) UpdateHook {
+		return func(ctx context.Context, key *Key, updates []Update) error {
+			events = append(events, fmt.Sprintf("%s:%v:%s", event, key, updates[0].Field))
+			return nil
+		}
+	}
+	registry.BeforeInsert(recordHook("before_insert"))
+	registry.AfterInsert(recordHook("after_insert"))
+	registry.BeforeSet(recordHook("before_set"), HookForDataType(testHookUser{}))
+	registry.AfterSet(recordHook("after_set"))
+	registry.BeforeUpdate(updateHook("before_update"))
+	registry.AfterUpdate(updateHook("after_update"))
+	registry.BeforeDelete(keyHook("before_delete"), HookForCollection("users"))
+	registry.AfterDelete(keyHook("after_delete"))
+	registry.AfterLoad(recordHook("after_load"))
+	registry.AfterCommit(func(ctx context.Context, keys []*Key) error {
+		events = append(events, fmt.Sprintf("after_commit:%v", keys))
+		return nil
+	}, HookForCollection("users"))
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		u0 := NewRecordWithData(NewKeyWithID("users", "u0"), new(testHookUser))
+		if err := tx.Get(ctx, u0); err != nil {
+			return err
+		}
+		if err := tx.Insert(ctx, NewRecordWithData(NewKeyWithID("users", "u1"), &testHookUser{Name: "Ann"})); err != nil {
+			return err
+		}
+		if err := tx.SetMulti(ctx, []Record{
+			NewRecordWithData(NewKeyWithID("users", "u2"), &testHookUser{Name: "Bob"}),
+			NewRecordWithData(NewKeyWithID("tags", "t1"), &map[string]any{"title": "t1"}),
+		}); err != nil {
+			return err
+		}
+		if err := tx.Update(ctx, u0.Key(), []Update{{Field: "name", Value: "Zoe"}}); err != nil {
+			return err
+		}
+		return tx.DeleteMulti(ctx, []*Key{NewKeyWithID("users", "u2"), NewKeyWithID("tags", "t1")})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"after_load:users/u0",
+		"before_insert:users/u1",
+		"after_insert:users/u1",
+		"before_set:users/u2",
+		"after_set:users/u2",
+		"after_set:tags/t1",
+		"before_update:users/u0:name",
+		"after_update:users/u0:name",
+		"before_delete:users/u2",
+		"after_delete:users/u2",
+		"after_delete:tags/t1",
+		"after_commit:[users/u1 users/u2 users/u0 users/u2]",
+	}, events)
+
+	t.Run("no_after_commit_on_rollback", func(t *testing.T) {
+		events = nil
+		errRollback := errors.New("rollback")
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Set(ctx, NewRecordWithData(NewKeyWithID("users", "u3"), &testHookUser{Name: "Cid"})); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+		assert.Equal(t, []string{"before_set:users/u3", "after_set:users/u3"}, events)
+	})
+
+	t.Run("failed_before_hook_stops_operation", func(t *testing.T) {
+		registry.BeforeInsert(func(ctx context.Context, record Record) error {
+			return errors.New("rejected")
+		}, HookForCollection("rejected"))
+		key := NewKeyWithID("rejected", "r1")
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Insert(ctx, NewRecordWithData(key, &testHookUser{Name: "X"}))
+		})
+		assert.ErrorIs(t, err, ErrHookFailed)
+		assert.Nil(t, memDB.getData(key))
+	})
+}
+
+func TestDBWithHooks_validatesAndCallsDataHooks(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithHooks(memDB, NewHookRegistry())
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Insert(ctx, NewRecordWithData(NewKeyWithID("items", "i1"), &testHookValidatedData{}))
+	})
+	assert.EqualError(t, err, "title is required")
+
+	var loaded []string
+	memDB.putData(NewKeyWithID("items", "i2"), testHookValidatedData{Title: "Item 2"})
+	data := MakeRecordData(&testHookValidatedData{}, WithAfterLoad(func(ctx context.Context, db DB, key *Key, data any) error {
+		loaded = append(loaded, key.String()+":"+data.(*testHookValidatedData).Title)
+		return nil
+	}))
+	record := NewRecordWithData(NewKeyWithID("items", "i2"), data)
+	assert.Nil(t, db.Get(ctx, record))
+	assert.Equal(t, []string{"items/i2:"}, loaded)
+}
+
+func TestDBWithHooks_afterLoad(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	memDB.putData(NewKeyWithID("users", "u1"), testHookUser{Name: "Ann"})
+	memDB.putData(NewKeyWithID("users", "u2"), testHookUser{Name: "Bob"})
+	registry := NewHookRegistry()
+	var loaded []string
+	registry.AfterLoad(func(ctx context.Context, record Record) error {
+		loaded = append(loaded, record.Key().String())
+		return nil
+	})
+	db := NewDBWithHooks(memDB, registry)
+
+	records := []Record{
+		NewRecordWithData(NewKeyWithID("users", "u1"), new(testHookUser)),
+		NewRecordWithData(NewKeyWithID("users", "missing"), new(testHookUser)),
+	}
+	assert.Nil(t, db.GetMulti(ctx, records))
+	assert.Equal(t, []string{"users/u1"}, loaded)
+
+	loaded = nil
+	queried, err := db.QueryAllRecords(ctx, From("users").SelectInto(func() Record {
+		return NewRecordWithIncompleteKey("users", reflect.String, new(testHookUser))
+	}))
+	assert.Nil(t, err)
+	assert.Len(t, queried, 2)
+	assert.Equal(t, []string{"users/u1", "users/u2"}, loaded)
+
+	loaded = nil
+	err = db.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return tx.Get(ctx, NewRecordWithData(NewKeyWithID("users", "u2"), new(testHookUser)))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users/u2"}, loaded)
+}
@@ -0,0 +1,503 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DefaultSoftDeleteField is a name of a field that holds time of a soft delete
+const DefaultSoftDeleteField = "deletedAt"
+
+var includeDeletedContextKey = "includeDeletedContextKey"
+
+// errSoftDeleted is a cause of not found errors of updates of soft-deleted records
+var errSoftDeleted = errors.New("record is soft-deleted")
+
+// WithDeletedRecords returns a context that makes a DB created by NewDBWithSoftDelete()
+// to return soft-deleted records by Get, GetMulti & queries
+func WithDeletedRecords(ctx context.Context) context.Context {
+	return context.WithValue(ctx, &includeDeletedContextKey, true)
+}
+
+// IsIncludingDeletedRecords checks if a context has been created by WithDeletedRecords()
+func IsIncludingDeletedRecords(ctx context.Context) bool {
+	include, _ := ctx.Value(&includeDeletedContextKey).(bool)
+	return include
+}
+
+// SoftDeleteTransaction is implemented by transactions of a DB created by NewDBWithSoftDelete()
+type SoftDeleteTransaction interface {
+	ReadwriteTransaction
+
+	// HardDelete deletes records physically
+	HardDelete(ctx context.Context, keys ...*Key) error
+
+	// Undelete restores soft-deleted records
+	Undelete(ctx context.Context, keys ...*Key) error
+}
+
+// HardDelete deletes records physically, bypassing soft delete if the transaction supports it
+func HardDelete(ctx context.Context, tx ReadwriteTransaction, keys ...*Key) error {
+	if softDeleteTx, ok := tx.(SoftDeleteTransaction); ok {
+		return softDeleteTx.HardDelete(ctx, keys...)
+	}
+	return tx.DeleteMulti(ctx, keys)
+}
+
+// Undelete restores soft-deleted records, the transaction should be of a DB created by NewDBWithSoftDelete()
+func Undelete(ctx context.Context, tx ReadwriteTransaction, keys ...*Key) error {
+	if softDeleteTx, ok := tx.(SoftDeleteTransaction); ok {
+		return softDeleteTx.Undelete(ctx, keys...)
+	}
+	return fmt.Errorf("%w: undelete by transaction of type %T", ErrNotSupported, tx)
+}
+
+// SoftDeleteOption configures a DB created by NewDBWithSoftDelete()
+type SoftDeleteOption func(options *softDeleteOptions)
+
+type softDeleteOptions struct {
+	field       string
+	collections map[string]bool
+	now         func() time.Time
+}
+
+// SoftDeleteField sets a name of a field that holds time of a soft delete, defaults to DefaultSoftDeleteField
+func SoftDeleteField(name string) SoftDeleteOption {
+	if name == "" {
+		panic("name is a required parameter, got empty string")
+	}
+	return func(options *softDeleteOptions) {
+		options.field = name
+	}
+}
+
+// SoftDeleteCollections sets collections soft delete applies to, it is a required option of NewDBWithSoftDelete()
+// as records of these collections should have the soft delete field.
+func SoftDeleteCollections(collections ...string) SoftDeleteOption {
+	if len(collections) == 0 {
+		panic("at least 1 collection is required")
+	}
+	return func(options *softDeleteOptions) {
+		if options.collections == nil {
+			options.collections = make(map[string]bool, len(collections))
+		}
+		for _, collection := range collections {
+			options.collections[collection] = true
+		}
+	}
+}
+
+func (o softDeleteOptions) appliesTo(collection string) bool {
+	return o.collections[collection]
+}
+
+// NewDBWithSoftDelete wraps a DB so records of collections set by SoftDeleteCollections()
+// are not deleted physically but marked as deleted:
+//   - Delete & DeleteMulti of readwrite transactions set the soft delete field to current time,
+//     missing & already soft-deleted records are ignored (so the original time of a delete is kept);
+//   - Get & GetMulti report soft-deleted records as not found;
+//   - Update & UpdateMulti of soft-deleted records fail with a not found error;
+//   - queries get a `<field> IS NULL` condition & soft-deleted records are filtered out of results.
+//
+// Deletes & updates read records within a transaction first, note that some databases
+// (e.g. Firestore & Datastore) require all reads of a transaction to happen before writes.
+//
+// Use WithDeletedRecords() context to read soft-deleted records,
+// HardDelete() & Undelete() to delete records physically & to restore them.
+//
+// Some databases (e.g. Firestore & Datastore) do not match missing fields by `IS NULL` conditions,
+// so Insert, InsertMulti, Set & SetMulti write the soft delete field with an explicit nil value:
+// map data gets a nil item (in a copy of the map) & struct data should have the field of a pointer type,
+// e.g. a `DeletedAt *time.Time` field tagged by `dalgo:"deletedAt"`, otherwise writes fail with ErrNotSupported.
+// Records written before soft delete was enabled should be updated to have the field.
+func NewDBWithSoftDelete(db DB, options ...SoftDeleteOption) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	v := dbWithSoftDelete{DB: db, options: softDeleteOptions{field: DefaultSoftDeleteField, now: time.Now}}
+	for _, option := range options {
+		option(&v.options)
+	}
+	if len(v.options.collections) == 0 {
+		panic("collections are required, use SoftDeleteCollections() option")
+	}
+	return v
+}
+
+type dbWithSoftDelete struct {
+	DB
+	options softDeleteOptions
+}
+
+// isDeleted checks if a retrieved record is soft-deleted
+func (v dbWithSoftDelete) isDeleted(record Record) bool {
+	if !v.options.appliesTo(record.Key().Collection()) {
+		return false
+	}
+	if exists, err := recordStatus(record); err != nil || !exists {
+		return false
+	}
+	value, found := GetFieldValue(recordDataOrNil(record), v.options.field)
+	if !found {
+		return false
+	}
+	rv := indirectValue(value)
+	return rv.IsValid() && !rv.IsZero()
+}
+
+// hideDeleted marks soft-deleted records as not found
+func (v dbWithSoftDelete) hideDeleted(ctx context.Context, records ...Record) {
+	if IsIncludingDeletedRecords(ctx) {
+		return
+	}
+	for _, record := range records {
+		if v.isDeleted(record) {
+			record.SetError(NewErrNotFoundByKey(record.Key(), nil))
+		}
+	}
+}
+
+func (v dbWithSoftDelete) get(ctx context.Context, session Getter, record Record) error {
+	if err := session.Get(ctx, record); err != nil {
+		return err
+	}
+	if !IsIncludingDeletedRecords(ctx) && v.isDeleted(record) {
+		err := NewErrNotFoundByKey(record.Key(), nil)
+		record.SetError(err)
+		return err
+	}
+	return nil
+}
+
+func (v dbWithSoftDelete) getMulti(ctx context.Context, session MultiGetter, records []Record) error {
+	if err := session.GetMulti(ctx, records); err != nil {
+		return err
+	}
+	v.hideDeleted(ctx, records...)
+	return nil
+}
+
+func (v dbWithSoftDelete) queryReader(ctx context.Context, session QueryExecutor, query Query) (Reader, error) {
+	if IsIncludingDeletedRecords(ctx) || query.From() == nil || !v.options.appliesTo(query.From().Name) {
+		return session.QueryReader(ctx, query)
+	}
+	reader, err := session.QueryReader(ctx, queryWithCondition(query, WhereField(v.options.field, Equal, nil)))
+	if err != nil {
+		return nil, err
+	}
+	return softDeleteReader{Reader: reader, db: v}, nil
+}
+
+func (v dbWithSoftDelete) Get(ctx context.Context, record Record) error {
+	return v.get(ctx, v.DB, record)
+}
+
+func (v dbWithSoftDelete) GetMulti(ctx context.Context, records []Record) error {
+	return v.getMulti(ctx, v.DB, records)
+}
+
+func (v dbWithSoftDelete) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return v.queryReader(ctx, v.DB, query)
+}
+
+func (v dbWithSoftDelete) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := v.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (v dbWithSoftDelete) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return f(ctx, readTransactionWithSoftDelete{ReadTransaction: tx, db: v})
+	}, options...)
+}
+
+func (v dbWithSoftDelete) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	return v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return f(ctx, readwriteTransactionWithSoftDelete{ReadwriteTransaction: tx, db: v})
+	}, options...)
+}
+
+// queryWithCondition returns a query with an additional condition joined by AND
+func queryWithCondition(query Query, condition Condition) Query {
+	where := condition
+	if query.Where() != nil {
+		where = GroupCondition{operator: And, conditions: []Condition{query.Where(), condition}}
+	}
+	if q, ok := query.(theQuery); ok {
+		q.where = where
+		return q
+	}
+	return queryWithWhere{Query: query, where: where}
+}
+
+type queryWithWhere struct {
+	Query
+	where Condition
+}
+
+func (q queryWithWhere) Where() Condition {
+	return q.where
+}
+
+// softDeleteReader skips soft-deleted records in case a DB does not apply the condition, e.g. to missing fields
+type softDeleteReader struct {
+	Reader
+	db dbWithSoftDelete
+}
+
+func (r softDeleteReader) Next() (Record, error) {
+	for {
+		record, err := r.Reader.Next()
+		if err != nil || !r.db.isDeleted(record) {
+			return record, err
+		}
+	}
+}
+
+type readTransactionWithSoftDelete struct {
+	ReadTransaction
+	db dbWithSoftDelete
+}
+
+func (tx readTransactionWithSoftDelete) Get(ctx context.Context, record Record) error {
+	return tx.db.get(ctx, tx.ReadTransaction, record)
+}
+
+func (tx readTransactionWithSoftDelete) GetMulti(ctx context.Context, records []Record) error {
+	return tx.db.getMulti(ctx, tx.ReadTransaction, records)
+}
+
+func (tx readTransactionWithSoftDelete) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.db.queryReader(ctx, tx.ReadTransaction, query)
+}
+
+func (tx readTransactionWithSoftDelete) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+var _ SoftDeleteTransaction = readwriteTransactionWithSoftDelete{}
+
+type readwriteTransactionWithSoftDelete struct {
+	ReadwriteTransaction
+	db dbWithSoftDelete
+}
+
+func (tx readwriteTransactionWithSoftDelete) Get(ctx context.Context, record Record) error {
+	return tx.db.get(ctx, tx.ReadwriteTransaction, record)
+}
+
+func (tx readwriteTransactionWithSoftDelete) GetMulti(ctx context.Context, records []Record) error {
+	return tx.db.getMulti(ctx, tx.ReadwriteTransaction, records)
+}
+
+func (tx readwriteTransactionWithSoftDelete) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.db.queryReader(ctx, tx.ReadwriteTransaction, query)
+}
+
+func (tx readwriteTransactionWithSoftDelete) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := tx.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+// withNilDeletedAt returns records with data that has an explicit nil soft delete field
+func (v dbWithSoftDelete) withNilDeletedAt(records []Record) ([]Record, error) {
+	result := make([]Record, len(records))
+	for i, record := range records {
+		result[i] = record
+		if !v.options.appliesTo(record.Key().Collection()) {
+			continue
+		}
+		data := recordDataOrNil(record)
+		if wrapper, ok := data.(DataWrapper); ok {
+			data = wrapper.Data()
+		}
+		rv := indirectValue(data)
+		if !rv.IsValid() {
+			continue
+		}
+		field := v.options.field
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String || !isNilable(rv.Type().Elem().Kind()) {
+				return nil, fmt.Errorf("%w: soft delete of record %v with data of type %T", ErrNotSupported, record.Key(), data)
+			}
+			key := reflect.ValueOf(field).Convert(rv.Type().Key())
+			if rv.MapIndex(key).IsValid() {
+				continue
+			}
+			m := shallowCopy(rv)
+			m.SetMapIndex(key, reflect.Zero(rv.Type().Elem()))
+			result[i] = recordWithData{Record: record, data: m.Interface()}
+		case reflect.Struct:
+			index, ok := structFieldIndex(rv.Type(), field)
+			if !ok || !isNilable(rv.Type().FieldByIndex(index).Type.Kind()) {
+				return nil, fmt.Errorf("%w: data of type %T of record %v should have a soft delete field %s of a pointer type, e.g. *time.Time",
+					ErrNotSupported, data, record.Key(), field)
+			}
+		default:
+			return nil, fmt.Errorf("%w: soft delete of record %v with data of type %T", ErrNotSupported, record.Key(), data)
+		}
+	}
+	return result, nil
+}
+
+func isNilable(kind reflect.Kind) bool {
+	return kind == reflect.Pointer || kind == reflect.Interface
+}
+
+func (tx readwriteTransactionWithSoftDelete) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	records, err := tx.db.withNilDeletedAt([]Record{record})
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.Insert(ctx, records[0], opts...)
+}
+
+func (tx readwriteTransactionWithSoftDelete) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	records, err := tx.db.withNilDeletedAt(records)
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.InsertMulti(ctx, records, opts...)
+}
+
+func (tx readwriteTransactionWithSoftDelete) Set(ctx context.Context, record Record) error {
+	records, err := tx.db.withNilDeletedAt([]Record{record})
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.Set(ctx, records[0])
+}
+
+func (tx readwriteTransactionWithSoftDelete) SetMulti(ctx context.Context, records []Record) error {
+	records, err := tx.db.withNilDeletedAt(records)
+	if err != nil {
+		return err
+	}
+	return tx.ReadwriteTransaction.SetMulti(ctx, records)
+}
+
+// readStates reads records of soft-deletable collections with data as maps
+func (tx readwriteTransactionWithSoftDelete) readStates(ctx context.Context, keys []*Key) ([]Record, error) {
+	records := make([]Record, len(keys))
+	for i, key := range keys {
+		records[i] = NewRecordWithData(key, new(map[string]any))
+	}
+	var err error
+	if len(records) == 1 {
+		if err = tx.ReadwriteTransaction.Get(ctx, records[0]); IsNotFound(err) {
+			err = nil
+		}
+	} else {
+		err = tx.ReadwriteTransaction.GetMulti(ctx, records)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read records to check if they are soft-deleted: %w", err)
+	}
+	return records, nil
+}
+
+func (tx readwriteTransactionWithSoftDelete) Update(ctx context.Context, key *Key, updates []Update, preconditions ...Precondition) error {
+	return tx.UpdateMulti(ctx, []*Key{key}, updates, preconditions...)
+}
+
+// UpdateMulti fails with a not found error if any of the records is soft-deleted
+func (tx readwriteTransactionWithSoftDelete) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) error {
+	var softDeletable []*Key
+	for _, key := range keys {
+		if tx.db.options.appliesTo(key.Collection()) {
+			softDeletable = append(softDeletable, key)
+		}
+	}
+	if len(softDeletable) > 0 {
+		records, err := tx.readStates(ctx, softDeletable)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if tx.db.isDeleted(record) {
+				return NewErrNotFoundByKey(record.Key(), errSoftDeleted)
+			}
+		}
+	}
+	if len(keys) == 1 {
+		return tx.ReadwriteTransaction.Update(ctx, keys[0], updates, preconditions...)
+	}
+	return tx.ReadwriteTransaction.UpdateMulti(ctx, keys, updates, preconditions...)
+}
+
+func (tx readwriteTransactionWithSoftDelete) Delete(ctx context.Context, key *Key) error {
+	return tx.DeleteMulti(ctx, []*Key{key})
+}
+
+// DeleteMulti soft-deletes records of collections soft delete applies to & deletes other records physically
+func (tx readwriteTransactionWithSoftDelete) DeleteMulti(ctx context.Context, keys []*Key) error {
+	var softDelete, hardDelete []*Key
+	for _, key := range keys {
+		if tx.db.options.appliesTo(key.Collection()) {
+			softDelete = append(softDelete, key)
+		} else {
+			hardDelete = append(hardDelete, key)
+		}
+	}
+	if len(softDelete) > 0 {
+		records, err := tx.readStates(ctx, softDelete)
+		if err != nil {
+			return err
+		}
+		softDelete = softDelete[:0:0]
+		for _, record := range records {
+			if exists, _ := recordStatus(record); exists && !tx.db.isDeleted(record) {
+				softDelete = append(softDelete, record.Key())
+			}
+		}
+	}
+	if len(softDelete) > 0 {
+		if err := tx.setDeletedAt(ctx, softDelete, tx.db.options.now()); err != nil {
+			return err
+		}
+	}
+	if len(hardDelete) > 0 {
+		return tx.HardDelete(ctx, hardDelete...)
+	}
+	return nil
+}
+
+func (tx readwriteTransactionWithSoftDelete) setDeletedAt(ctx context.Context, keys []*Key, value any) error {
+	updates := []Update{{Field: tx.db.options.field, Value: value}}
+	if len(keys) == 1 {
+		return tx.ReadwriteTransaction.Update(ctx, keys[0], updates)
+	}
+	return tx.ReadwriteTransaction.UpdateMulti(ctx, keys, updates)
+}
+
+func (tx readwriteTransactionWithSoftDelete) HardDelete(ctx context.Context, keys ...*Key) error {
+	switch len(keys) {
+	case 0:
+		return nil
+	case 1:
+		return tx.ReadwriteTransaction.Delete(ctx, keys[0])
+	default:
+		return tx.ReadwriteTransaction.DeleteMulti(ctx, keys)
+	}
+}
+
+func (tx readwriteTransactionWithSoftDelete) Undelete(ctx context.Context, keys ...*Key) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return tx.setDeletedAt(ctx, keys, nil)
+}
@@ -0,0 +1,250 @@
+package dal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSoftDeletedUser struct {
+	Name      string     `json:"name"`
+	DeletedAt *time.Time `json:"deletedAt" dalgo:"deletedAt"`
+}
+
+func TestNewDBWithSoftDelete(t *testing.T) {
+	assert.Panics(t, func() {
+		NewDBWithSoftDelete(nil)
+	})
+	assert.Panics(t, func() {
+		SoftDeleteField("")
+	})
+	assert.Panics(t, func() {
+		SoftDeleteCollections()
+	})
+	assert.Panics(t, func() {
+		NewDBWithSoftDelete(newMemoryDB())
+	}, "collections should be set explicitly")
+}
+
+func TestWithDeletedRecords(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, IsIncludingDeletedRecords(ctx))
+	assert.True(t, IsIncludingDeletedRecords(WithDeletedRecords(ctx)))
+}
+
+func TestDBWithSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	u1, u2, u3 := NewKeyWithID("users", "u1"), NewKeyWithID("users", "u2"), NewKeyWithID("users", "u3")
+	log := NewKeyWithID("logs", "l1")
+	deletedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	memDB := newMemoryDB()
+	memDB.putData(u1, testSoftDeletedUser{Name: "Ann"})
+	memDB.putData(u2, testSoftDeletedUser{Name: "Bob"})
+	memDB.putData(u3, testSoftDeletedUser{Name: "Eve"})
+	memDB.putData(log, map[string]any{"message": "hello"})
+	db := NewDBWithSoftDelete(memDB, SoftDeleteCollections("users"), func(options *softDeleteOptions) {
+		options.now = func() time.Time { return deletedAt }
+	})
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		if err := tx.Delete(ctx, u1); err != nil {
+			return err
+		}
+		return tx.DeleteMulti(ctx, []*Key{u2, log})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "2024-01-02T03:04:05Z", memDB.getData(u1)["deletedAt"], "should be soft-deleted")
+	assert.Equal(t, "2024-01-02T03:04:05Z", memDB.getData(u2)["deletedAt"], "should be soft-deleted")
+	assert.Nil(t, memDB.getData(log), "should be deleted physically as soft delete does not apply to the collection")
+
+	t.Run("Get", func(t *testing.T) {
+		record := NewRecordWithData(u1, new(testSoftDeletedUser))
+		err := db.Get(ctx, record)
+		assert.True(t, IsNotFound(err))
+		assert.False(t, record.Exists())
+
+		record = NewRecordWithData(u1, new(testSoftDeletedUser))
+		assert.Nil(t, db.Get(WithDeletedRecords(ctx), record))
+		assert.True(t, record.Exists())
+		assert.Equal(t, deletedAt, *record.Data().(*testSoftDeletedUser).DeletedAt)
+
+		record = NewRecordWithData(u3, new(testSoftDeletedUser))
+		assert.Nil(t, db.Get(ctx, record))
+		assert.True(t, record.Exists())
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		records := []Record{
+			NewRecordWithData(u1, new(testSoftDeletedUser)),
+			NewRecordWithData(u3, new(testSoftDeletedUser)),
+		}
+		assert.Nil(t, db.GetMulti(ctx, records))
+		assert.False(t, records[0].Exists())
+		assert.True(t, records[1].Exists())
+	})
+
+	t.Run("QueryReader", func(t *testing.T) {
+		query := From("users").SelectInto(func() Record {
+			return NewRecordWithIncompleteKey("users", reflect.String, new(testSoftDeletedUser))
+		})
+		records, err := db.QueryAllRecords(ctx, query)
+		assert.Nil(t, err)
+		assert.Len(t, records, 1)
+		assert.Equal(t, u3, records[0].Key())
+
+		records, err = db.QueryAllRecords(WithDeletedRecords(ctx), query)
+		assert.Nil(t, err)
+		assert.Len(t, records, 3)
+	})
+
+	t.Run("Delete_is_idempotent", func(t *testing.T) {
+		db := NewDBWithSoftDelete(memDB, SoftDeleteCollections("users"), func(options *softDeleteOptions) {
+			options.now = func() time.Time { return deletedAt.Add(time.Hour) }
+		})
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := tx.Delete(ctx, NewKeyWithID("users", "missing")); err != nil {
+				return err
+			}
+			return tx.DeleteMulti(ctx, []*Key{u1, NewKeyWithID("users", "missing2")})
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, memDB.getData(NewKeyWithID("users", "missing")), "missing record should not be created")
+		assert.Equal(t, "2024-01-02T03:04:05Z", memDB.getData(u1)["deletedAt"], "time of the first delete should be kept")
+	})
+
+	t.Run("Update_of_deleted", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.Update(ctx, u1, []Update{{Field: "name", Value: "Anna"}})
+		})
+		assert.True(t, IsNotFound(err))
+		assert.ErrorContains(t, err, errSoftDeleted.Error())
+		assert.Equal(t, "Ann", memDB.getData(u1)["name"])
+
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return tx.UpdateMulti(ctx, []*Key{u3}, []Update{{Field: "name", Value: "Eva"}})
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "Eva", memDB.getData(u3)["name"])
+	})
+
+	t.Run("Undelete_and_HardDelete", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			if err := Undelete(ctx, tx, u1); err != nil {
+				return err
+			}
+			return HardDelete(ctx, tx, u2)
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, memDB.getData(u2))
+		record := NewRecordWithData(u1, new(testSoftDeletedUser))
+		assert.Nil(t, db.Get(ctx, record))
+		assert.Nil(t, record.Data().(*testSoftDeletedUser).DeletedAt)
+	})
+
+	t.Run("Undelete_not_supported", func(t *testing.T) {
+		err := memDB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+			return Undelete(ctx, tx, u1)
+		})
+		assert.ErrorIs(t, err, ErrNotSupported)
+	})
+}
+
+func Test_queryWithCondition(t *testing.T) {
+	condition := WhereField("deletedAt", Equal, nil)
+	query := From("users").Limit(10).SelectKeysOnly(reflect.String)
+	q := queryWithCondition(query, condition)
+	assert.Equal(t, condition, q.Where())
+	assert.Equal(t, 10, q.Limit())
+
+	query = From("users").WhereField("name", Equal, "Ann").SelectKeysOnly(reflect.String)
+	q = queryWithCondition(query, condition)
+	group, ok := q.Where().(GroupCondition)
+	assert.True(t, ok)
+	assert.Equal(t, Operator(And), group.Operator())
+	assert.Equal(t, []Condition{query.Where(), condition}, group.Conditions())
+}
+
+// missingFieldsDB applies `field == nil` conditions of queries like Firestore & Datastore do:
+// records with missing fields are not matched
+type missingFieldsDB struct {
+	*memoryDB
+}
+
+func (db missingFieldsDB) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	records, err := db.memoryDB.QueryAllRecords(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []Condition
+	switch where := query.Where().(type) {
+	case nil:
+	case GroupCondition:
+		conditions = where.Conditions()
+	default:
+		conditions = []Condition{where}
+	}
+	var matched []Record
+	for _, record := range records {
+		data := *record.Data().(*map[string]any)
+		isMatching := true
+		for _, condition := range conditions {
+			if c, ok := condition.(Comparison); ok && c.Operator == Equal && c.Right == (Constant{Value: nil}) {
+				value, found := data[c.Left.(FieldRef).Name]
+				isMatching = isMatching && found && value == nil
+			}
+		}
+		if isMatching {
+			matched = append(matched, record)
+		}
+	}
+	return NewRecordsReader(matched), nil
+}
+
+func (db missingFieldsDB) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	return db.memoryDB.RunReadwriteTransaction(ctx, f, options...)
+}
+
+func TestDBWithSoftDelete_WritesNilDeletedAt(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithSoftDelete(missingFieldsDB{memoryDB: memDB}, SoftDeleteCollections("users", "posts"))
+	u1, u2, p1 := NewKeyWithID("users", "u1"), NewKeyWithID("users", "u2"), NewKeyWithID("posts", "p1")
+	post := map[string]any{"title": "hello"}
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		if err := tx.Insert(ctx, NewRecordWithData(u1, &testSoftDeletedUser{Name: "Ann"})); err != nil {
+			return err
+		}
+		if err := tx.SetMulti(ctx, []Record{NewRecordWithData(u2, testSoftDeletedUser{Name: "Bob"})}); err != nil {
+			return err
+		}
+		return tx.Set(ctx, NewRecordWithData(p1, post))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"title": "hello"}, post, "data of a record should not be modified")
+	data := memDB.getData(p1)
+	assert.Contains(t, data, "deletedAt")
+	assert.Nil(t, data["deletedAt"])
+
+	records, err := db.QueryAllRecords(ctx, From("users").SelectInto(func() Record {
+		return NewRecordWithIncompleteKey("users", reflect.String, new(testSoftDeletedUser))
+	}))
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	records, err = db.QueryAllRecords(ctx, From("posts").SelectKeysOnly(reflect.String))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, NewRecordWithData(u1, &struct{ Name string }{Name: "Ann"}))
+	})
+	assert.ErrorIs(t, err, ErrNotSupported, "struct data should have a nilable soft delete field")
+	err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.InsertMulti(ctx, []Record{NewRecordWithData(p1, map[string]string{"title": "x"})})
+	})
+	assert.ErrorIs(t, err, ErrNotSupported, "a map should be able to hold nil values")
+}
@@ -0,0 +1,472 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoTenant indicates an operation of a strict multi-tenant DB is called without a tenant in a context
+var ErrNoTenant = errors.New("no tenant in context")
+
+// ErrInvalidTenant indicates a tenant ID can't be used to scope records, e.g. it contains a collection prefix separator
+var ErrInvalidTenant = errors.New("invalid tenant ID")
+
+// DefaultTenantsCollection is a name of a collection of tenant keys that are used as parents of tenant's records
+const DefaultTenantsCollection = "tenants"
+
+var tenantContextKey = "tenantContextKey"
+
+// WithTenant returns a context that makes a DB created by NewDBWithTenants() to work with records of the tenant
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		panic("tenantID is a required parameter, got empty string")
+	}
+	return context.WithValue(ctx, &tenantContextKey, tenantID)
+}
+
+// TenantFromContext returns ID of a tenant set by WithTenant()
+func TenantFromContext(ctx context.Context) (tenantID string, ok bool) {
+	tenantID, ok = ctx.Value(&tenantContextKey).(string)
+	return
+}
+
+// TenancyOption configures a DB created by NewDBWithTenants()
+type TenancyOption func(t *tenancy)
+
+// TenantParentKeys makes keys of records to be children of a tenant key `<collection>/<tenantID>`,
+// e.g. `users/u1` becomes `tenants/t1/users/u1`. This is the default with DefaultTenantsCollection.
+// Collection group queries are not supported in this mode as they would return records of all tenants.
+func TenantParentKeys(collection string) TenancyOption {
+	if collection == "" {
+		panic("collection is a required parameter, got empty string")
+	}
+	return func(t *tenancy) {
+		t.tenantsCollection, t.separator = collection, ""
+	}
+}
+
+// TenantCollectionPrefix makes names of collections to be prefixed with a tenant ID & a separator,
+// e.g. `users/u1` becomes `t1_users/u1` for "_" separator. Collections of all levels of a key are prefixed.
+// Tenant IDs that contain the separator are rejected with ErrInvalidTenant,
+// otherwise tenants "a" & "a_b" would share a collection "a_b_users" (of collections "b_users" & "users").
+func TenantCollectionPrefix(separator string) TenancyOption {
+	if separator == "" {
+		panic("separator is a required parameter, got empty string")
+	}
+	return func(t *tenancy) {
+		t.tenantsCollection, t.separator = "", separator
+	}
+}
+
+// StrictTenancy makes operations without a tenant in a context to fail with ErrNoTenant,
+// otherwise such operations work with keys as is.
+func StrictTenancy() TenancyOption {
+	return func(t *tenancy) {
+		t.strict = true
+	}
+}
+
+type tenancy struct {
+	tenantsCollection string // for parent keys mode
+	separator         string // for collection prefix mode
+	strict            bool
+}
+
+// tenantID returns a tenant from a context, empty if there is no tenant & tenancy is not strict
+func (t tenancy) tenantID(ctx context.Context) (string, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		if t.strict {
+			return "", ErrNoTenant
+		}
+		return "", nil
+	}
+	if err := t.validateTenantID(tenantID); err != nil {
+		return "", err
+	}
+	return tenantID, nil
+}
+
+func (t tenancy) validateTenantID(tenantID string) error {
+	if tenantID == "" {
+		return fmt.Errorf("%w: empty string", ErrInvalidTenant)
+	}
+	if t.separator != "" && strings.Contains(tenantID, t.separator) {
+		return fmt.Errorf("%w: %q contains collection prefix separator %q", ErrInvalidTenant, tenantID, t.separator)
+	}
+	return nil
+}
+
+// scopeKey returns a copy of a key in the namespace of a tenant
+func (t tenancy) scopeKey(tenantID string, key *Key) *Key {
+	if key == nil {
+		if t.separator == "" {
+			return NewKeyWithID(t.tenantsCollection, tenantID)
+		}
+		return nil
+	}
+	scoped := *key
+	if t.separator != "" {
+		scoped.collection = tenantID + t.separator + key.collection
+	}
+	scoped.parent = t.scopeKey(tenantID, key.parent)
+	return &scoped
+}
+
+// unscopeKey reverts scopeKey()
+func (t tenancy) unscopeKey(tenantID string, key *Key) *Key {
+	if key == nil {
+		return nil
+	}
+	if t.separator == "" && key.parent == nil && key.collection == t.tenantsCollection && equalKeyIDs(key.ID, tenantID) {
+		return nil // the tenant key itself
+	}
+	unscoped := *key
+	if t.separator != "" {
+		unscoped.collection = strings.TrimPrefix(key.collection, tenantID+t.separator)
+	}
+	unscoped.parent = t.unscopeKey(tenantID, key.parent)
+	return &unscoped
+}
+
+func (t tenancy) scopeKeys(tenantID string, keys []*Key) []*Key {
+	scoped := make([]*Key, len(keys))
+	for i, key := range keys {
+		scoped[i] = t.scopeKey(tenantID, key)
+	}
+	return scoped
+}
+
+func (t tenancy) scopeRecords(tenantID string, records []Record) []Record {
+	scoped := make([]Record, len(records))
+	for i, r := range records {
+		scoped[i] = recordWithKey{Record: r, key: t.scopeKey(tenantID, r.Key())}
+	}
+	return scoped
+}
+
+// syncIDs copies IDs generated by inserts of scoped records back to original records
+func syncIDs(records, scoped []Record) {
+	for i, r := range records {
+		if key, scopedKey := r.Key(), scoped[i].Key(); key != nil && scopedKey != nil {
+			key.ID, key.IDKind = scopedKey.ID, scopedKey.IDKind
+		}
+	}
+}
+
+func (t tenancy) scopeQuery(tenantID string, query Query) (Query, error) {
+	from := query.From()
+	if from == nil {
+		return query, nil
+	}
+	scopedFrom := *from
+	if from.IsGroup {
+		if t.separator == "" {
+			return nil, fmt.Errorf("%w: collection group queries with tenant parent keys", ErrNotSupported)
+		}
+		scopedFrom.Name = tenantID + t.separator + from.Name
+	} else {
+		scopedFrom.Parent = t.scopeKey(tenantID, from.Parent)
+		if t.separator != "" {
+			scopedFrom.Name = tenantID + t.separator + from.Name
+		}
+	}
+	if q, ok := query.(theQuery); ok {
+		q.from = &scopedFrom
+		return q, nil
+	}
+	return queryWithFrom{Query: query, from: &scopedFrom}, nil
+}
+
+type queryWithFrom struct {
+	Query
+	from *CollectionRef
+}
+
+func (q queryWithFrom) From() *CollectionRef {
+	return q.from
+}
+
+// recordWithKey overrides a key of a record, all other methods are delegated to the record
+type recordWithKey struct {
+	Record
+	key *Key
+}
+
+func (r recordWithKey) Key() *Key {
+	return r.key
+}
+
+func (r recordWithKey) SetError(err error) Record {
+	r.Record.SetError(err)
+	return r
+}
+
+// unscopedReader returns records with keys out of a tenant namespace
+type unscopedReader struct {
+	Reader
+	tenancy  tenancy
+	tenantID string
+}
+
+func (r unscopedReader) Next() (Record, error) {
+	next, err := r.Reader.Next()
+	if err != nil || next == nil {
+		return next, err
+	}
+	key := r.tenancy.unscopeKey(r.tenantID, next.Key())
+	if v, ok := next.(*record); ok {
+		v.key = key
+		return v, nil
+	}
+	return recordWithKey{Record: next, key: key}, nil
+}
+
+// NewDBWithTenants wraps a DB so records of a tenant set by WithTenant() are isolated from records of other tenants:
+//   - keys of records passed to Get, GetMulti & write operations are moved to the namespace of the tenant;
+//   - queries are scoped to the namespace of the tenant;
+//   - keys of records returned by queries are moved out of the namespace, so callers see keys as they are.
+//
+// By default, tenant parent keys are used (see TenantParentKeys()), use TenantCollectionPrefix() to prefix collections.
+// Use StrictTenancy() to refuse operations without a tenant, so a forgotten WithTenant() is not a data leak.
+// Collection group queries without a tenant are refused in any mode.
+//
+// Note that keys in errors returned by the underlying DB are in the namespace of the tenant.
+func NewDBWithTenants(db DB, options ...TenancyOption) DB {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	v := dbWithTenants{DB: db, tenancy: tenancy{tenantsCollection: DefaultTenantsCollection}}
+	for _, option := range options {
+		option(&v.tenancy)
+	}
+	return v
+}
+
+type dbWithTenants struct {
+	DB
+	tenancy tenancy
+}
+
+// tenantSession implements tenant scoped reads for both DB & transactions
+type tenantSession struct {
+	session ReadSession
+	tenancy tenancy
+}
+
+func (s tenantSession) Get(ctx context.Context, record Record) error {
+	tenantID, err := s.tenancy.tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	if tenantID == "" {
+		return s.session.Get(ctx, record)
+	}
+	return s.session.Get(ctx, recordWithKey{Record: record, key: s.tenancy.scopeKey(tenantID, record.Key())})
+}
+
+func (s tenantSession) GetMulti(ctx context.Context, records []Record) error {
+	tenantID, err := s.tenancy.tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	if tenantID == "" {
+		return s.session.GetMulti(ctx, records)
+	}
+	return s.session.GetMulti(ctx, s.tenancy.scopeRecords(tenantID, records))
+}
+
+func (s tenantSession) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	tenantID, err := s.tenancy.tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID == "" {
+		if from := query.From(); from != nil && from.IsGroup {
+			return nil, fmt.Errorf("%w: collection group query would read records of all tenants", ErrNoTenant)
+		}
+		return s.session.QueryReader(ctx, query)
+	}
+	if query, err = s.tenancy.scopeQuery(tenantID, query); err != nil {
+		return nil, err
+	}
+	reader, err := s.session.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return unscopedReader{Reader: reader, tenancy: s.tenancy, tenantID: tenantID}, nil
+}
+
+func (s tenantSession) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := s.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (v dbWithTenants) session() tenantSession {
+	return tenantSession{session: v.DB, tenancy: v.tenancy}
+}
+
+func (v dbWithTenants) Get(ctx context.Context, record Record) error {
+	return v.session().Get(ctx, record)
+}
+
+func (v dbWithTenants) GetMulti(ctx context.Context, records []Record) error {
+	return v.session().GetMulti(ctx, records)
+}
+
+func (v dbWithTenants) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return v.session().QueryReader(ctx, query)
+}
+
+func (v dbWithTenants) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	return v.session().QueryAllRecords(ctx, query)
+}
+
+func (v dbWithTenants) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	if _, err := v.tenancy.tenantID(ctx); err != nil {
+		return err
+	}
+	return v.DB.RunReadonlyTransaction(ctx, func(ctx context.Context, tx ReadTransaction) error {
+		return f(ctx, readTransactionWithTenants{
+			ReadTransaction: tx,
+			tenantSession:   tenantSession{session: tx, tenancy: v.tenancy},
+		})
+	}, options...)
+}
+
+func (v dbWithTenants) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	if _, err := v.tenancy.tenantID(ctx); err != nil {
+		return err
+	}
+	return v.DB.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return f(ctx, readwriteTransactionWithTenants{
+			ReadwriteTransaction: tx,
+			tenantSession:        tenantSession{session: tx, tenancy: v.tenancy},
+		})
+	}, options...)
+}
+
+type readTransactionWithTenants struct {
+	ReadTransaction
+	tenantSession
+}
+
+func (tx readTransactionWithTenants) Get(ctx context.Context, record Record) error {
+	return tx.tenantSession.Get(ctx, record)
+}
+
+func (tx readTransactionWithTenants) GetMulti(ctx context.Context, records []Record) error {
+	return tx.tenantSession.GetMulti(ctx, records)
+}
+
+func (tx readTransactionWithTenants) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.tenantSession.QueryReader(ctx, query)
+}
+
+func (tx readTransactionWithTenants) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	return tx.tenantSession.QueryAllRecords(ctx, query)
+}
+
+type readwriteTransactionWithTenants struct {
+	ReadwriteTransaction
+	tenantSession
+}
+
+func (tx readwriteTransactionWithTenants) Get(ctx context.Context, record Record) error {
+	return tx.tenantSession.Get(ctx, record)
+}
+
+func (tx readwriteTransactionWithTenants) GetMulti(ctx context.Context, records []Record) error {
+	return tx.tenantSession.GetMulti(ctx, records)
+}
+
+func (tx readwriteTransactionWithTenants) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.tenantSession.QueryReader(ctx, query)
+}
+
+func (tx readwriteTransactionWithTenants) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	return tx.tenantSession.QueryAllRecords(ctx, query)
+}
+
+// scope returns records & keys in the namespace of a tenant from a context, or as is if there is no tenant
+func (tx readwriteTransactionWithTenants) scope(ctx context.Context, records []Record, keys []*Key) ([]Record, []*Key, error) {
+	tenantID, err := tx.tenancy.tenantID(ctx)
+	if err != nil || tenantID == "" {
+		return records, keys, err
+	}
+	if records != nil {
+		records = tx.tenancy.scopeRecords(tenantID, records)
+	}
+	if keys != nil {
+		keys = tx.tenancy.scopeKeys(tenantID, keys)
+	}
+	return records, keys, nil
+}
+
+func (tx readwriteTransactionWithTenants) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	return tx.InsertMulti(ctx, []Record{record}, opts...)
+}
+
+func (tx readwriteTransactionWithTenants) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	scoped, _, err := tx.scope(ctx, records, nil)
+	if err != nil {
+		return err
+	}
+	if len(scoped) == 1 {
+		err = tx.ReadwriteTransaction.Insert(ctx, scoped[0], opts...)
+	} else {
+		err = tx.ReadwriteTransaction.InsertMulti(ctx, scoped, opts...)
+	}
+	syncIDs(records, scoped)
+	return err
+}
+
+func (tx readwriteTransactionWithTenants) Set(ctx context.Context, record Record) error {
+	return tx.SetMulti(ctx, []Record{record})
+}
+
+func (tx readwriteTransactionWithTenants) SetMulti(ctx context.Context, records []Record) error {
+	scoped, _, err := tx.scope(ctx, records, nil)
+	if err != nil {
+		return err
+	}
+	if len(scoped) == 1 {
+		return tx.ReadwriteTransaction.Set(ctx, scoped[0])
+	}
+	return tx.ReadwriteTransaction.SetMulti(ctx, scoped)
+}
+
+func (tx readwriteTransactionWithTenants) Update(ctx context.Context, key *Key, updates []Update, preconditions ...Precondition) error {
+	return tx.UpdateMulti(ctx, []*Key{key}, updates, preconditions...)
+}
+
+func (tx readwriteTransactionWithTenants) UpdateMulti(ctx context.Context, keys []*Key, updates []Update, preconditions ...Precondition) error {
+	_, scoped, err := tx.scope(ctx, nil, keys)
+	if err != nil {
+		return err
+	}
+	if len(scoped) == 1 {
+		return tx.ReadwriteTransaction.Update(ctx, scoped[0], updates, preconditions...)
+	}
+	return tx.ReadwriteTransaction.UpdateMulti(ctx, scoped, updates, preconditions...)
+}
+
+func (tx readwriteTransactionWithTenants) Delete(ctx context.Context, key *Key) error {
+	return tx.DeleteMulti(ctx, []*Key{key})
+}
+
+func (tx readwriteTransactionWithTenants) DeleteMulti(ctx context.Context, keys []*Key) error {
+	_, scoped, err := tx.scope(ctx, nil, keys)
+	if err != nil {
+		return err
+	}
+	if len(scoped) == 1 {
+		return tx.ReadwriteTransaction.Delete(ctx, scoped[0])
+	}
+	return tx.ReadwriteTransaction.DeleteMulti(ctx, scoped)
+}
@@ -0,0 +1,190 @@
+package dal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTenant(t *testing.T) {
+	ctx := context.Background()
+	_, ok := TenantFromContext(ctx)
+	assert.False(t, ok)
+	tenantID, ok := TenantFromContext(WithTenant(ctx, "t1"))
+	assert.True(t, ok)
+	assert.Equal(t, "t1", tenantID)
+	assert.Panics(t, func() {
+		WithTenant(ctx, "")
+	})
+}
+
+func TestNewDBWithTenants(t *testing.T) {
+	assert.Panics(t, func() {
+		NewDBWithTenants(nil)
+	})
+	assert.Panics(t, func() {
+		TenantParentKeys("")
+	})
+	assert.Panics(t, func() {
+		TenantCollectionPrefix("")
+	})
+}
+
+func Test_tenancy_scopeKey(t *testing.T) {
+	key := NewKeyWithParentAndID(NewKeyWithID("users", "u1"), "orders", 1)
+	for _, tt := range []struct {
+		name     string
+		tenancy  tenancy
+		expected string
+	}{
+		{name: "parent_keys", tenancy: tenancy{tenantsCollection: "tenants"}, expected: "tenants/t1/users/u1/orders/1"},
+		{name: "collection_prefix", tenancy: tenancy{separator: "_"}, expected: "t1_users/u1/t1_orders/1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			scoped := tt.tenancy.scopeKey("t1", key)
+			assert.Equal(t, tt.expected, scoped.String())
+			assert.Equal(t, "users/u1/orders/1", key.String(), "original key should not be changed")
+			assert.Equal(t, key, tt.tenancy.unscopeKey("t1", scoped))
+		})
+	}
+}
+
+func TestDBWithTenants(t *testing.T) {
+	u1 := NewKeyWithID("users", "u1")
+	newUserRecord := func() Record {
+		return NewRecordWithData(NewKeyWithID("users", "u1"), new(testHookUser))
+	}
+	usersQuery := From("users").SelectInto(func() Record {
+		return NewRecordWithIncompleteKey("users", reflect.String, new(testHookUser))
+	})
+
+	for _, tt := range []struct {
+		name     string
+		options  []TenancyOption
+		t1Key    *Key
+		t2Key    *Key
+		groupErr bool
+	}{
+		{
+			name:     "parent_keys",
+			t1Key:    NewKeyWithParentAndID(NewKeyWithID("tenants", "t1"), "users", "u1"),
+			t2Key:    NewKeyWithParentAndID(NewKeyWithID("tenants", "t2"), "users", "u1"),
+			groupErr: true,
+		},
+		{
+			name:    "collection_prefix",
+			options: []TenancyOption{TenantCollectionPrefix("_")},
+			t1Key:   NewKeyWithID("t1_users", "u1"),
+			t2Key:   NewKeyWithID("t2_users", "u1"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			memDB := newMemoryDB()
+			db := NewDBWithTenants(memDB, tt.options...)
+			ctx1 := WithTenant(context.Background(), "t1")
+			ctx2 := WithTenant(context.Background(), "t2")
+
+			err := db.RunReadwriteTransaction(ctx1, func(ctx context.Context, tx ReadwriteTransaction) error {
+				record := NewRecordWithData(NewKeyWithID("users", "u1"), &testHookUser{Name: "Ann"})
+				if err := tx.Insert(ctx, record); err != nil {
+					return err
+				}
+				assert.Equal(t, u1, record.Key(), "key of a record should not be changed")
+				return tx.Update(ctx, u1, []Update{{Field: "name", Value: "Anna"}})
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]any{"name": "Anna"}, memDB.getData(tt.t1Key))
+
+			err = db.RunReadwriteTransaction(ctx2, func(ctx context.Context, tx ReadwriteTransaction) error {
+				return tx.Set(ctx, NewRecordWithData(NewKeyWithID("users", "u1"), &testHookUser{Name: "Bob"}))
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]any{"name": "Bob"}, memDB.getData(tt.t2Key))
+
+			record := newUserRecord()
+			assert.Nil(t, db.Get(ctx1, record))
+			assert.Equal(t, "Anna", record.Data().(*testHookUser).Name)
+			assert.Equal(t, u1, record.Key())
+
+			records := []Record{newUserRecord()}
+			assert.Nil(t, db.GetMulti(ctx2, records))
+			assert.Equal(t, "Bob", records[0].Data().(*testHookUser).Name)
+
+			records, err = db.QueryAllRecords(ctx1, usersQuery)
+			assert.Nil(t, err)
+			assert.Len(t, records, 1)
+			assert.Equal(t, u1, records[0].Key())
+			assert.Equal(t, "Anna", (*records[0].Data().(*map[string]any))["name"])
+
+			_, err = db.QueryReader(ctx1, FromCollectionGroup("users").SelectKeysOnly(reflect.String))
+			if tt.groupErr {
+				assert.ErrorIs(t, err, ErrNotSupported)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			err = db.RunReadwriteTransaction(ctx2, func(ctx context.Context, tx ReadwriteTransaction) error {
+				return tx.Delete(ctx, u1)
+			})
+			assert.Nil(t, err)
+			assert.Nil(t, memDB.getData(tt.t2Key))
+			assert.NotNil(t, memDB.getData(tt.t1Key))
+		})
+	}
+}
+
+func TestDBWithTenants_collectionPrefixCollision(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	db := NewDBWithTenants(memDB, TenantCollectionPrefix("_"))
+	err := db.RunReadwriteTransaction(WithTenant(ctx, "a"), func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, NewRecordWithData(NewKeyWithID("b_users", "u1"), &testHookUser{Name: "Ann"}))
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, memDB.getData(NewKeyWithID("a_b_users", "u1")))
+
+	record := NewRecordWithData(NewKeyWithID("users", "u1"), new(testHookUser))
+	err = db.Get(WithTenant(ctx, "a_b"), record)
+	assert.ErrorIs(t, err, ErrInvalidTenant, "tenant a_b should not read records of tenant a")
+	err = db.RunReadwriteTransaction(WithTenant(ctx, "a_b"), func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(ctx, record)
+	})
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+	_, err = db.QueryReader(WithTenant(ctx, "a_b"), From("users").SelectKeysOnly(reflect.String))
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+
+	err = NewDBWithTenants(memDB).Get(WithTenant(ctx, "a_b"), record)
+	assert.True(t, IsNotFound(err), "separator is not checked in tenant parent keys mode")
+}
+
+func TestDBWithTenants_collectionGroupWithoutTenant(t *testing.T) {
+	_, err := NewDBWithTenants(newMemoryDB(), TenantCollectionPrefix("_")).
+		QueryReader(context.Background(), FromCollectionGroup("users").SelectKeysOnly(reflect.String))
+	assert.ErrorIs(t, err, ErrNoTenant)
+}
+
+func TestDBWithTenants_strict(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	memDB.putData(NewKeyWithID("users", "u1"), testHookUser{Name: "Ann"})
+
+	lax := NewDBWithTenants(memDB)
+	record := NewRecordWithData(NewKeyWithID("users", "u1"), new(testHookUser))
+	assert.Nil(t, lax.Get(ctx, record), "keys should be used as is without a tenant")
+
+	strict := NewDBWithTenants(memDB, StrictTenancy())
+	assert.ErrorIs(t, strict.Get(ctx, record), ErrNoTenant)
+	assert.ErrorIs(t, strict.GetMulti(ctx, []Record{record}), ErrNoTenant)
+	_, err := strict.QueryReader(ctx, From("users").SelectKeysOnly(reflect.String))
+	assert.ErrorIs(t, err, ErrNoTenant)
+	err = strict.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNoTenant)
+	err = strict.RunReadwriteTransaction(WithTenant(ctx, "t1"), func(ctx context.Context, tx ReadwriteTransaction) error {
+		return tx.Set(context.Background(), record)
+	})
+	assert.ErrorIs(t, err, ErrNoTenant, "should be checked for each operation")
+}
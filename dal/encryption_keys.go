@@ -0,0 +1,172 @@
+package dal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDecryptionFailed indicates an encrypted value can't be decrypted, e.g. it is corrupted or its key is unknown
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// EncryptionKeyProvider provides keys for field-level encryption, see NewDBWithEncryption().
+// Each encrypted value keeps ID of its key, so keys can be rotated by changing the current key ID
+// while previous keys are still provided for decryption of existing values.
+type EncryptionKeyProvider interface {
+
+	// CurrentKeyID returns ID of a key to be used for encryption
+	CurrentKeyID(ctx context.Context) (string, error)
+
+	// EncryptionKey returns an AES key (16, 24 or 32 bytes long) by ID
+	EncryptionKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// NewStaticEncryptionKeys creates a provider of keys kept in memory.
+// Key IDs can't contain ':' & keys should be 16, 24 or 32 bytes long (AES-128, AES-192 & AES-256).
+func NewStaticEncryptionKeys(currentKeyID string, keys map[string][]byte) EncryptionKeyProvider {
+	if _, ok := keys[currentKeyID]; !ok {
+		panic(fmt.Sprintf("no key for current key ID %q", currentKeyID))
+	}
+	provider := staticEncryptionKeys{currentKeyID: currentKeyID, keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			panic(fmt.Sprintf("invalid key ID %q", id))
+		}
+		if l := len(key); l != 16 && l != 24 && l != 32 {
+			panic(fmt.Sprintf("key %q should be 16, 24 or 32 bytes long, got %d", id, l))
+		}
+		provider.keys[id] = append([]byte(nil), key...)
+	}
+	return provider
+}
+
+type staticEncryptionKeys struct {
+	currentKeyID string
+	keys         map[string][]byte
+}
+
+func (p staticEncryptionKeys) CurrentKeyID(context.Context) (string, error) {
+	return p.currentKeyID, nil
+}
+
+func (p staticEncryptionKeys) EncryptionKey(_ context.Context, keyID string) ([]byte, error) {
+	if key, ok := p.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown encryption key %q", keyID)
+}
+
+const (
+	encryptedValuePrefix     = "dalgo:enc:"  // randomized encryption
+	deterministicValuePrefix = "dalgo:denc:" // deterministic encryption, same plaintext gives same ciphertext
+)
+
+// IsEncryptedValue checks if a string is a value encrypted by a DB created by NewDBWithEncryption()
+func IsEncryptedValue(s string) bool {
+	return strings.HasPrefix(s, encryptedValuePrefix) || strings.HasPrefix(s, deterministicValuePrefix)
+}
+
+// encryptValue encrypts a plaintext with AES-GCM into `<prefix><keyID>:<base64(nonce+ciphertext)>`.
+// A ciphertext is bound to a record & a field by additional data (see encryptionAdditionalData),
+// so an encrypted value can't be moved to another record or field.
+// A deterministic nonce is derived from the additional data & the plaintext with HMAC-SHA256 keyed by a subkey
+// of the AES key, so equal values can be compared. As a ciphertext depends on a key, deterministic equality lookups
+// match values written with the current key ID only & miss values written before a key rotation.
+func encryptValue(ctx context.Context, keys EncryptionKeyProvider, key *Key, path string, plaintext []byte, deterministic bool) (string, error) {
+	keyID, err := keys.CurrentKeyID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current encryption key ID: %w", err)
+	}
+	aead, encryptionKey, err := newAEAD(ctx, keys, keyID)
+	if err != nil {
+		return "", err
+	}
+	additionalData := encryptionAdditionalData(key, path, deterministic)
+	nonce := make([]byte, aead.NonceSize())
+	prefix := encryptedValuePrefix
+	if deterministic {
+		prefix = deterministicValuePrefix
+		mac := hmac.New(sha256.New, deterministicNonceKey(encryptionKey))
+		mac.Write(additionalData)
+		mac.Write([]byte{0})
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return prefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue reverts encryptValue()
+func decryptValue(ctx context.Context, keys EncryptionKeyProvider, key *Key, path string, s string) ([]byte, error) {
+	deterministic := false
+	rest, ok := strings.CutPrefix(s, encryptedValuePrefix)
+	if !ok {
+		if rest, ok = strings.CutPrefix(s, deterministicValuePrefix); !ok {
+			return nil, fmt.Errorf("%w: field %s: not an encrypted value", ErrDecryptionFailed, path)
+		}
+		deterministic = true
+	}
+	keyID, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: field %s: no key ID", ErrDecryptionFailed, path)
+	}
+	aead, _, err := newAEAD(ctx, keys, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: field %s: %w", ErrDecryptionFailed, path, err)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: field %s: invalid encoding", ErrDecryptionFailed, path)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, encryptionAdditionalData(key, path, deterministic))
+	if err != nil {
+		return nil, fmt.Errorf("%w: field %s: %w", ErrDecryptionFailed, path, err)
+	}
+	return plaintext, nil
+}
+
+// encryptionAdditionalData returns data a ciphertext is bound to: a canonical record key (see Key.Canonical)
+// & a field path. Deterministic ciphertexts should be equal for all records of a collection to be used in queries,
+// so they are bound to a collection instead of a record.
+func encryptionAdditionalData(key *Key, path string, deterministic bool) []byte {
+	scope := key.Canonical()
+	if deterministic {
+		scope = key.Collection()
+	}
+	return []byte(scope + "\x00" + path)
+}
+
+// deterministicNonceKey derives a MAC key for deterministic nonces from an AES key by HKDF-SHA256 (RFC 5869),
+// so the AES key is not used for anything but encryption
+func deterministicNonceKey(key []byte) []byte {
+	extract := hmac.New(sha256.New, nil) // HKDF-Extract with an empty salt
+	extract.Write(key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte("dalgo-det-nonce"))
+	expand.Write([]byte{1}) // HKDF-Expand of a single block
+	return expand.Sum(nil)
+}
+
+func newAEAD(ctx context.Context, keys EncryptionKeyProvider, keyID string) (aead cipher.AEAD, key []byte, err error) {
+	if key, err = keys.EncryptionKey(ctx, keyID); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid encryption key %q: %w", keyID, err)
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, nil, err
+	}
+	return aead, key, nil
+}
@@ -0,0 +1,110 @@
+package dal
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEncryptionKeys(currentKeyID string) EncryptionKeyProvider {
+	return NewStaticEncryptionKeys(currentKeyID, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	})
+}
+
+func TestNewStaticEncryptionKeys(t *testing.T) {
+	assert.Panics(t, func() {
+		NewStaticEncryptionKeys("k1", map[string][]byte{})
+	})
+	assert.Panics(t, func() {
+		NewStaticEncryptionKeys("k1", map[string][]byte{"k1": []byte("short")})
+	})
+	assert.Panics(t, func() {
+		NewStaticEncryptionKeys("k:1", map[string][]byte{"k:1": bytes.Repeat([]byte{1}, 16)})
+	})
+	keys := newTestEncryptionKeys("k1")
+	keyID, err := keys.CurrentKeyID(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", keyID)
+	_, err = keys.EncryptionKey(context.Background(), "unknown")
+	assert.Error(t, err)
+}
+
+func Test_encryptValue(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestEncryptionKeys("k1")
+	u1, u2 := NewKeyWithID("users", "u1"), NewKeyWithID("users", "u2")
+
+	t.Run("randomized", func(t *testing.T) {
+		v1, err := encryptValue(ctx, keys, u1, "phone", []byte("123"), false)
+		assert.Nil(t, err)
+		v2, err := encryptValue(ctx, keys, u1, "phone", []byte("123"), false)
+		assert.Nil(t, err)
+		assert.NotEqual(t, v1, v2)
+		assert.True(t, strings.HasPrefix(v1, "dalgo:enc:k1:"))
+		assert.True(t, IsEncryptedValue(v1))
+		plaintext, err := decryptValue(ctx, keys, u1, "phone", v1)
+		assert.Nil(t, err)
+		assert.Equal(t, "123", string(plaintext))
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		v1, err := encryptValue(ctx, keys, u1, "email", []byte("a@b.c"), true)
+		assert.Nil(t, err)
+		v2, err := encryptValue(ctx, keys, u1, "email", []byte("a@b.c"), true)
+		assert.Nil(t, err)
+		assert.Equal(t, v1, v2)
+		assert.True(t, strings.HasPrefix(v1, "dalgo:denc:k1:"))
+		v3, err := encryptValue(ctx, keys, u1, "email2", []byte("a@b.c"), true)
+		assert.Nil(t, err)
+		assert.NotEqual(t, v1, v3, "ciphertext should depend on field path")
+		v4, err := encryptValue(ctx, keys, u2, "email", []byte("a@b.c"), true)
+		assert.Nil(t, err)
+		assert.Equal(t, v1, v4, "ciphertext should not depend on a record of a collection")
+		v5, err := encryptValue(ctx, keys, NewKeyWithID("admins", "u1"), "email", []byte("a@b.c"), true)
+		assert.Nil(t, err)
+		assert.NotEqual(t, v1, v5, "ciphertext should depend on a collection")
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		old, err := encryptValue(ctx, keys, u1, "phone", []byte("123"), false)
+		assert.Nil(t, err)
+		rotated := newTestEncryptionKeys("k2")
+		plaintext, err := decryptValue(ctx, rotated, u1, "phone", old)
+		assert.Nil(t, err)
+		assert.Equal(t, "123", string(plaintext))
+		v, err := encryptValue(ctx, rotated, u1, "phone", []byte("123"), false)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(v, "dalgo:enc:k2:"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		v, err := encryptValue(ctx, keys, u1, "phone", []byte("123"), false)
+		assert.Nil(t, err)
+		for _, s := range []string{
+			"plain",
+			"dalgo:enc:k1",
+			"dalgo:enc:unknown:" + v[len("dalgo:enc:k1:"):],
+			"dalgo:enc:k1:%%%",
+			v[:len(v)-2],
+		} {
+			_, err = decryptValue(ctx, keys, u1, "phone", s)
+			assert.ErrorIs(t, err, ErrDecryptionFailed, s)
+		}
+		_, err = decryptValue(ctx, keys, u1, "another", v)
+		assert.ErrorIs(t, err, ErrDecryptionFailed, "should not be decrypted for another field")
+		_, err = decryptValue(ctx, keys, u2, "phone", v)
+		assert.ErrorIs(t, err, ErrDecryptionFailed, "should not be decrypted for another record")
+	})
+}
+
+func Test_deterministicNonceKey(t *testing.T) {
+	// expected value is computed by crypto/hkdf.Key(sha256.New, key, nil, "dalgo-det-nonce", 32) of Go 1.24+
+	assert.Equal(t, "d0e3e9cd81081b028dcaa3f63ff5d682eed4825dc67d954b7f4e21b36bcf2147",
+		hex.EncodeToString(deterministicNonceKey(bytes.Repeat([]byte{1}, 32))))
+}
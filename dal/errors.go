@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -16,9 +17,10 @@ var ErrNotImplementedYet = errors.New("not implemented yet")
 // ErrNoMoreRecords indicates there is no more records
 var ErrNoMoreRecords = errors.New("no more errors")
 
-// ErrDuplicateUser indicates there is a duplicate user // TODO: move to strongo/app?
+// ErrDuplicateUser indicates there is a duplicate user
+//
+// Deprecated: not related to data access and will be removed from this package, define it in your app.
 type ErrDuplicateUser struct {
-	// TODO: Should it be moved out of this package to strongo/app/user?
 	SearchCriteria   string
 	DuplicateUserIDs []string
 }
@@ -33,6 +35,25 @@ func (err ErrDuplicateUser) Error() string {
 var (
 	// ErrRecordNotFound is returned when a DB record is not found
 	ErrRecordNotFound = errors.New("record not found")
+
+	// ErrAlreadyExists is returned by Insert when a record with the same key already exists
+	ErrAlreadyExists = errors.New("record already exists")
+
+	// ErrPreconditionFailed is returned when a precondition of an operation is not met
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrTxAborted is returned when a transaction is aborted, for example due to a conflict with another transaction
+	ErrTxAborted = errors.New("transaction aborted")
+
+	// ErrUnavailable is returned when a database is temporarily unavailable
+	ErrUnavailable = errors.New("database unavailable")
+
+	// ErrDeadlineExceeded is returned when an operation has not completed in time.
+	// IsDeadlineExceeded also treats context.DeadlineExceeded as such error.
+	ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+	// ErrQuotaExceeded is returned when a quota or a rate limit of a database is exhausted
+	ErrQuotaExceeded = errors.New("quota exceeded")
 )
 
 // IsNotFound check if underlying error is ErrRecordNotFound
@@ -94,6 +115,139 @@ func errNotFoundCause(cause error) error {
 	return fmt.Errorf("%w: %v", ErrRecordNotFound, cause)
 }
 
+// ErrByKey is an error related to a record with a specific key.
+// Use errors.Is() with a sentinel error (e.g. ErrAlreadyExists) to check kind of the error.
+type ErrByKey interface {
+	Key() *Key
+	Cause() error
+	error
+}
+
+var _ ErrByKey = (*errByKey)(nil)
+
+type errByKey struct {
+	kind  error
+	key   *Key
+	cause error
+}
+
+func (e errByKey) Key() *Key {
+	return e.key
+}
+
+func (e errByKey) Cause() error {
+	if e.cause == nil {
+		return e.kind
+	}
+	return e.cause
+}
+
+func (e errByKey) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+func (e errByKey) Error() string {
+	if e.cause == nil {
+		return fmt.Sprintf("%v: key=%v", e.kind, e.key)
+	}
+	return fmt.Sprintf("%v: key=%v: %v", e.kind, e.key, e.cause)
+}
+
+func newErrByKey(kind error, key *Key, cause error) error {
+	if cause == kind {
+		cause = nil
+	}
+	return errByKey{kind: kind, key: key, cause: cause}
+}
+
+// NewErrAlreadyExists creates an error that indicates a record with the given key already exists.
+// Cause is an optional native error of a DB client.
+func NewErrAlreadyExists(key *Key, cause error) error {
+	return newErrByKey(ErrAlreadyExists, key, cause)
+}
+
+// NewErrPreconditionFailed creates an error that indicates a precondition failed for a record with the given key
+func NewErrPreconditionFailed(key *Key, cause error) error {
+	return newErrByKey(ErrPreconditionFailed, key, cause)
+}
+
+// NewErrTxAborted creates an error that indicates a transaction has been aborted.
+// Key is optional and identifies a record that caused a conflict.
+func NewErrTxAborted(key *Key, cause error) error {
+	return newErrByKey(ErrTxAborted, key, cause)
+}
+
+// NewErrUnavailable creates an error that indicates a database was unavailable during an operation on a record.
+// Key is optional.
+func NewErrUnavailable(key *Key, cause error) error {
+	return newErrByKey(ErrUnavailable, key, cause)
+}
+
+// KeyFromError returns a key of the first error in the chain that carries a key, or nil
+func KeyFromError(err error) *Key {
+	var errWithKey interface{ Key() *Key }
+	if errors.As(err, &errWithKey) {
+		return errWithKey.Key()
+	}
+	return nil
+}
+
+// IsAlreadyExists checks if an error indicates a record already exists
+func IsAlreadyExists(err error) bool {
+	return errors.Is(err, ErrAlreadyExists)
+}
+
+// IsPreconditionFailed checks if an error indicates a precondition failed
+func IsPreconditionFailed(err error) bool {
+	return errors.Is(err, ErrPreconditionFailed)
+}
+
+// IsTxAborted checks if an error indicates a transaction has been aborted
+func IsTxAborted(err error) bool {
+	return errors.Is(err, ErrTxAborted)
+}
+
+// IsUnavailable checks if an error indicates a database is unavailable
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// IsDeadlineExceeded checks if an error indicates an operation has not completed in time
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsQuotaExceeded checks if an error indicates a quota or a rate limit is exhausted
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded)
+}
+
+// RetryableError can be implemented by adapter errors to explicitly state if an operation can be retried
+type RetryableError interface {
+	Retryable() bool
+}
+
+// IsRetryable checks if an operation that returned the error can be retried.
+// An error that implements RetryableError decides for itself.
+// Aborted transactions, unavailable DB, deadline exceeded and quota exceeded errors are retryable
+// (the later should be retried with a backoff). Any other error is treated as non-retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var retryable RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return IsTxAborted(err) || IsUnavailable(err) || IsDeadlineExceeded(err) || IsQuotaExceeded(err)
+}
+
 type rollbackError struct {
 	originalErr error
 	rollbackErr error
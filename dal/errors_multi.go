@@ -0,0 +1,131 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrRecordNotProcessed indicates a record of a multi-record operation has not been processed,
+// e.g. SetError() has not been called for it.
+var ErrRecordNotProcessed = errors.New("record has not been processed")
+
+// RecordError describes a failure of a single record in a multi-record operation
+type RecordError struct {
+	Index int   // Index of the record in the slice passed to a multi-record operation
+	Key   *Key  // Key of the failed record
+	Err   error // Err is the error of the record
+}
+
+// Error implements error interface
+func (e RecordError) Error() string {
+	return fmt.Sprintf("record #%d key=%v: %v", e.Index, e.Key, e.Err)
+}
+
+// Unwrap returns the error of the record
+func (e RecordError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by multi-record operations (GetMulti, SetMulti, etc.) that partially failed.
+// It keeps an error for each failed record so callers can retry just the failed records.
+// errors.Is() & errors.As() check errors of all records.
+type MultiError struct {
+	Errors []RecordError
+}
+
+// NewMultiError creates a MultiError from errors of records, e.g. from records passed to GetMulti.
+// Not found records are not treated as failed. Returns nil if no record has failed.
+func NewMultiError(records []Record) error {
+	var multiErr MultiError
+	for i, r := range records {
+		if _, err := recordStatus(r); err != nil {
+			multiErr.Add(i, r.Key(), err)
+		}
+	}
+	return multiErr.ErrorOrNil()
+}
+
+// Add adds an error of a record
+func (e *MultiError) Add(index int, key *Key, err error) {
+	e.Errors = append(e.Errors, RecordError{Index: index, Key: key, Err: err})
+}
+
+// ErrorOrNil returns nil if there are no errors, otherwise returns the MultiError
+func (e *MultiError) ErrorOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Keys returns keys of failed records
+func (e *MultiError) Keys() []*Key {
+	keys := make([]*Key, len(e.Errors))
+	for i, recordErr := range e.Errors {
+		keys[i] = recordErr.Key
+	}
+	return keys
+}
+
+// Error implements error interface
+func (e *MultiError) Error() string {
+	switch len(e.Errors) {
+	case 0:
+		return "no errors"
+	case 1:
+		return fmt.Sprintf("1 record failed: %v", e.Errors[0])
+	}
+	s := make([]string, len(e.Errors))
+	for i, recordErr := range e.Errors {
+		s[i] = recordErr.Error()
+	}
+	return fmt.Sprintf("%d records failed: %s", len(e.Errors), strings.Join(s, "; "))
+}
+
+// Unwrap returns errors of all failed records, used by errors.Is() & errors.As()
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, recordErr := range e.Errors {
+		errs[i] = recordErr
+	}
+	return errs
+}
+
+// SplitRecordsByError splits records by their state after a multi-record operation
+// into succeeded, not found & failed ones.
+// A record for which SetError() has not been called is treated as failed.
+func SplitRecordsByError(records []Record) (succeeded, notFound, failed []Record) {
+	for _, r := range records {
+		exists, err := recordStatus(r)
+		switch {
+		case err != nil:
+			failed = append(failed, r)
+		case exists:
+			succeeded = append(succeeded, r)
+		default:
+			notFound = append(notFound, r)
+		}
+	}
+	return
+}
+
+// FailedRecords returns records that have failed in a multi-record operation
+func FailedRecords(records []Record) (failed []Record) {
+	_, _, failed = SplitRecordsByError(records)
+	return
+}
+
+// recordStatus returns error of a record and if it exists.
+// Record.Exists() panics for records that have not been processed yet.
+func recordStatus(r Record) (exists bool, err error) {
+	if err = r.Error(); err != nil {
+		return false, err
+	}
+	defer func() {
+		if recover() != nil {
+			exists, err = false, ErrRecordNotProcessed
+		}
+	}()
+	return r.Exists(), nil
+}
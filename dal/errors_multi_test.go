@@ -0,0 +1,71 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecordError struct {
+	code int
+}
+
+func (e testRecordError) Error() string {
+	return "test record error"
+}
+
+func TestMultiError(t *testing.T) {
+	var multiErr MultiError
+	assert.Nil(t, multiErr.ErrorOrNil())
+	assert.Equal(t, "no errors", multiErr.Error())
+
+	key1, key2 := NewKeyWithID("c1", "k1"), NewKeyWithID("c1", "k2")
+	errTimeout := errors.New("timeout")
+	multiErr.Add(1, key1, errTimeout)
+	assert.Equal(t, "1 record failed: record #1 key=c1/k1: timeout", multiErr.Error())
+
+	multiErr.Add(3, key2, testRecordError{code: 7})
+	err := multiErr.ErrorOrNil()
+	assert.Equal(t, "2 records failed: record #1 key=c1/k1: timeout; record #3 key=c1/k2: test record error", err.Error())
+	assert.True(t, errors.Is(err, errTimeout))
+	var recordErr testRecordError
+	assert.True(t, errors.As(err, &recordErr))
+	assert.Equal(t, 7, recordErr.code)
+	var re RecordError
+	assert.True(t, errors.As(err, &re))
+	assert.Equal(t, 1, re.Index)
+	assert.Equal(t, []*Key{key1, key2}, multiErr.Keys())
+}
+
+func TestNewMultiError(t *testing.T) {
+	errFailed := errors.New("failed")
+	records := []Record{
+		NewRecord(NewKeyWithID("c1", "ok")).SetError(nil),
+		NewRecord(NewKeyWithID("c1", "missing")).SetError(NewErrNotFoundByKey(NewKeyWithID("c1", "missing"), nil)),
+		NewRecord(NewKeyWithID("c1", "failed")).SetError(errFailed),
+		NewRecord(NewKeyWithID("c1", "unprocessed")),
+	}
+	err := NewMultiError(records)
+	var multiErr *MultiError
+	assert.True(t, errors.As(err, &multiErr))
+	assert.Len(t, multiErr.Errors, 2)
+	assert.Equal(t, 2, multiErr.Errors[0].Index)
+	assert.True(t, errors.Is(err, errFailed))
+	assert.True(t, errors.Is(err, ErrRecordNotProcessed))
+
+	assert.Nil(t, NewMultiError(records[:2]))
+}
+
+func TestSplitRecordsByError(t *testing.T) {
+	ok := NewRecord(NewKeyWithID("c1", "ok")).SetError(nil)
+	missing := NewRecord(NewKeyWithID("c1", "missing")).SetError(ErrRecordNotFound)
+	failed := NewRecord(NewKeyWithID("c1", "failed")).SetError(errors.New("failed"))
+	unprocessed := NewRecord(NewKeyWithID("c1", "unprocessed"))
+
+	succeeded, notFound, failedRecords := SplitRecordsByError([]Record{ok, missing, failed, unprocessed})
+	assert.Equal(t, []Record{ok}, succeeded)
+	assert.Equal(t, []Record{missing}, notFound)
+	assert.Equal(t, []Record{failed, unprocessed}, failedRecords)
+	assert.Equal(t, []Record{failed, unprocessed}, FailedRecords([]Record{ok, failed, unprocessed}))
+}
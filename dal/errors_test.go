@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
@@ -244,3 +245,76 @@ func TestRollbackError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestErrByKey(t *testing.T) {
+	key := NewKeyWithID("Foo", "bar")
+	nativeErr := errors.New("native error")
+	for _, tt := range []struct {
+		name     string
+		newErr   func(key *Key, cause error) error
+		kind     error
+		is       func(err error) bool
+		expected string
+	}{
+		{"already_exists", NewErrAlreadyExists, ErrAlreadyExists, IsAlreadyExists, "record already exists: key=Foo/bar"},
+		{"precondition_failed", NewErrPreconditionFailed, ErrPreconditionFailed, IsPreconditionFailed, "precondition failed: key=Foo/bar"},
+		{"tx_aborted", NewErrTxAborted, ErrTxAborted, IsTxAborted, "transaction aborted: key=Foo/bar"},
+		{"unavailable", NewErrUnavailable, ErrUnavailable, IsUnavailable, "database unavailable: key=Foo/bar"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.newErr(key, nil)
+			assert.Equal(t, tt.expected, err.Error())
+			assert.True(t, tt.is(fmt.Errorf("wrapped: %w", err)))
+			assert.False(t, IsNotFound(err))
+			assert.Equal(t, key, KeyFromError(fmt.Errorf("wrapped: %w", err)))
+			var errWithKey ErrByKey
+			assert.True(t, errors.As(err, &errWithKey))
+			assert.Equal(t, tt.kind, errWithKey.Cause())
+
+			err = tt.newErr(key, nativeErr)
+			assert.Equal(t, tt.expected+": native error", err.Error())
+			assert.True(t, tt.is(err))
+			assert.True(t, errors.Is(err, nativeErr))
+
+			assert.Equal(t, tt.expected, tt.newErr(key, tt.kind).Error())
+		})
+	}
+	assert.Nil(t, KeyFromError(errors.New("no key")))
+	assert.Equal(t, key, KeyFromError(NewErrNotFoundByKey(key, nil)))
+}
+
+type retryableTestError bool
+
+func (e retryableTestError) Error() string {
+	return "retryable test error"
+}
+
+func (e retryableTestError) Retryable() bool {
+	return bool(e)
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"unknown", errors.New("unknown"), false},
+		{"not_found", ErrRecordNotFound, false},
+		{"already_exists", NewErrAlreadyExists(nil, nil), false},
+		{"precondition_failed", ErrPreconditionFailed, false},
+		{"context_canceled", context.Canceled, false},
+		{"tx_aborted", NewErrTxAborted(nil, nil), true},
+		{"unavailable", fmt.Errorf("wrapped: %w", ErrUnavailable), true},
+		{"deadline_exceeded", ErrDeadlineExceeded, true},
+		{"context_deadline_exceeded", context.DeadlineExceeded, true},
+		{"quota_exceeded", ErrQuotaExceeded, true},
+		{"explicitly_retryable", retryableTestError(true), true},
+		{"explicitly_not_retryable", fmt.Errorf("%w: %w", retryableTestError(false), ErrUnavailable), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsRetryable(tt.err))
+		})
+	}
+}
@@ -9,34 +9,57 @@ type ValidatableRecord interface {
 	Validate() error
 }
 
-var beforeSafeHooks []RecordHook
-
+// BeforeSave validates record data (see ValidateData & ValidatableRecord) and calls BeforeSave hooks of the data
+// (see RecordBeforeSaveHook & WithBeforeSave). It is called automatically by a DB created with NewDBWithHooks.
 func BeforeSave(ctx context.Context, db DB, record Record) error {
 	if err := beforeSafe(ctx, db, record); err != nil {
 		return err
 	}
-	return callRecordHooks(ctx, record, beforeSafeHooks)
+	return nil
 }
 
-func callRecordHooks(ctx context.Context, record Record, hooks []RecordHook) error {
-	//errs := make([]error, 0, len(hooks))
-	for _, hook := range hooks {
-		if err := hook(ctx, record); err != nil {
-			return fmt.Errorf("%w: %v", ErrHookFailed, err)
+// AfterLoad calls AfterLoad hooks of record data (see RecordAfterLoadHook & WithAfterLoad).
+// It is called automatically by a DB created with NewDBWithHooks.
+func AfterLoad(ctx context.Context, db DB, record Record) error {
+	switch data := recordDataOrNil(record).(type) {
+	case recordDataAfterLoadHook:
+		if err := data.AfterLoad(ctx, db, record.Key()); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookFailed, err)
+		}
+	case RecordAfterLoadHook:
+		if err := data.AfterLoad(ctx, record.Key()); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookFailed, err)
 		}
 	}
-	//if len(errs) > 0 {
-	//	return fmt.Errorf("%w: %v", ErrHookFailed, errors.Join(errs...))
-	//}
 	return nil
 }
 
-func beforeSafe(_ context.Context, _ DB, record Record) error {
-	data := record.Data()
+func beforeSafe(ctx context.Context, db DB, record Record) error {
+	data := recordDataOrNil(record)
+	if err := ValidateData(record.Key(), data); err != nil {
+		return err
+	}
+	if wrapper, ok := data.(DataWrapper); ok {
+		if validatable, ok := wrapper.Data().(ValidatableRecord); ok {
+			if err := validatable.Validate(); err != nil {
+				return err
+			}
+		}
+	}
 	if validatable, ok := data.(ValidatableRecord); ok {
 		if err := validatable.Validate(); err != nil {
 			return err
 		}
 	}
+	switch data := data.(type) {
+	case recordDataBeforeSaveHook:
+		if err := data.BeforeSave(ctx, db, record.Key()); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookFailed, err)
+		}
+	case RecordBeforeSaveHook:
+		if err := data.BeforeSave(ctx, record.Key()); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookFailed, err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,274 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// UpdateHook is called before or after update of a record
+type UpdateHook = func(ctx context.Context, key *Key, updates []Update) error
+
+// KeyHook is called before or after an operation that has just a key, e.g. delete
+type KeyHook = func(ctx context.Context, key *Key) error
+
+// CommitHook is called after a successful commit of a readwrite transaction
+// with keys of records that have been inserted, set, updated or deleted within the transaction.
+type CommitHook = func(ctx context.Context, keys []*Key) error
+
+// HookScope limits hooks to records of a specific collection or a specific data type.
+// Hooks registered without a scope are global and called for all records.
+type HookScope struct {
+	collection string
+	dataType   reflect.Type
+}
+
+// HookForCollection creates a scope that matches records of the given collection
+func HookForCollection(collection string) HookScope {
+	if collection == "" {
+		panic("collection is a required parameter, got empty string")
+	}
+	return HookScope{collection: collection}
+}
+
+// HookForDataType creates a scope that matches records with data of the same type as the given example.
+// Pointers are dereferenced, so HookForDataType(User{}) & HookForDataType(&User{}) are the same.
+// As updates & deletes do not have record data, such hooks are called only for operations on records with data.
+func HookForDataType(example any) HookScope {
+	t := reflect.TypeOf(example)
+	if t == nil {
+		panic("example is a required parameter, got nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return HookScope{dataType: t}
+}
+
+func (s HookScope) matches(key *Key, dataType reflect.Type) bool {
+	if s.collection != "" && (key == nil || key.Collection() != s.collection) {
+		return false
+	}
+	if s.dataType != nil && s.dataType != dataType {
+		return false
+	}
+	return true
+}
+
+type scopedHook[T any] struct {
+	hook   T
+	scopes []HookScope
+}
+
+func (h scopedHook[T]) matches(key *Key, dataType reflect.Type) bool {
+	if len(h.scopes) == 0 {
+		return true
+	}
+	for _, scope := range h.scopes {
+		if scope.matches(key, dataType) {
+			return true
+		}
+	}
+	return false
+}
+
+type recordHookEvent int
+
+const (
+	beforeInsertEvent recordHookEvent = iota
+	afterInsertEvent
+	beforeSetEvent
+	afterSetEvent
+	afterLoadEvent
+)
+
+// HookRegistry keeps hooks to be called by a DB wrapper created with NewDBWithHooks.
+// Each hook can be registered globally (without scopes) or for records matching any of the given scopes.
+// Hooks are called in order of registration, the first failed hook stops an operation.
+// It is safe to register hooks concurrently with running operations.
+type HookRegistry struct {
+	mutex        sync.RWMutex
+	recordHooks  map[recordHookEvent][]scopedHook[RecordHook]
+	beforeUpdate []scopedHook[UpdateHook]
+	afterUpdate  []scopedHook[UpdateHook]
+	beforeDelete []scopedHook[KeyHook]
+	afterDelete  []scopedHook[KeyHook]
+	afterCommit  []scopedHook[CommitHook]
+}
+
+// NewHookRegistry creates an empty hook registry
+func NewHookRegistry() *HookRegistry {
+	return &HookRegistry{
+		recordHooks: make(map[recordHookEvent][]scopedHook[RecordHook]),
+	}
+}
+
+func (r *HookRegistry) addRecordHook(event recordHookEvent, hook RecordHook, scopes []HookScope) {
+	if hook == nil {
+		panic("hook is a required parameter, got nil")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recordHooks[event] = append(r.recordHooks[event], scopedHook[RecordHook]{hook: hook, scopes: scopes})
+}
+
+func addHook[T any](r *HookRegistry, hooks *[]scopedHook[T], hook T, scopes []HookScope) {
+	if reflect.ValueOf(hook).IsNil() {
+		panic("hook is a required parameter, got nil")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	*hooks = append(*hooks, scopedHook[T]{hook: hook, scopes: scopes})
+}
+
+// BeforeInsert registers a hook to be called before a record is inserted
+func (r *HookRegistry) BeforeInsert(hook RecordHook, scopes ...HookScope) {
+	r.addRecordHook(beforeInsertEvent, hook, scopes)
+}
+
+// AfterInsert registers a hook to be called after a record is inserted
+func (r *HookRegistry) AfterInsert(hook RecordHook, scopes ...HookScope) {
+	r.addRecordHook(afterInsertEvent, hook, scopes)
+}
+
+// BeforeSet registers a hook to be called before a record is set
+func (r *HookRegistry) BeforeSet(hook RecordHook, scopes ...HookScope) {
+	r.addRecordHook(beforeSetEvent, hook, scopes)
+}
+
+// AfterSet registers a hook to be called after a record is set
+func (r *HookRegistry) AfterSet(hook RecordHook, scopes ...HookScope) {
+	r.addRecordHook(afterSetEvent, hook, scopes)
+}
+
+// AfterLoad registers a hook to be called after a record is loaded by Get, GetMulti or a query
+func (r *HookRegistry) AfterLoad(hook RecordHook, scopes ...HookScope) {
+	r.addRecordHook(afterLoadEvent, hook, scopes)
+}
+
+// BeforeUpdate registers a hook to be called before a record is updated.
+// The hook can't modify the slice of updates.
+func (r *HookRegistry) BeforeUpdate(hook UpdateHook, scopes ...HookScope) {
+	addHook(r, &r.beforeUpdate, hook, scopes)
+}
+
+// AfterUpdate registers a hook to be called after a record is updated
+func (r *HookRegistry) AfterUpdate(hook UpdateHook, scopes ...HookScope) {
+	addHook(r, &r.afterUpdate, hook, scopes)
+}
+
+// BeforeDelete registers a hook to be called before a record is deleted
+func (r *HookRegistry) BeforeDelete(hook KeyHook, scopes ...HookScope) {
+	addHook(r, &r.beforeDelete, hook, scopes)
+}
+
+// AfterDelete registers a hook to be called after a record is deleted
+func (r *HookRegistry) AfterDelete(hook KeyHook, scopes ...HookScope) {
+	addHook(r, &r.afterDelete, hook, scopes)
+}
+
+// AfterCommit registers a hook to be called after a readwrite transaction is committed.
+// A scoped hook receives only matching keys and is not called if no matching records were changed.
+func (r *HookRegistry) AfterCommit(hook CommitHook, scopes ...HookScope) {
+	addHook(r, &r.afterCommit, hook, scopes)
+}
+
+func (r *HookRegistry) callRecordHooks(ctx context.Context, event recordHookEvent, record Record) error {
+	r.mutex.RLock()
+	hooks := r.recordHooks[event]
+	r.mutex.RUnlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+	dataType := recordDataType(record)
+	for _, h := range hooks {
+		if h.matches(record.Key(), dataType) {
+			if err := h.hook(ctx, record); err != nil {
+				return fmt.Errorf("%w: key=%v: %w", ErrHookFailed, record.Key(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *HookRegistry) callUpdateHooks(ctx context.Context, registered *[]scopedHook[UpdateHook], key *Key, updates []Update) error {
+	r.mutex.RLock()
+	hooks := *registered
+	r.mutex.RUnlock()
+	for _, h := range hooks {
+		if h.matches(key, nil) {
+			if err := h.hook(ctx, key, updates); err != nil {
+				return fmt.Errorf("%w: key=%v: %w", ErrHookFailed, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *HookRegistry) callKeyHooks(ctx context.Context, registered *[]scopedHook[KeyHook], key *Key) error {
+	r.mutex.RLock()
+	hooks := *registered
+	r.mutex.RUnlock()
+	for _, h := range hooks {
+		if h.matches(key, nil) {
+			if err := h.hook(ctx, key); err != nil {
+				return fmt.Errorf("%w: key=%v: %w", ErrHookFailed, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// changedRecord is a key of a record changed in a transaction with type of its data if known
+type changedRecord struct {
+	key      *Key
+	dataType reflect.Type
+}
+
+func (r *HookRegistry) callCommitHooks(ctx context.Context, changed []changedRecord) error {
+	r.mutex.RLock()
+	hooks := r.afterCommit
+	r.mutex.RUnlock()
+	for _, h := range hooks {
+		var keys []*Key
+		for _, c := range changed {
+			if h.matches(c.key, c.dataType) {
+				keys = append(keys, c.key)
+			}
+		}
+		if len(keys) == 0 && len(h.scopes) > 0 {
+			continue
+		}
+		if err := h.hook(ctx, keys); err != nil {
+			return fmt.Errorf("%w: after commit: %w", ErrHookFailed, err)
+		}
+	}
+	return nil
+}
+
+// recordDataType returns type of record data with pointers dereferenced, or nil if data is not available
+func recordDataType(r Record) reflect.Type {
+	data := recordDataOrNil(r)
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// recordDataOrNil returns record data or nil if data is not accessible, e.g. record has an error
+func recordDataOrNil(r Record) (data any) {
+	if v, ok := r.(*record); ok {
+		return v.data
+	}
+	defer func() {
+		if recover() != nil {
+			data = nil
+		}
+	}()
+	return r.Data()
+}
@@ -0,0 +1,97 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testHookUser struct {
+	Name string `json:"name"`
+}
+
+func TestHookScope(t *testing.T) {
+	assert.Panics(t, func() {
+		HookForCollection("")
+	})
+	assert.Panics(t, func() {
+		HookForDataType(nil)
+	})
+	key := NewKeyWithID("users", "u1")
+	userType := reflect.TypeOf(testHookUser{})
+	assert.True(t, HookForCollection("users").matches(key, nil))
+	assert.False(t, HookForCollection("orders").matches(key, nil))
+	assert.True(t, HookForDataType(&testHookUser{}).matches(key, userType))
+	assert.False(t, HookForDataType(testHookUser{}).matches(key, nil))
+	assert.False(t, HookForDataType(map[string]any{}).matches(key, userType))
+}
+
+func TestHookRegistry_callRecordHooks(t *testing.T) {
+	ctx := context.Background()
+	registry := NewHookRegistry()
+	assert.Panics(t, func() {
+		registry.BeforeInsert(nil)
+	})
+	assert.Panics(t, func() {
+		registry.BeforeUpdate(nil)
+	})
+	var called []string
+	hook := func(name string) RecordHook {
+		return func(ctx context.Context, record Record) error {
+			called = append(called, name)
+			return nil
+		}
+	}
+	registry.BeforeInsert(hook("global"))
+	registry.BeforeInsert(hook("users"), HookForCollection("users"))
+	registry.BeforeInsert(hook("user_data"), HookForDataType(testHookUser{}))
+	registry.BeforeInsert(hook("orders_or_users"), HookForCollection("orders"), HookForCollection("users"))
+	registry.AfterInsert(hook("after"))
+
+	user := NewRecordWithData(NewKeyWithID("users", "u1"), &testHookUser{})
+	assert.Nil(t, registry.callRecordHooks(ctx, beforeInsertEvent, user))
+	assert.Equal(t, []string{"global", "users", "user_data", "orders_or_users"}, called)
+
+	called = nil
+	other := NewRecordWithData(NewKeyWithID("others", "o1"), MakeRecordData(&testHookUser{}))
+	assert.Nil(t, registry.callRecordHooks(ctx, beforeInsertEvent, other))
+	assert.Equal(t, []string{"global", "user_data"}, called)
+
+	errFailed := errors.New("failed")
+	registry.BeforeSet(func(ctx context.Context, record Record) error {
+		return errFailed
+	})
+	err := registry.callRecordHooks(ctx, beforeSetEvent, user)
+	assert.ErrorIs(t, err, ErrHookFailed)
+	assert.ErrorIs(t, err, errFailed)
+}
+
+func TestHookRegistry_callCommitHooks(t *testing.T) {
+	registry := NewHookRegistry()
+	calls := make(map[string][]*Key)
+	hook := func(name string) CommitHook {
+		return func(ctx context.Context, keys []*Key) error {
+			calls[name] = keys
+			return nil
+		}
+	}
+	registry.AfterCommit(hook("global"))
+	registry.AfterCommit(hook("users"), HookForCollection("users"))
+	registry.AfterCommit(hook("none"), HookForCollection("none"))
+	registry.AfterCommit(hook("user_data"), HookForDataType(testHookUser{}))
+
+	userKey, orderKey := NewKeyWithID("users", "u1"), NewKeyWithID("orders", "o1")
+	err := registry.callCommitHooks(context.Background(), []changedRecord{
+		{key: userKey, dataType: reflect.TypeOf(testHookUser{})},
+		{key: orderKey},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]*Key{
+		"global":    {userKey, orderKey},
+		"users":     {userKey},
+		"user_data": {userKey},
+	}, calls)
+}
@@ -0,0 +1,104 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultSnowflakeEpoch is a default epoch for Snowflake IDs - 2020-01-01T00:00:00Z
+var DefaultSnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	snowflakeTimestampBits = 41
+	snowflakeNodeBits      = 10
+	snowflakeSequenceBits  = 12
+
+	// MaxSnowflakeNodeID is a maximum node ID of a Snowflake generator
+	MaxSnowflakeNodeID = 1<<snowflakeNodeBits - 1
+
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+)
+
+// SnowflakeGenerator generates Snowflake-style int64 IDs:
+// 41 bits of milliseconds since an epoch, 10 bits of a node ID and 12 bits of a sequence number.
+// IDs are monotonic within a generator even if clock moves backwards.
+// Use a distinct node ID for each process that generates IDs for the same collection.
+type SnowflakeGenerator struct {
+	mutex    sync.Mutex
+	nodeID   int64
+	epoch    time.Time
+	now      func() time.Time
+	lastMs   int64
+	sequence int64
+}
+
+// SnowflakeOption defines an option for a SnowflakeGenerator
+type SnowflakeOption func(g *SnowflakeGenerator)
+
+// SnowflakeEpoch sets a custom epoch for a Snowflake generator. Default is DefaultSnowflakeEpoch.
+func SnowflakeEpoch(epoch time.Time) SnowflakeOption {
+	return func(g *SnowflakeGenerator) {
+		g.epoch = epoch
+	}
+}
+
+// NewSnowflakeGenerator creates a Snowflake ID generator for a node
+func NewSnowflakeGenerator(nodeID int64, options ...SnowflakeOption) (*SnowflakeGenerator, error) {
+	if nodeID < 0 || nodeID > MaxSnowflakeNodeID {
+		return nil, fmt.Errorf("snowflake node ID should be in range [0, %d], got %d", MaxSnowflakeNodeID, nodeID)
+	}
+	g := &SnowflakeGenerator{nodeID: nodeID, epoch: DefaultSnowflakeEpoch, now: time.Now, lastMs: -1}
+	for _, o := range options {
+		o(g)
+	}
+	if g.now().Before(g.epoch) {
+		return nil, fmt.Errorf("snowflake epoch is in the future: %v", g.epoch)
+	}
+	return g, nil
+}
+
+// NextID returns a new Snowflake ID
+func (g *SnowflakeGenerator) NextID() (int64, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	ms := g.now().Sub(g.epoch).Milliseconds()
+	if ms <= g.lastMs {
+		// Same millisecond (or clock moved backwards) - increment sequence to keep IDs monotonic
+		if g.sequence++; g.sequence > snowflakeMaxSequence {
+			g.lastMs++ // sequence overflow, borrow next millisecond
+			g.sequence = 0
+		}
+	} else {
+		g.lastMs = ms
+		g.sequence = 0
+	}
+	if g.lastMs >= 1<<snowflakeTimestampBits {
+		return 0, fmt.Errorf("snowflake timestamp overflow: epoch %v is too far in the past", g.epoch)
+	}
+	return g.lastMs<<(snowflakeNodeBits+snowflakeSequenceBits) | g.nodeID<<snowflakeSequenceBits | g.sequence, nil
+}
+
+// GenerateID is an IDGenerator that sets a new Snowflake ID as an ID of a record key
+func (g *SnowflakeGenerator) GenerateID(_ context.Context, record Record) error {
+	id, err := g.NextID()
+	if err != nil {
+		return err
+	}
+	key := record.Key()
+	key.ID = id
+	key.IDKind = reflect.Int64
+	return nil
+}
+
+// WithSnowflakeID sets a new Snowflake ID as a key ID
+func WithSnowflakeID(g *SnowflakeGenerator) KeyOption {
+	if g == nil {
+		panic("g is a required parameter, got nil")
+	}
+	return func(key *Key) error {
+		return WithIDGenerator(context.Background(), g.GenerateID)(key)
+	}
+}
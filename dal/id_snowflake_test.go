@@ -0,0 +1,78 @@
+package dal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSnowflakeGenerator(t *testing.T) {
+	t.Run("invalid_node_id", func(t *testing.T) {
+		_, err := NewSnowflakeGenerator(-1)
+		assert.ErrorContains(t, err, "node ID")
+		_, err = NewSnowflakeGenerator(MaxSnowflakeNodeID + 1)
+		assert.ErrorContains(t, err, "node ID")
+	})
+	t.Run("epoch_in_future", func(t *testing.T) {
+		_, err := NewSnowflakeGenerator(1, SnowflakeEpoch(time.Now().Add(time.Hour)))
+		assert.ErrorContains(t, err, "epoch is in the future")
+	})
+	t.Run("should_pass", func(t *testing.T) {
+		g, err := NewSnowflakeGenerator(1)
+		assert.Nil(t, err)
+		id, err := g.NextID()
+		assert.Nil(t, err)
+		assert.Greater(t, id, int64(0))
+	})
+}
+
+func TestSnowflakeGenerator_NextID(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := epoch.Add(5 * time.Millisecond)
+	g, err := NewSnowflakeGenerator(3, SnowflakeEpoch(epoch))
+	assert.Nil(t, err)
+	g.now = func() time.Time { return now }
+
+	first, err := g.NextID()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5<<22|3<<12), first)
+
+	second, _ := g.NextID()
+	assert.Equal(t, first+1, second, "sequence is incremented within the same millisecond")
+
+	now = now.Add(-time.Second) // clock moved backwards
+	third, _ := g.NextID()
+	assert.Equal(t, second+1, third)
+
+	g.sequence = snowflakeMaxSequence
+	overflow, _ := g.NextID()
+	assert.Equal(t, int64(6<<22|3<<12), overflow, "borrows next millisecond on sequence overflow")
+
+	now = epoch.Add(time.Second)
+	next, _ := g.NextID()
+	assert.Equal(t, int64(1000<<22|3<<12), next)
+
+	t.Run("timestamp_overflow", func(t *testing.T) {
+		g.lastMs = 1<<snowflakeTimestampBits - 1
+		g.sequence = snowflakeMaxSequence
+		_, err := g.NextID()
+		assert.ErrorContains(t, err, "timestamp overflow")
+	})
+}
+
+func TestWithSnowflakeID(t *testing.T) {
+	assert.Panics(t, func() {
+		WithSnowflakeID(nil)
+	})
+	g, _ := NewSnowflakeGenerator(1)
+	key, err := NewKeyWithOptions("c1", WithSnowflakeID(g))
+	assert.Nil(t, err)
+	assert.Equal(t, reflect.Int64, key.IDKind)
+	assert.IsType(t, int64(0), key.ID)
+
+	g.lastMs = 1<<snowflakeTimestampBits - 1
+	g.sequence = snowflakeMaxSequence
+	assert.NotNil(t, g.GenerateID(context.Background(), NewRecord(NewKeyWithID("c1", 1))))
+}
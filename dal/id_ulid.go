@@ -0,0 +1,94 @@
+package dal
+
+import (
+	"context"
+	"crypto/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// crockfordBase32 is an alphabet used by ULID, it's in ASCII order so encoded ULIDs are sortable as strings
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator generates ULIDs that are monotonic within a millisecond
+type ulidGenerator struct {
+	mutex   sync.Mutex
+	now     func() time.Time
+	lastMs  uint64
+	entropy [10]byte
+}
+
+var defaultULIDGenerator = &ulidGenerator{now: time.Now}
+
+// NewULID returns a new ULID (https://github.com/ulid/spec) - a 26 characters string
+// that starts with a millisecond timestamp, so ULIDs are sorted by time of generation.
+// ULIDs generated within the same millisecond are monotonically increasing.
+func NewULID() string {
+	return defaultULIDGenerator.next()
+}
+
+func (g *ulidGenerator) next() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond (or clock moved backwards) - increment entropy to keep IDs monotonic
+		if incrementBytes(g.entropy[:]) {
+			g.lastMs++ // entropy overflow, borrow next millisecond
+		}
+	} else {
+		g.lastMs = ms
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			panic(err)
+		}
+	}
+	var b [16]byte
+	for i := 0; i < 6; i++ {
+		b[i] = byte(g.lastMs >> (40 - 8*i))
+	}
+	copy(b[6:], g.entropy[:])
+	return encodeULID(b)
+}
+
+// incrementBytes increments a big-endian number and returns true on overflow
+func incrementBytes(b []byte) (overflow bool) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID encodes 128 bits as 26 characters of Crockford's base32
+func encodeULID(b [16]byte) string {
+	var s [26]byte
+	// 128 bits are encoded as 130 bits with 2 leading zero bits
+	hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 |
+		uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+	lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 |
+		uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+	for i := 25; i >= 0; i-- {
+		s[i] = crockfordBase32[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// GenerateULID is an IDGenerator that sets a new ULID as an ID of a record key
+func GenerateULID(_ context.Context, record Record) error {
+	key := record.Key()
+	key.ID = NewULID()
+	key.IDKind = reflect.String
+	return nil
+}
+
+// WithULID sets a new ULID as a key ID (see NewULID)
+func WithULID() KeyOption {
+	return func(key *Key) error {
+		return WithIDGenerator(context.Background(), GenerateULID)(key)
+	}
+}
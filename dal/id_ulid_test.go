@@ -0,0 +1,64 @@
+package dal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewULID(t *testing.T) {
+	id := NewULID()
+	assert.Equal(t, 26, len(id))
+	for _, c := range id {
+		assert.True(t, strings.ContainsRune(crockfordBase32, c), "unexpected char %c", c)
+	}
+}
+
+func TestULIDGenerator_monotonic(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	g := &ulidGenerator{now: func() time.Time { return now }}
+	ids := make([]string, 0, 100)
+	for i := 0; i < 50; i++ {
+		ids = append(ids, g.next())
+	}
+	now = now.Add(-time.Second) // clock moved backwards
+	for i := 0; i < 50; i++ {
+		ids = append(ids, g.next())
+	}
+	assert.True(t, sort.StringsAreSorted(ids))
+	assert.Equal(t, ids[0][:10], ids[99][:10], "same timestamp part")
+
+	t.Run("entropy_overflow", func(t *testing.T) {
+		for i := range g.entropy {
+			g.entropy[i] = 0xFF
+		}
+		lastMs := g.lastMs
+		id := g.next()
+		assert.Equal(t, lastMs+1, g.lastMs)
+		assert.True(t, id > ids[99])
+	})
+}
+
+func TestEncodeULID(t *testing.T) {
+	assert.Equal(t, "00000000000000000000000000", encodeULID([16]byte{}))
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xFF
+	}
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID(max))
+}
+
+func TestWithULID(t *testing.T) {
+	key, err := NewKeyWithOptions("c1", WithULID())
+	assert.Nil(t, err)
+	assert.Equal(t, reflect.String, key.IDKind)
+	assert.Equal(t, 26, len(key.ID.(string)))
+
+	record := NewRecord(NewKeyWithID("c1", "x"))
+	assert.Nil(t, GenerateULID(context.Background(), record))
+	assert.NotEqual(t, "x", record.Key().ID)
+}
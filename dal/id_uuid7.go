@@ -0,0 +1,86 @@
+package dal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// uuidV7Generator generates UUIDv7 values that are monotonic within a millisecond.
+// It uses 12 bits of rand_a field as a counter (method 1 of RFC 9562, section 6.2).
+type uuidV7Generator struct {
+	mutex   sync.Mutex
+	now     func() time.Time
+	lastMs  uint64
+	counter uint16
+}
+
+var defaultUUIDv7Generator = &uuidV7Generator{now: time.Now}
+
+// NewUUIDv7 returns a new UUID version 7 (RFC 9562) in a canonical 36 characters form.
+// It starts with a millisecond timestamp, so values are sorted by time of generation.
+// UUIDs generated within the same millisecond are monotonically increasing.
+func NewUUIDv7() string {
+	return defaultUUIDv7Generator.next()
+}
+
+func (g *uuidV7Generator) next() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	g.mutex.Lock()
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs {
+		g.counter++
+		if g.counter > 0xFFF {
+			g.lastMs++ // counter overflow, borrow next millisecond
+			g.counter = 0
+		}
+	} else {
+		g.lastMs = ms
+		g.counter = uint16(b[6]&0x07)<<8 | uint16(b[7]) // random seed with the highest bit clear
+	}
+	ms, counter := g.lastMs, g.counter
+	g.mutex.Unlock()
+
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	b[6] = 0x70 | byte(counter>>8) // version 7
+	b[7] = byte(counter)
+	b[8] = b[8]&0x3F | 0x80 // variant 10
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// GenerateUUIDv7 is an IDGenerator that sets a new UUIDv7 as an ID of a record key
+func GenerateUUIDv7(_ context.Context, record Record) error {
+	key := record.Key()
+	key.ID = NewUUIDv7()
+	key.IDKind = reflect.String
+	return nil
+}
+
+// WithUUIDv7 sets a new UUIDv7 as a key ID (see NewUUIDv7)
+func WithUUIDv7() KeyOption {
+	return func(key *Key) error {
+		return WithIDGenerator(context.Background(), GenerateUUIDv7)(key)
+	}
+}
@@ -0,0 +1,44 @@
+package dal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+var reUUIDv7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUIDv7(t *testing.T) {
+	id := NewUUIDv7()
+	assert.Regexp(t, reUUIDv7, id)
+}
+
+func TestUUIDv7Generator_monotonic(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	g := &uuidV7Generator{now: func() time.Time { return now }}
+	ids := make([]string, 0, 5000)
+	for i := 0; i < 5000; i++ { // more than 4096 to overflow the counter
+		ids = append(ids, g.next())
+	}
+	assert.True(t, sort.StringsAreSorted(ids))
+	for _, id := range ids {
+		assert.Regexp(t, reUUIDv7, id)
+	}
+	assert.Equal(t, "018bcfe5-6800", ids[0][:13])
+	assert.Equal(t, "018bcfe5-6801", ids[4999][:13], "timestamp is borrowed from next millisecond")
+}
+
+func TestWithUUIDv7(t *testing.T) {
+	key, err := NewKeyWithOptions("c1", WithUUIDv7())
+	assert.Nil(t, err)
+	assert.Equal(t, reflect.String, key.IDKind)
+	assert.Regexp(t, reUUIDv7, key.ID)
+
+	record := NewRecord(NewKeyWithID("c1", "x"))
+	assert.Nil(t, GenerateUUIDv7(context.Background(), record))
+	assert.Regexp(t, reUUIDv7, record.Key().ID)
+}
@@ -16,6 +16,7 @@ type Key struct {
 }
 
 var idCharsReplacer = strings.NewReplacer(
+	"%", "%25", // escaped, so escaped sequences in IDs survive a round trip
 	".", "%2E",
 	"$", "%24",
 	"#", "%23",
@@ -24,10 +25,26 @@ var idCharsReplacer = strings.NewReplacer(
 	"/", "%2F",
 )
 
+var idCharsUnescaper = strings.NewReplacer(
+	"%25", "%",
+	"%2E", ".",
+	"%24", "$",
+	"%23", "#",
+	"%5B", "[",
+	"%5D", "]",
+	"%2F", "/",
+)
+
+// EscapeID escapes characters that are not allowed in a key path (see Key.String())
 func EscapeID(id string) string {
 	return idCharsReplacer.Replace(id)
 }
 
+// UnescapeID reverts escaping done by EscapeID
+func UnescapeID(id string) string {
+	return idCharsUnescaper.Replace(id)
+}
+
 // String returns string representation of a key instance
 func (k *Key) String() string {
 	key := k // This is intended as we want to traverse the key ancestors
@@ -189,6 +206,10 @@ func NewKeyWithFields(collection string, fields ...FieldVal) *Key {
 	return &Key{collection: collection, ID: fields}
 }
 
+// EqualKeys checks if 2 keys reference the same record.
+// IDs are compared by kind & value as they are encoded (see Key.MarshalBinary), so IDs of a named type
+// are equal to IDs of its underlying type & time.Time IDs are equal if they are the same instant.
+// Composite IDs ([]FieldVal) are compared field by field.
 func EqualKeys(k1 *Key, k2 *Key) bool {
 	if k1 == nil && k2 == nil {
 		return true
@@ -201,7 +222,7 @@ func EqualKeys(k1 *Key, k2 *Key) bool {
 
 	panicIfCircular := func(key *Key, keys []*Key) {
 		for _, k := range keys {
-			if k.ID == key.ID && k.collection == key.collection {
+			if k.collection == key.collection && equalKeyIDs(k.ID, key.ID) {
 				panic(fmt.Sprintf("circular key: %s=%v", k.collection, k.ID))
 			}
 		}
@@ -216,7 +237,7 @@ func EqualKeys(k1 *Key, k2 *Key) bool {
 		if k1.Collection() != k2.Collection() {
 			return false
 		}
-		if k1.ID != k2.ID {
+		if !equalKeyIDs(k1.ID, k2.ID) {
 			return false
 		}
 		k1s = append(k1s, k1)
@@ -231,6 +252,7 @@ func EqualKeys(k1 *Key, k2 *Key) bool {
 	}
 }
 
+// Equal checks if the key is equal to another key (see EqualKeys)
 func (k *Key) Equal(key *Key) bool {
 	return EqualKeys(k, key)
 }
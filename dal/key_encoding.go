@@ -0,0 +1,363 @@
+package dal
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Binary key encoding is inspired by the FoundationDB tuple layer.
+// For each level of a key starting from the root it writes an escaped collection name
+// followed by a type tagged ID value. Encoded keys are ordered the same way as keys are:
+// by collection, then by ID, and a parent key goes before all its children.
+//
+// Values are ordered by type tag first: nil < bytes < string < composite < integer < float < bool < time.
+// Kind of an integer or a float is stored after the value, so it's preserved when decoded.
+const (
+	keyTagNull       byte = 0x00
+	keyTagBytes      byte = 0x01
+	keyTagString     byte = 0x02
+	keyTagComposite  byte = 0x05
+	keyTagIntZero    byte = 0x14 // 0x0C-0x13 are negative integers & 0x15-0x1C are positive integers
+	keyTagFloat      byte = 0x21
+	keyTagFalse      byte = 0x26
+	keyTagTrue       byte = 0x27
+	keyTagTime       byte = 0x33
+	keyCompositeItem byte = 0x01
+	keyTerminator    byte = 0x00
+	keyEscape        byte = 0xFF
+)
+
+// keyEncoding is a base64 encoding with alphabet in ASCII order, so it preserves order of encoded bytes.
+var keyEncoding = base64.NewEncoding("-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz").
+	WithPadding(base64.NoPadding)
+
+var _ encoding.BinaryMarshaler = (*Key)(nil)
+var _ encoding.BinaryUnmarshaler = (*Key)(nil)
+
+// Encode returns a compact, URL-safe string representation of a key that preserves ID types.
+// Encoded keys are ordered the same way as keys are, so they can be used as sort keys in KV stores.
+// Use DecodeKey to get the key back.
+func (k *Key) Encode() (string, error) {
+	b, err := k.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return keyEncoding.EncodeToString(b), nil
+}
+
+// DecodeKey decodes a key encoded by Key.Encode()
+func DecodeKey(s string) (*Key, error) {
+	b, err := keyEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	key := new(Key)
+	if err = key.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// MarshalBinary encodes a key into an ordered binary form (implements encoding.BinaryMarshaler)
+func (k *Key) MarshalBinary() ([]byte, error) {
+	if err := k.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to encode invalid key: %w", err)
+	}
+	keys := make([]*Key, k.Level()+1)
+	for key, i := k, k.Level(); key != nil; key, i = key.parent, i-1 {
+		keys[i] = key
+	}
+	buf := make([]byte, 0, 32*len(keys))
+	var err error
+	for _, key := range keys {
+		buf = appendKeyString(buf, key.collection)
+		if buf, err = appendKeyValue(buf, key.ID); err != nil {
+			return nil, fmt.Errorf("failed to encode ID of %s: %w", key.collection, err)
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a key encoded by MarshalBinary (implements encoding.BinaryUnmarshaler)
+func (k *Key) UnmarshalBinary(data []byte) (err error) {
+	if len(data) == 0 {
+		return errors.New("failed to decode key: no data")
+	}
+	var parent *Key
+	for len(data) > 0 {
+		key := &Key{parent: parent}
+		if key.collection, data, err = readKeyString(data); err != nil {
+			return fmt.Errorf("failed to decode key collection: %w", err)
+		}
+		if key.collection == "" {
+			return errors.New("failed to decode key: empty collection")
+		}
+		if key.ID, data, err = readKeyValue(data); err != nil {
+			return fmt.Errorf("failed to decode ID of %s: %w", key.collection, err)
+		}
+		key.IDKind = idKindOf(key.ID)
+		parent = key
+	}
+	*k = *parent
+	return nil
+}
+
+func idKindOf(id any) reflect.Kind {
+	switch id.(type) {
+	case nil:
+		return reflect.Invalid
+	case []FieldVal:
+		return reflect.Slice
+	default:
+		return reflect.TypeOf(id).Kind()
+	}
+}
+
+func appendKeyString(buf []byte, s string) []byte {
+	return appendEscaped(buf, []byte(s))
+}
+
+// appendEscaped writes bytes escaping 0x00 as 0x00 0xFF and adds 0x00 terminator
+func appendEscaped(buf []byte, b []byte) []byte {
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == keyTerminator {
+			buf = append(buf, keyEscape)
+		}
+	}
+	return append(buf, keyTerminator)
+}
+
+func appendKeyValue(buf []byte, v any) (_ []byte, err error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, keyTagNull), nil
+	case string:
+		return appendKeyString(append(buf, keyTagString), v), nil
+	case []byte:
+		return appendEscaped(append(buf, keyTagBytes), v), nil
+	case bool:
+		if v {
+			return append(buf, keyTagTrue), nil
+		}
+		return append(buf, keyTagFalse), nil
+	case time.Time:
+		buf = append(buf, keyTagTime)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(v.Unix())^(1<<63))
+		return binary.BigEndian.AppendUint32(buf, uint32(v.Nanosecond())), nil
+	case []FieldVal:
+		buf = append(buf, keyTagComposite)
+		for _, field := range v {
+			buf = appendKeyString(append(buf, keyCompositeItem), field.Name)
+			if buf, err = appendKeyValue(buf, field.Value); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		return append(buf, keyTerminator), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		i := rv.Int()
+		if i < 0 {
+			buf = appendKeyInt(buf, true, uint64(-(i+1))+1)
+		} else {
+			buf = appendKeyInt(buf, false, uint64(i))
+		}
+	case rv.CanUint():
+		buf = appendKeyInt(buf, false, rv.Uint())
+	case rv.CanFloat():
+		bits := math.Float64bits(rv.Float())
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		buf = binary.BigEndian.AppendUint64(append(buf, keyTagFloat), bits)
+	case rv.Kind() == reflect.String:
+		return appendKeyString(append(buf, keyTagString), rv.String()), nil
+	default:
+		return nil, fmt.Errorf("%w: encoding of key value of type %T", ErrNotSupported, v)
+	}
+	return append(buf, byte(rv.Kind())), nil
+}
+
+func appendKeyInt(buf []byte, negative bool, magnitude uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], magnitude)
+	n := 8
+	for n > 0 && b[8-n] == 0 {
+		n--
+	}
+	if negative {
+		buf = append(buf, keyTagIntZero-byte(n))
+		for _, c := range b[8-n:] {
+			buf = append(buf, ^c)
+		}
+		return buf
+	}
+	return append(append(buf, keyTagIntZero+byte(n)), b[8-n:]...)
+}
+
+func readEscaped(data []byte) (value []byte, rest []byte, err error) {
+	for i := 0; i < len(data); i++ {
+		if data[i] != keyTerminator {
+			value = append(value, data[i])
+			continue
+		}
+		if i+1 < len(data) && data[i+1] == keyEscape {
+			value = append(value, keyTerminator)
+			i++
+			continue
+		}
+		return value, data[i+1:], nil
+	}
+	return nil, nil, errors.New("unterminated string")
+}
+
+func readKeyString(data []byte) (string, []byte, error) {
+	value, rest, err := readEscaped(data)
+	return string(value), rest, err
+}
+
+func readKeyValue(data []byte) (v any, rest []byte, err error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("missing value")
+	}
+	tag, data := data[0], data[1:]
+	switch {
+	case tag == keyTagNull:
+		return nil, data, nil
+	case tag == keyTagString:
+		return readKeyString(data)
+	case tag == keyTagBytes:
+		var b []byte
+		if b, data, err = readEscaped(data); err == nil && b == nil {
+			b = []byte{}
+		}
+		return b, data, err
+	case tag == keyTagFalse:
+		return false, data, nil
+	case tag == keyTagTrue:
+		return true, data, nil
+	case tag == keyTagTime:
+		if len(data) < 12 {
+			return nil, nil, errors.New("truncated time value")
+		}
+		sec := int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+		nsec := int64(binary.BigEndian.Uint32(data[8:]))
+		return time.Unix(sec, nsec).UTC(), data[12:], nil
+	case tag == keyTagComposite:
+		fields := make([]FieldVal, 0)
+		for {
+			if len(data) == 0 {
+				return nil, nil, errors.New("unterminated composite value")
+			}
+			if data[0] == keyTerminator {
+				return fields, data[1:], nil
+			}
+			if data[0] != keyCompositeItem {
+				return nil, nil, fmt.Errorf("unexpected byte in composite value: 0x%02X", data[0])
+			}
+			var field FieldVal
+			if field.Name, data, err = readKeyString(data[1:]); err != nil {
+				return nil, nil, err
+			}
+			if field.Value, data, err = readKeyValue(data); err != nil {
+				return nil, nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			fields = append(fields, field)
+		}
+	case tag >= keyTagIntZero-8 && tag <= keyTagIntZero+8:
+		return readKeyInt(tag, data)
+	case tag == keyTagFloat:
+		if len(data) < 9 {
+			return nil, nil, errors.New("truncated float value")
+		}
+		bits := binary.BigEndian.Uint64(data)
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		f := math.Float64frombits(bits)
+		switch kind := reflect.Kind(data[8]); kind {
+		case reflect.Float32:
+			return float32(f), data[9:], nil
+		case reflect.Float64:
+			return f, data[9:], nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected float kind: %v", kind)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown value tag: 0x%02X", tag)
+	}
+}
+
+func readKeyInt(tag byte, data []byte) (v any, rest []byte, err error) {
+	negative := tag < keyTagIntZero
+	n := int(tag) - int(keyTagIntZero)
+	if negative {
+		n = -n
+	}
+	if len(data) < n+1 {
+		return nil, nil, errors.New("truncated integer value")
+	}
+	var b [8]byte
+	copy(b[8-n:], data[:n])
+	if negative {
+		for i := 8 - n; i < 8; i++ {
+			b[i] = ^b[i]
+		}
+	}
+	magnitude := binary.BigEndian.Uint64(b[:])
+	kind := reflect.Kind(data[n])
+	rest = data[n+1:]
+
+	var i int64
+	if negative {
+		if magnitude > 1<<63 {
+			return nil, nil, errors.New("integer value overflows int64")
+		}
+		i = -int64(magnitude-1) - 1
+	} else if magnitude <= math.MaxInt64 {
+		i = int64(magnitude)
+	}
+	fitsInt := func(bits int) bool {
+		return (negative || magnitude <= math.MaxInt64) && i >= -1<<(bits-1) && i <= 1<<(bits-1)-1
+	}
+	fitsUint := func(bits int) bool {
+		return !negative && (bits == 64 || magnitude < 1<<bits)
+	}
+	switch {
+	case kind == reflect.Int && fitsInt(strconv.IntSize):
+		return int(i), rest, nil
+	case kind == reflect.Int8 && fitsInt(8):
+		return int8(i), rest, nil
+	case kind == reflect.Int16 && fitsInt(16):
+		return int16(i), rest, nil
+	case kind == reflect.Int32 && fitsInt(32):
+		return int32(i), rest, nil
+	case kind == reflect.Int64 && fitsInt(64):
+		return i, rest, nil
+	case kind == reflect.Uint && fitsUint(strconv.IntSize):
+		return uint(magnitude), rest, nil
+	case kind == reflect.Uint8 && fitsUint(8):
+		return uint8(magnitude), rest, nil
+	case kind == reflect.Uint16 && fitsUint(16):
+		return uint16(magnitude), rest, nil
+	case kind == reflect.Uint32 && fitsUint(32):
+		return uint32(magnitude), rest, nil
+	case kind == reflect.Uint64 && fitsUint(64):
+		return magnitude, rest, nil
+	default:
+		return nil, nil, fmt.Errorf("integer value does not fit into %v", kind)
+	}
+}
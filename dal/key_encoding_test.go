@@ -0,0 +1,157 @@
+package dal
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey_Encode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		key  *Key
+	}{
+		{name: "string_id", key: NewKeyWithID("users", "u1")},
+		{name: "string_with_zero_byte", key: NewKeyWithID("users", "a\x00b")},
+		{name: "int_id", key: NewKeyWithID("users", 123)},
+		{name: "zero_int", key: NewKeyWithID("users", 0)},
+		{name: "negative_int", key: NewKeyWithID("users", -123)},
+		{name: "min_int64", key: NewKeyWithID("users", int64(math.MinInt64))},
+		{name: "max_uint64", key: NewKeyWithID("users", uint64(math.MaxUint64))},
+		{name: "int8", key: NewKeyWithID("users", int8(-5))},
+		{name: "int16", key: NewKeyWithID("users", int16(300))},
+		{name: "int32", key: NewKeyWithID("users", int32(-70000))},
+		{name: "uint", key: NewKeyWithID("users", uint(7))},
+		{name: "uint8", key: NewKeyWithID("users", uint8(255))},
+		{name: "uint16", key: NewKeyWithID("users", uint16(65535))},
+		{name: "uint32", key: NewKeyWithID("users", uint32(1))},
+		{name: "float64", key: NewKeyWithID("users", -1.5)},
+		{name: "float32", key: NewKeyWithID("users", float32(2.5))},
+		{name: "bool", key: NewKeyWithID("users", true)},
+		{name: "child", key: NewKeyWithParentAndID(NewKeyWithID("users", 1), "orders", "o1")},
+		{
+			name: "composite",
+			key: NewKeyWithFields("memberships",
+				FieldVal{Name: "user", Value: "u1"},
+				FieldVal{Name: "group", Value: 2},
+				FieldVal{Name: "active", Value: false},
+				FieldVal{Name: "since", Value: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)},
+				FieldVal{Name: "nil", Value: nil},
+				FieldVal{Name: "bytes", Value: []byte{0, 1}},
+			),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.key.Encode()
+			assert.Nil(t, err)
+			assert.NotContains(t, s, "/")
+			assert.NotContains(t, s, "+")
+			decoded, err := DecodeKey(s)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.key.String(), decoded.String())
+			assert.Equal(t, tt.key.ID, decoded.ID)
+			assert.Equal(t, idKindOf(tt.key.ID), decoded.IDKind)
+			assert.Equal(t, tt.key.Level(), decoded.Level())
+		})
+	}
+	t.Run("string_and_int_differ", func(t *testing.T) {
+		s1, _ := NewKeyWithID("users", "123").Encode()
+		s2, _ := NewKeyWithID("users", 123).Encode()
+		assert.NotEqual(t, s1, s2)
+	})
+	t.Run("invalid_key", func(t *testing.T) {
+		_, err := (&Key{ID: 1}).Encode()
+		assert.ErrorContains(t, err, "invalid key")
+	})
+	t.Run("unsupported_id", func(t *testing.T) {
+		_, err := NewKeyWithID("c", struct{ A int }{A: 1}).Encode()
+		assert.ErrorIs(t, err, ErrNotSupported)
+	})
+	t.Run("named_string_type", func(t *testing.T) {
+		type userID string
+		s, err := NewKeyWithID("users", userID("u1")).Encode()
+		assert.Nil(t, err)
+		key, err := DecodeKey(s)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", key.ID)
+	})
+}
+
+func TestKey_Encode_order(t *testing.T) {
+	users := func(id any) *Key {
+		return &Key{collection: "users", ID: id}
+	}
+	ordered := []*Key{
+		users(nil),
+		users("a"),
+		&Key{parent: users("a"), collection: "orders", ID: 1},
+		users("a\x00"),
+		users("ab"),
+		users("b"),
+		users(int64(math.MinInt64)),
+		users(-300),
+		users(-2),
+		users(-1),
+		users(0),
+		users(1),
+		users(255),
+		users(256),
+		users(uint64(math.MaxUint64)),
+		users(-1.5),
+		users(0.0),
+		users(2.5),
+		users(false),
+		users(true),
+	}
+	encoded := make([]string, len(ordered))
+	for i, key := range ordered {
+		var err error
+		if encoded[i], err = key.Encode(); err != nil {
+			t.Fatalf("failed to encode %v: %v", key, err)
+		}
+		b, _ := key.MarshalBinary()
+		if i > 0 {
+			prev, _ := ordered[i-1].MarshalBinary()
+			assert.Equal(t, -1, bytes.Compare(prev, b), "%v < %v", ordered[i-1], key)
+		}
+	}
+	assert.True(t, sort.StringsAreSorted(encoded), strings.Join(encoded, "\n"))
+}
+
+func TestDecodeKey(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{name: "empty", data: nil, err: "no data"},
+		{name: "unterminated_collection", data: []byte("users"), err: "unterminated string"},
+		{name: "empty_collection", data: []byte{0, keyTagNull}, err: "empty collection"},
+		{name: "missing_id", data: []byte("users\x00"), err: "missing value"},
+		{name: "unknown_tag", data: []byte("users\x00\x40"), err: "unknown value tag"},
+		{name: "truncated_int", data: []byte("users\x00\x16\x01"), err: "truncated integer"},
+		{name: "int_overflow", data: []byte{'u', 0, keyTagIntZero + 2, 1, 0, byte(reflect.Int8)}, err: "does not fit into int8"},
+		{name: "truncated_float", data: []byte{'u', 0, keyTagFloat, 1}, err: "truncated float"},
+		{name: "bad_float_kind", data: []byte{'u', 0, keyTagFloat, 0, 0, 0, 0, 0, 0, 0, 0, byte(reflect.Int)}, err: "unexpected float kind"},
+		{name: "truncated_time", data: []byte{'u', 0, keyTagTime, 1}, err: "truncated time"},
+		{name: "unterminated_composite", data: []byte{'u', 0, keyTagComposite}, err: "unterminated composite"},
+		{name: "bad_composite", data: []byte{'u', 0, keyTagComposite, 7}, err: "unexpected byte"},
+		{name: "bad_composite_field_name", data: []byte{'u', 0, keyTagComposite, keyCompositeItem, 'a'}, err: "unterminated string"},
+		{name: "bad_composite_field_value", data: []byte{'u', 0, keyTagComposite, keyCompositeItem, 'a', 0, 0x40}, err: "field a"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := DecodeKey(keyEncoding.EncodeToString(tt.data))
+			assert.ErrorContains(t, err, tt.err)
+			assert.Nil(t, key)
+		})
+	}
+	t.Run("invalid_base64", func(t *testing.T) {
+		_, err := DecodeKey("!")
+		assert.ErrorContains(t, err, "failed to decode key")
+	})
+}
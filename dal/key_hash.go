@@ -0,0 +1,102 @@
+package dal
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+// equalKeyIDs compares 2 key IDs without panicking on non-comparable values like []FieldVal
+func equalKeyIDs(id1, id2 any) bool {
+	if fields1, ok := id1.([]FieldVal); ok {
+		fields2, ok := id2.([]FieldVal)
+		if !ok || len(fields1) != len(fields2) {
+			return false
+		}
+		for i, f := range fields1 {
+			if f.Name != fields2[i].Name || !equalKeyIDs(f.Value, fields2[i].Value) {
+				return false
+			}
+		}
+		return true
+	}
+	if id1 == nil || id2 == nil {
+		return id1 == nil && id2 == nil
+	}
+	t1, t2 := reflect.TypeOf(id1), reflect.TypeOf(id2)
+	if t1 == t2 && isPlainKeyIDKind(t1.Kind()) {
+		return id1 == id2
+	}
+	// Compare encoded values, so equal keys have the same canonical form (see Key.Canonical)
+	b1, err1 := appendKeyValue(nil, id1)
+	b2, err2 := appendKeyValue(nil, id2)
+	if err1 == nil && err2 == nil {
+		return bytes.Equal(b1, b2)
+	}
+	if t1 != t2 {
+		return false
+	}
+	if t1.Comparable() {
+		return id1 == id2
+	}
+	return reflect.DeepEqual(id1, id2)
+}
+
+// isPlainKeyIDKind returns true for kinds of values that are equal if and only if their encodings are equal
+func isPlainKeyIDKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// canonicalKeyFallbackPrefix marks canonical form of keys that can't be binary encoded (see Key.MarshalBinary)
+const canonicalKeyFallbackPrefix = "\xFF\xFF"
+
+// Canonical returns a canonical form of a key that can be used as a map key.
+// Keys that are equal (see EqualKeys) have the same canonical form.
+// For keys with supported ID types it is the same as Key.MarshalBinary() output, so it preserves keys order.
+func (k *Key) Canonical() string {
+	if k == nil {
+		return ""
+	}
+	if b, err := k.MarshalBinary(); err == nil {
+		return string(b)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(canonicalKeyFallbackPrefix)
+	for key := k; key != nil; key = key.parent {
+		_, _ = fmt.Fprintf(&buf, "%s\x00%T:%#v\x00", key.collection, key.ID, key.ID)
+	}
+	return buf.String()
+}
+
+// Hash returns a 64-bit FNV-1a hash of a key canonical form (see Key.Canonical)
+func (k *Key) Hash() uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(k.Canonical()))
+	return h.Sum64()
+}
+
+// CompareKeys defines total order of keys and returns -1, 0 or 1.
+// Keys are compared level by level starting from the root: first by collection and then by ID,
+// a parent key goes before its children. IDs of different kinds are ordered by kind:
+// nil < bytes < string < composite < integer < float < bool < time.
+// The order is the same as the order of encoded keys (see Key.Encode).
+// A nil key goes before any other key.
+func CompareKeys(k1, k2 *Key) int {
+	if k1 == k2 {
+		return 0
+	}
+	if k1 == nil {
+		return -1
+	}
+	if k2 == nil {
+		return 1
+	}
+	return bytes.Compare([]byte(k1.Canonical()), []byte(k2.Canonical()))
+}
@@ -0,0 +1,128 @@
+package dal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqualKeys_composite(t *testing.T) {
+	newKey := func(fields ...FieldVal) *Key {
+		return NewKeyWithFields("memberships", fields...)
+	}
+	k1 := newKey(FieldVal{Name: "user", Value: "u1"}, FieldVal{Name: "group", Value: 1})
+	for _, tt := range []struct {
+		name     string
+		k2       *Key
+		expected bool
+	}{
+		{name: "equal", k2: newKey(FieldVal{Name: "user", Value: "u1"}, FieldVal{Name: "group", Value: 1}), expected: true},
+		{name: "different_value", k2: newKey(FieldVal{Name: "user", Value: "u1"}, FieldVal{Name: "group", Value: 2})},
+		{name: "different_value_type", k2: newKey(FieldVal{Name: "user", Value: "u1"}, FieldVal{Name: "group", Value: int64(1)})},
+		{name: "different_name", k2: newKey(FieldVal{Name: "user", Value: "u1"}, FieldVal{Name: "team", Value: 1})},
+		{name: "different_length", k2: newKey(FieldVal{Name: "user", Value: "u1"})},
+		{name: "not_composite", k2: NewKeyWithID("memberships", "u1")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, EqualKeys(k1, tt.k2))
+			assert.Equal(t, tt.expected, EqualKeys(tt.k2, k1))
+			assert.Equal(t, tt.expected, k1.Canonical() == tt.k2.Canonical())
+			if tt.expected {
+				assert.Equal(t, k1.Hash(), tt.k2.Hash())
+				assert.Equal(t, 0, CompareKeys(k1, tt.k2))
+			} else {
+				assert.NotEqual(t, 0, CompareKeys(k1, tt.k2))
+			}
+		})
+	}
+}
+
+func TestEqualKeyIDs(t *testing.T) {
+	assert.True(t, equalKeyIDs(nil, nil))
+	assert.False(t, equalKeyIDs(nil, 1))
+	assert.False(t, equalKeyIDs("1", 1))
+	assert.True(t, equalKeyIDs([]int{1}, []int{1}))
+	assert.False(t, equalKeyIDs([]int{1}, []int{2}))
+	assert.True(t, equalKeyIDs(
+		[]FieldVal{{Name: "a", Value: []FieldVal{{Name: "b", Value: 1}}}},
+		[]FieldVal{{Name: "a", Value: []FieldVal{{Name: "b", Value: 1}}}},
+	))
+}
+
+func TestEqualKeys_consistentWithCanonical(t *testing.T) {
+	type userID string
+	moment := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	now := time.Now()
+	for _, tt := range []struct {
+		name     string
+		id1, id2 any
+		expected bool
+	}{
+		{name: "named_string_type", id1: userID("u1"), id2: "u1", expected: true},
+		{name: "named_string_type_different_value", id1: userID("u1"), id2: "u2"},
+		{name: "time_in_different_locations", id1: moment, id2: moment.In(time.FixedZone("X", 3600)), expected: true},
+		{name: "time_with_monotonic_clock", id1: now, id2: now.Round(0), expected: true},
+		{name: "different_times", id1: moment, id2: moment.Add(time.Nanosecond)},
+		{name: "different_int_kinds", id1: 1, id2: int64(1)},
+		{name: "negative_zero_float", id1: 0.0, id2: math.Copysign(0, -1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			k1, k2 := &Key{collection: "c", ID: tt.id1}, &Key{collection: "c", ID: tt.id2}
+			assert.Equal(t, tt.expected, EqualKeys(k1, k2))
+			assert.Equal(t, tt.expected, k1.Canonical() == k2.Canonical())
+		})
+	}
+}
+
+func TestKey_Canonical(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var key *Key
+		assert.Equal(t, "", key.Canonical())
+	})
+	t.Run("preserves_types", func(t *testing.T) {
+		assert.NotEqual(t, NewKeyWithID("c", "1").Canonical(), NewKeyWithID("c", 1).Canonical())
+	})
+	t.Run("as_map_key", func(t *testing.T) {
+		m := map[string]int{}
+		m[NewKeyWithFields("c", FieldVal{Name: "a", Value: 1}).Canonical()]++
+		m[NewKeyWithFields("c", FieldVal{Name: "a", Value: 1}).Canonical()]++
+		m[NewKeyWithParentAndID(NewKeyWithID("p", 1), "c", "x").Canonical()]++
+		m[NewKeyWithParentAndID(NewKeyWithID("p", 1), "c", "x").Canonical()]++
+		assert.Equal(t, 2, len(m))
+	})
+	t.Run("fallback_for_unsupported_id", func(t *testing.T) {
+		type id struct{ A, B int }
+		k1 := NewKeyWithID("c", id{A: 1, B: 2})
+		k2 := NewKeyWithID("c", id{A: 1, B: 2})
+		k3 := NewKeyWithID("c", id{A: 2, B: 1})
+		assert.Equal(t, k1.Canonical(), k2.Canonical())
+		assert.NotEqual(t, k1.Canonical(), k3.Canonical())
+		assert.Equal(t, 0, CompareKeys(k1, k2))
+		assert.NotEqual(t, 0, CompareKeys(k1, k3))
+	})
+}
+
+func TestCompareKeys(t *testing.T) {
+	users := func(id any) *Key {
+		return &Key{collection: "users", ID: id}
+	}
+	ordered := []*Key{
+		nil,
+		{collection: "a", ID: 1},
+		users("a"),
+		{parent: users("a"), collection: "orders", ID: 1},
+		users("b"),
+		users(NewKeyWithFields("x", FieldVal{Name: "a", Value: 1}).ID),
+		users(-1),
+		users(1),
+		users(1.5),
+		users(true),
+	}
+	for i := 1; i < len(ordered); i++ {
+		assert.Equal(t, -1, CompareKeys(ordered[i-1], ordered[i]), "%v < %v", ordered[i-1], ordered[i])
+		assert.Equal(t, 1, CompareKeys(ordered[i], ordered[i-1]))
+		assert.Equal(t, 0, CompareKeys(ordered[i], ordered[i]))
+	}
+}
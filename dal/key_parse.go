@@ -0,0 +1,108 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CollectionIDKind defines a kind of ID for keys of a collection.
+// An empty Collection matches any collection.
+type CollectionIDKind struct {
+	Collection string
+	IDKind     reflect.Kind
+}
+
+// ParseKey parses a key path as generated by Key.String() - e.g. "collection1/id1/collection2/id2".
+// IDs are unescaped (see EscapeID) and by default are strings.
+// Pass idKinds to coerce IDs of specific collections to integer types, for example:
+//
+//	key, err := dal.ParseKey("users/123/orders/456",
+//		dal.CollectionIDKind{Collection: "users", IDKind: reflect.Int},
+//		dal.CollectionIDKind{Collection: "orders", IDKind: reflect.Int64},
+//	)
+func ParseKey(path string, idKinds ...CollectionIDKind) (key *Key, err error) {
+	if path == "" {
+		return nil, errors.New("key path is empty")
+	}
+	segments := strings.Split(path, "/")
+	if len(segments)%2 != 0 {
+		return nil, fmt.Errorf("key path should have even number of segments, got %d: %s", len(segments), path)
+	}
+	for i := 0; i < len(segments); i += 2 {
+		collection, id := segments[i], UnescapeID(segments[i+1])
+		if strings.TrimSpace(collection) == "" {
+			return nil, fmt.Errorf("key path has an empty collection at segment #%d: %s", i, path)
+		}
+		if id == "" {
+			return nil, fmt.Errorf("key path has an empty ID at segment #%d: %s", i+1, path)
+		}
+		child := &Key{parent: key, collection: collection}
+		if child.ID, child.IDKind, err = parseKeyID(id, collectionIDKind(collection, idKinds)); err != nil {
+			return nil, fmt.Errorf("failed to parse ID of collection %s: %w", collection, err)
+		}
+		key = child
+	}
+	if err = key.Validate(); err != nil {
+		return nil, fmt.Errorf("key path is not valid: %w", err)
+	}
+	return key, nil
+}
+
+func collectionIDKind(collection string, idKinds []CollectionIDKind) reflect.Kind {
+	kind := reflect.String
+	for _, k := range idKinds {
+		if k.Collection == collection {
+			return k.IDKind
+		}
+		if k.Collection == "" {
+			kind = k.IDKind
+		}
+	}
+	return kind
+}
+
+func parseKeyID(s string, kind reflect.Kind) (id any, _ reflect.Kind, err error) {
+	switch kind {
+	case reflect.String, reflect.Invalid:
+		return s, reflect.String, nil
+	case reflect.Int:
+		id, err = strconv.Atoi(s)
+	case reflect.Int8:
+		id, err = parseInt[int8](s, 8)
+	case reflect.Int16:
+		id, err = parseInt[int16](s, 16)
+	case reflect.Int32:
+		id, err = parseInt[int32](s, 32)
+	case reflect.Int64:
+		id, err = strconv.ParseInt(s, 10, 64)
+	case reflect.Uint:
+		id, err = parseUint[uint](s, strconv.IntSize)
+	case reflect.Uint8:
+		id, err = parseUint[uint8](s, 8)
+	case reflect.Uint16:
+		id, err = parseUint[uint16](s, 16)
+	case reflect.Uint32:
+		id, err = parseUint[uint32](s, 32)
+	case reflect.Uint64:
+		id, err = strconv.ParseUint(s, 10, 64)
+	default:
+		return nil, kind, fmt.Errorf("%w: ID kind %v", ErrNotSupported, kind)
+	}
+	if err != nil {
+		return nil, kind, err
+	}
+	return id, kind, nil
+}
+
+func parseInt[T int8 | int16 | int32](s string, bitSize int) (T, error) {
+	v, err := strconv.ParseInt(s, 10, bitSize)
+	return T(v), err
+}
+
+func parseUint[T uint | uint8 | uint16 | uint32](s string, bitSize int) (T, error) {
+	v, err := strconv.ParseUint(s, 10, bitSize)
+	return T(v), err
+}
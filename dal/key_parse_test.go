@@ -0,0 +1,114 @@
+package dal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func TestUnescapeID(t *testing.T) {
+	const id = "a.b$c#d[e]f/g"
+	escaped := EscapeID(id)
+	assert.Equal(t, "a%2Eb%24c%23d%5Be%5Df%2Fg", escaped)
+	assert.Equal(t, id, UnescapeID(escaped))
+
+	for _, id := range []string{"a%2Eb", "100%", "%25", "a%2F.b"} {
+		assert.Equal(t, id, UnescapeID(EscapeID(id)))
+		key, err := ParseKey(NewKeyWithID("c1", id).String())
+		assert.Nil(t, err)
+		assert.Equal(t, id, key.ID)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		path     string
+		idKinds  []CollectionIDKind
+		expected *Key
+		err      string
+	}{
+		{name: "empty", path: "", err: "empty"},
+		{name: "odd_segments", path: "users/1/orders", err: "even number of segments"},
+		{name: "empty_collection", path: "/1", err: "empty collection"},
+		{name: "empty_id", path: "users/", err: "empty ID"},
+		{
+			name:     "root_string_id",
+			path:     "users/u1",
+			expected: &Key{collection: "users", ID: "u1", IDKind: reflect.String},
+		},
+		{
+			name:     "escaped_id",
+			path:     "files/a%2Fb%2Etxt",
+			expected: &Key{collection: "files", ID: "a/b.txt", IDKind: reflect.String},
+		},
+		{
+			name: "child_with_int_ids",
+			path: "users/123/orders/456",
+			idKinds: []CollectionIDKind{
+				{Collection: "users", IDKind: reflect.Int},
+				{Collection: "orders", IDKind: reflect.Int64},
+			},
+			expected: &Key{
+				parent:     &Key{collection: "users", ID: 123, IDKind: reflect.Int},
+				collection: "orders", ID: int64(456), IDKind: reflect.Int64,
+			},
+		},
+		{
+			name:     "default_kind",
+			path:     "a/1/b/2",
+			idKinds:  []CollectionIDKind{{IDKind: reflect.Uint16}, {Collection: "b", IDKind: reflect.String}},
+			expected: &Key{parent: &Key{collection: "a", ID: uint16(1), IDKind: reflect.Uint16}, collection: "b", ID: "2", IDKind: reflect.String},
+		},
+		{
+			name:    "not_int",
+			path:    "users/abc",
+			idKinds: []CollectionIDKind{{Collection: "users", IDKind: reflect.Int}},
+			err:     "failed to parse ID of collection users",
+		},
+		{
+			name:    "overflow",
+			path:    "users/300",
+			idKinds: []CollectionIDKind{{Collection: "users", IDKind: reflect.Uint8}},
+			err:     "out of range",
+		},
+		{
+			name:    "unsupported_kind",
+			path:    "users/1.5",
+			idKinds: []CollectionIDKind{{Collection: "users", IDKind: reflect.Float64}},
+			err:     ErrNotSupported.Error(),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKey(tt.path, tt.idKinds...)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.Nil(t, key)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, key)
+		})
+	}
+	t.Run("all_int_kinds", func(t *testing.T) {
+		for kind, expected := range map[reflect.Kind]any{
+			reflect.Int8:   int8(7),
+			reflect.Int16:  int16(7),
+			reflect.Int32:  int32(7),
+			reflect.Uint:   uint(7),
+			reflect.Uint32: uint32(7),
+			reflect.Uint64: uint64(7),
+		} {
+			key, err := ParseKey("c/7", CollectionIDKind{IDKind: kind})
+			assert.Nil(t, err)
+			assert.Equal(t, expected, key.ID)
+			assert.Equal(t, kind, key.IDKind)
+		}
+	})
+	t.Run("round_trip", func(t *testing.T) {
+		key := NewKeyWithParentAndID(NewKeyWithID("users", 123), "docs", "a.b/c")
+		parsed, err := ParseKey(key.String(), CollectionIDKind{Collection: "users", IDKind: reflect.Int})
+		assert.Nil(t, err)
+		assert.True(t, EqualKeys(key, parsed))
+	})
+}
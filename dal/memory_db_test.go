@@ -0,0 +1,317 @@
+package dal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryDB is an in-memory implementation of DB used by tests of DB & transaction wrappers.
+// Record data is stored as JSON so any serializable struct or map can be used as record data.
+type memoryDB struct {
+	mutex   sync.Mutex
+	adapter Adapter
+	records map[string]memoryRecord
+	calls   []string // names of called methods with number of keys, e.g. "SetMulti:2"
+	err     error    // if set returned by write operations
+}
+
+type memoryRecord struct {
+	key  *Key
+	data []byte
+}
+
+var _ DB = (*memoryDB)(nil)
+
+func newMemoryDB(options ...AdapterOption) *memoryDB {
+	return &memoryDB{
+		adapter: NewAdapter("memory", "v0", options...),
+		records: make(map[string]memoryRecord),
+	}
+}
+
+func (db *memoryDB) called(method string, count int) {
+	db.calls = append(db.calls, fmt.Sprintf("%s:%d", method, count))
+}
+
+// putData stores record data bypassing any wrappers, to be used in tests setup
+func (db *memoryDB) putData(key *Key, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	db.records[key.String()] = memoryRecord{key: key, data: b}
+}
+
+// getData returns stored record data as a map or nil if record does not exist
+func (db *memoryDB) getData(key *Key) map[string]any {
+	r, ok := db.records[key.String()]
+	if !ok {
+		return nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal(r.data, &data); err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (db *memoryDB) ID() string {
+	return "memory"
+}
+
+func (db *memoryDB) Adapter() Adapter {
+	return db.adapter
+}
+
+func (db *memoryDB) RunReadonlyTransaction(ctx context.Context, f ROTxWorker, options ...TransactionOption) error {
+	tx := &memoryTx{db: db, options: NewTransactionOptions(append(options, TxWithReadonly())...)}
+	return f(NewContextWithTransaction(ctx, tx), tx)
+}
+
+func (db *memoryDB) RunReadwriteTransaction(ctx context.Context, f RWTxWorker, options ...TransactionOption) error {
+	db.mutex.Lock()
+	snapshot := make(map[string]memoryRecord, len(db.records))
+	for k, r := range db.records {
+		snapshot[k] = r
+	}
+	db.mutex.Unlock()
+	tx := &memoryTx{db: db, options: NewTransactionOptions(options...)}
+	if err := f(NewContextWithTransaction(ctx, tx), tx); err != nil {
+		db.mutex.Lock()
+		db.records = snapshot
+		db.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (db *memoryDB) Get(_ context.Context, record Record) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.called("Get", 1)
+	return db.get(record)
+}
+
+func (db *memoryDB) get(record Record) error {
+	r, ok := db.records[record.Key().String()]
+	if !ok {
+		err := NewErrNotFoundByKey(record.Key(), nil)
+		record.SetError(err)
+		return err
+	}
+	record.SetError(nil)
+	if err := json.Unmarshal(r.data, record.Data()); err != nil {
+		record.SetError(err)
+		return err
+	}
+	return nil
+}
+
+func (db *memoryDB) GetMulti(_ context.Context, records []Record) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.called("GetMulti", len(records))
+	for _, record := range records {
+		if err := db.get(record); err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *memoryDB) QueryReader(_ context.Context, query Query) (Reader, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.called("QueryReader", 0)
+	from := query.From()
+	var records []Record
+	for _, r := range db.records {
+		if from != nil && !from.Contains(r.key) {
+			continue
+		}
+		data := new(map[string]any)
+		if err := json.Unmarshal(r.data, data); err != nil {
+			return nil, err
+		}
+		records = append(records, NewRecordWithData(r.key, data).SetError(nil))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return CompareKeys(records[i].Key(), records[j].Key()) < 0
+	})
+	return &recordsReader{records: records, current: -1}, nil
+}
+
+func (db *memoryDB) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	reader, err := db.QueryReader(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return SelectAllRecords(reader)
+}
+
+func (db *memoryDB) set(record Record) error {
+	if db.err != nil {
+		return db.err
+	}
+	record.SetError(nil)
+	b, err := json.Marshal(record.Data())
+	if err != nil {
+		return err
+	}
+	db.records[record.Key().String()] = memoryRecord{key: record.Key(), data: b}
+	return nil
+}
+
+func (db *memoryDB) update(key *Key, updates []Update) error {
+	if db.err != nil {
+		return db.err
+	}
+	data := db.getData(key)
+	if data == nil {
+		return NewErrNotFoundByKey(key, nil)
+	}
+	for _, u := range updates {
+		path := u.FieldPath
+		if u.Field != "" {
+			path = strings.Split(u.Field, ".")
+		}
+		m := data
+		for _, name := range path[:len(path)-1] {
+			child, ok := m[name].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				m[name] = child
+			}
+			m = child
+		}
+		if u.Value == DeleteField {
+			delete(m, path[len(path)-1])
+		} else {
+			m[path[len(path)-1]] = u.Value
+		}
+	}
+	db.putData(key, data)
+	return nil
+}
+
+var _ ReadwriteTransaction = (*memoryTx)(nil)
+
+type memoryTx struct {
+	db      *memoryDB
+	options TransactionOptions
+}
+
+func (tx *memoryTx) ID() string {
+	return "memory-tx"
+}
+
+func (tx *memoryTx) Options() TransactionOptions {
+	return tx.options
+}
+
+func (tx *memoryTx) Get(ctx context.Context, record Record) error {
+	return tx.db.Get(ctx, record)
+}
+
+func (tx *memoryTx) GetMulti(ctx context.Context, records []Record) error {
+	return tx.db.GetMulti(ctx, records)
+}
+
+func (tx *memoryTx) QueryReader(ctx context.Context, query Query) (Reader, error) {
+	return tx.db.QueryReader(ctx, query)
+}
+
+func (tx *memoryTx) QueryAllRecords(ctx context.Context, query Query) ([]Record, error) {
+	return tx.db.QueryAllRecords(ctx, query)
+}
+
+func (tx *memoryTx) Set(_ context.Context, record Record) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("Set", 1)
+	return tx.db.set(record)
+}
+
+func (tx *memoryTx) SetMulti(_ context.Context, records []Record) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("SetMulti", len(records))
+	for _, record := range records {
+		if err := tx.db.set(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *memoryTx) Delete(_ context.Context, key *Key) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("Delete", 1)
+	if tx.db.err != nil {
+		return tx.db.err
+	}
+	delete(tx.db.records, key.String())
+	return nil
+}
+
+func (tx *memoryTx) DeleteMulti(_ context.Context, keys []*Key) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("DeleteMulti", len(keys))
+	if tx.db.err != nil {
+		return tx.db.err
+	}
+	for _, key := range keys {
+		delete(tx.db.records, key.String())
+	}
+	return nil
+}
+
+func (tx *memoryTx) Update(_ context.Context, key *Key, updates []Update, _ ...Precondition) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("Update", 1)
+	return tx.db.update(key, updates)
+}
+
+func (tx *memoryTx) UpdateMulti(_ context.Context, keys []*Key, updates []Update, _ ...Precondition) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("UpdateMulti", len(keys))
+	for _, key := range keys {
+		if err := tx.db.update(key, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *memoryTx) Insert(_ context.Context, record Record, _ ...InsertOption) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("Insert", 1)
+	if _, exists := tx.db.records[record.Key().String()]; exists {
+		return NewErrAlreadyExists(record.Key(), nil)
+	}
+	return tx.db.set(record)
+}
+
+func (tx *memoryTx) InsertMulti(_ context.Context, records []Record, _ ...InsertOption) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.called("InsertMulti", len(records))
+	for _, record := range records {
+		if _, exists := tx.db.records[record.Key().String()]; exists {
+			return NewErrAlreadyExists(record.Key(), nil)
+		}
+		if err := tx.db.set(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
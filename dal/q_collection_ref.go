@@ -7,6 +7,9 @@ type CollectionRef struct {
 	Name   string
 	Alias  string
 	Parent *Key
+
+	// IsGroup indicates a collection group - all collections with the given name regardless of their parents
+	IsGroup bool
 }
 
 //func (v CollectionRef) Name() string {
@@ -23,7 +26,7 @@ type CollectionRef struct {
 
 func (v CollectionRef) String() string {
 	if v.Name != "" {
-		if v.Parent == nil {
+		if v.Parent == nil && !v.IsGroup {
 			if v.Alias == "" {
 				return v.Name
 			} else {
@@ -38,7 +41,12 @@ func (v CollectionRef) String() string {
 	return fmt.Sprintf("%s AS %s", path, v.Alias)
 }
 
+// Path returns a path to a collection.
+// For a collection group it is prefixed with "**/" as it matches collections at any level.
 func (v CollectionRef) Path() string {
+	if v.IsGroup {
+		return "**/" + v.Name
+	}
 	if v.Parent == nil {
 		return v.Name
 	}
@@ -58,3 +66,22 @@ func NewCollectionRef(name string, alias string, parent *Key) CollectionRef {
 		Parent: parent,
 	}
 }
+
+// NewCollectionGroupRef creates a reference to all collections with the given name regardless of their parents
+func NewCollectionGroupRef(name string, alias string) CollectionRef {
+	ref := NewCollectionRef(name, alias, nil)
+	ref.IsGroup = true
+	return ref
+}
+
+// Contains checks if a key belongs to the referenced collection.
+// For a collection group only the collection name is checked.
+func (v CollectionRef) Contains(key *Key) bool {
+	if key == nil || key.collection != v.Name {
+		return false
+	}
+	if v.IsGroup {
+		return true
+	}
+	return EqualKeys(key.parent, v.Parent)
+}
@@ -100,6 +100,29 @@ func TestCollectionRef(t *testing.T) {
 				path:   "collection2/id2/collection1",
 			},
 		},
+		{
+			name: "collection_group",
+			collectionRef: CollectionRef{
+				Name:    "collection1",
+				IsGroup: true,
+			},
+			expected: expected{
+				string: "**/collection1",
+				path:   "**/collection1",
+			},
+		},
+		{
+			name: "collection_group_with_alias",
+			collectionRef: CollectionRef{
+				Name:    "collection1",
+				Alias:   "c1",
+				IsGroup: true,
+			},
+			expected: expected{
+				string: "**/collection1 AS c1",
+				path:   "**/collection1",
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Run("string", func(t *testing.T) {
@@ -111,3 +134,35 @@ func TestCollectionRef(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCollectionGroupRef(t *testing.T) {
+	assert.Equal(t, CollectionRef{Name: "orders", IsGroup: true}, NewCollectionGroupRef("orders", "orders"))
+	assert.Panics(t, func() {
+		NewCollectionGroupRef("", "")
+	})
+}
+
+func TestCollectionRef_Contains(t *testing.T) {
+	user1 := NewKeyWithID("users", "u1")
+	user2 := NewKeyWithID("users", "u2")
+	for _, tt := range []struct {
+		name     string
+		ref      CollectionRef
+		key      *Key
+		expected bool
+	}{
+		{name: "nil_key", ref: CollectionRef{Name: "orders"}, key: nil},
+		{name: "root", ref: CollectionRef{Name: "orders"}, key: NewKeyWithID("orders", 1), expected: true},
+		{name: "root_vs_child", ref: CollectionRef{Name: "orders"}, key: NewKeyWithParentAndID(user1, "orders", 1)},
+		{name: "other_collection", ref: CollectionRef{Name: "orders"}, key: NewKeyWithID("items", 1)},
+		{name: "child", ref: NewCollectionRef("orders", "", user1), key: NewKeyWithParentAndID(user1, "orders", 1), expected: true},
+		{name: "child_of_other_parent", ref: NewCollectionRef("orders", "", user1), key: NewKeyWithParentAndID(user2, "orders", 1)},
+		{name: "group_root", ref: NewCollectionGroupRef("orders", ""), key: NewKeyWithID("orders", 1), expected: true},
+		{name: "group_child", ref: NewCollectionGroupRef("orders", ""), key: NewKeyWithParentAndID(user2, "orders", 1), expected: true},
+		{name: "group_other_collection", ref: NewCollectionGroupRef("orders", ""), key: NewKeyWithParentAndID(user2, "items", 1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.ref.Contains(tt.key))
+		})
+	}
+}
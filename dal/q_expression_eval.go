@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"fmt"
+)
+
+// evaluateExpression evaluates a non-aggregate expression against a record
+func evaluateExpression(record Record, expression Expression) (any, error) {
+	switch expr := expression.(type) {
+	case nil:
+		return nil, nil
+	case Constant:
+		return expr.Value, nil
+	case FieldRef:
+		if expr.IsID {
+			return record.Key().ID, nil
+		}
+		value, _ := GetFieldValue(record.Data(), expr.Name)
+		return value, nil
+	default:
+		return nil, fmt.Errorf("%w: evaluation of expression of type %T: %v", ErrNotSupported, expression, expression)
+	}
+}
@@ -10,6 +10,14 @@ type GroupCondition struct {
 	conditions []Condition
 }
 
+// NewGroupCondition creates a condition that joins conditions by an And or an Or operator
+func NewGroupCondition(operator Operator, conditions ...Condition) GroupCondition {
+	if operator != And && operator != Or {
+		panic(fmt.Sprintf("operator should be %v or %v, got %v", And, Or, operator))
+	}
+	return GroupCondition{operator: operator, conditions: conditions}
+}
+
 func (v GroupCondition) Operator() Operator {
 	return v.operator
 }
@@ -33,3 +33,13 @@ func TestGroupCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestNewGroupCondition(t *testing.T) {
+	a, b := WhereField("a", Equal, 1), WhereField("b", Equal, 2)
+	gc := NewGroupCondition(Or, a, b)
+	assert.Equal(t, Operator(Or), gc.Operator())
+	assert.Equal(t, []Condition{a, b}, gc.Conditions())
+	assert.Panics(t, func() {
+		NewGroupCondition(Equal, a, b)
+	})
+}
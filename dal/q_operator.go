@@ -7,9 +7,15 @@ const (
 	// Equal is a Comparison operator
 	Equal Operator = "=="
 
+	// NotEqual is a Comparison operator
+	NotEqual Operator = "!="
+
 	// In is a Comparison operator
 	In Operator = "In"
 
+	// ArrayContains is a Comparison operator that checks if an array field contains a value
+	ArrayContains Operator = "array-contains"
+
 	// GreaterThen is a Comparison operator
 	GreaterThen Operator = ">"
 
@@ -14,6 +14,8 @@ type QueryBuilder interface {
 	Where(conditions ...Condition) QueryBuilder
 	WhereField(name string, operator Operator, v any) QueryBuilder
 	OrderBy(expressions ...OrderExpression) QueryBuilder
+	GroupBy(expressions ...Expression) QueryBuilder
+	Columns(columns ...Column) QueryBuilder
 	SelectInto(func() Record) Query
 	SelectKeysOnly(idKind reflect.Kind) Query
 	StartFrom(cursor Cursor) QueryBuilder
@@ -21,16 +23,35 @@ type QueryBuilder interface {
 
 var _ QueryBuilder = (*queryBuilder)(nil)
 
+// From creates a query builder for a root collection
 func From(collection string, conditions ...Condition) QueryBuilder {
-	return &queryBuilder{collection: collection, conditions: conditions}
+	return &queryBuilder{from: CollectionRef{Name: collection}, conditions: conditions}
+}
+
+// FromChildren creates a query builder for a child collection of a specific parent record.
+// E.g. FromChildren(dal.NewKeyWithID("users", "u1"), "orders") queries orders of a single user.
+func FromChildren(parent *Key, collection string, conditions ...Condition) QueryBuilder {
+	if parent == nil {
+		panic("parent is a required parameter, got nil")
+	}
+	return &queryBuilder{from: NewCollectionRef(collection, "", parent), conditions: conditions}
+}
+
+// FromCollectionGroup creates a query builder for all collections with the given name regardless of parent.
+// E.g. FromCollectionGroup("orders") queries orders of all users.
+// Keys of returned records are expected to have parent keys populated.
+func FromCollectionGroup(collection string, conditions ...Condition) QueryBuilder {
+	return &queryBuilder{from: NewCollectionGroupRef(collection, ""), conditions: conditions}
 }
 
 type queryBuilder struct {
-	collection  string
+	from        CollectionRef
 	offset      int
 	limit       int
 	conditions  []Condition
 	orderBy     []OrderExpression
+	groupBy     []Expression
+	columns     []Column
 	startCursor Cursor
 }
 
@@ -54,6 +75,16 @@ func (s queryBuilder) OrderBy(expressions ...OrderExpression) QueryBuilder {
 	return s
 }
 
+func (s queryBuilder) GroupBy(expressions ...Expression) QueryBuilder {
+	s.groupBy = append(s.groupBy, expressions...)
+	return s
+}
+
+func (s queryBuilder) Columns(columns ...Column) QueryBuilder {
+	s.columns = append(s.columns, columns...)
+	return s
+}
+
 func (s queryBuilder) Where(conditions ...Condition) QueryBuilder {
 	s.conditions = append(s.conditions, conditions...)
 	return s
@@ -77,10 +108,13 @@ func (s queryBuilder) SelectKeysOnly(idKind reflect.Kind) Query {
 }
 
 func (s queryBuilder) newQuery() theQuery {
+	from := s.from
 	q := theQuery{
-		from:        &CollectionRef{Name: s.collection},
+		from:        &from,
 		limit:       s.limit,
 		orderBy:     s.orderBy,
+		groupBy:     s.groupBy,
+		columns:     s.columns,
 		offset:      s.offset,
 		startCursor: s.startCursor,
 	}
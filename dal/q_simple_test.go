@@ -46,4 +46,33 @@ func TestSimpleQuery(t *testing.T) {
 		q := qb2.SelectInto(newRecord)
 		assertQuery(t, q)
 	})
+
+	t.Run("with_group_by_and_columns", func(t *testing.T) {
+		q := From("test").
+			GroupBy(Field("country")).
+			Columns(Column{Expression: Field("country")}, CountAs(Field("*"), "cnt")).
+			SelectInto(newRecord)
+		assert.Equal(t, []Expression{Field("country")}, q.GroupBy())
+		assert.Equal(t, 2, len(q.Columns()))
+		assert.Equal(t, "cnt", q.Columns()[1].Alias)
+	})
+
+	t.Run("from_children", func(t *testing.T) {
+		parent := NewKeyWithID("users", "u1")
+		q := FromChildren(parent, "orders").WhereField("status", Equal, "new").SelectKeysOnly(reflect.Int)
+		assert.Equal(t, "orders", q.From().Name)
+		assert.Same(t, parent, q.From().Parent)
+		assert.False(t, q.From().IsGroup)
+		assert.Equal(t, "SELECT * FROM [users/u1/orders] WHERE status = 'new'", q.String())
+		assert.Panics(t, func() {
+			FromChildren(nil, "orders")
+		})
+	})
+	t.Run("from_collection_group", func(t *testing.T) {
+		q := FromCollectionGroup("orders").WhereField("status", Equal, "new").SelectKeysOnly(reflect.Int)
+		assert.Equal(t, "orders", q.From().Name)
+		assert.Nil(t, q.From().Parent)
+		assert.True(t, q.From().IsGroup)
+		assert.Equal(t, "SELECT * FROM [**/orders] WHERE status = 'new'", q.String())
+	})
 }
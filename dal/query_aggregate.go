@@ -0,0 +1,359 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// QueryAggregates executes an aggregate query client side.
+// It streams base records (by query From, Where & Into) from the query executor
+// and computes grouped aggregates (see SumAs, CountAs, MinAs, MaxAs, AverageAs) in memory.
+// This allows to run aggregate queries against adapters that do not support them natively.
+//
+// Each returned row is keyed by column alias (or by expression text if a column has no alias).
+// To count records use CountAs(Field("*"), alias).
+func QueryAggregates(ctx context.Context, qe QueryExecutor, query Query) (rows []map[string]any, err error) {
+	if qe == nil {
+		panic("qe is a required parameter, got nil")
+	}
+	var reader Reader
+	if reader, err = qe.QueryReader(ctx, newAggregateBaseQuery(query)); err != nil {
+		return nil, fmt.Errorf("failed to get reader for aggregate query: %w", err)
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return AggregateRecords(reader, query)
+}
+
+// newAggregateBaseQuery creates a query that selects records to be aggregated
+func newAggregateBaseQuery(query Query) Query {
+	q := theQuery{
+		from:   query.From(),
+		where:  query.Where(),
+		into:   query.Into(),
+		idKind: query.IDKind(),
+	}
+	if q.into == nil && q.from != nil {
+		collection, parent, idKind := q.from.Name, q.from.Parent, q.idKind
+		if idKind == reflect.Invalid {
+			idKind = reflect.Interface
+		}
+		q.into = func() Record {
+			return NewRecordWithData(NewIncompleteKey(collection, idKind, parent), new(map[string]any))
+		}
+	}
+	return q
+}
+
+// AggregateRecords reads all records from a reader and computes grouped aggregates
+// for columns of the given query. Query GroupBy, OrderBy, Offset & Limit are applied to the result.
+// OrderBy expressions should reference column aliases.
+// Numeric GROUP BY values are grouped by value regardless of their types, e.g. int64(1), 1 & 1.0.
+// SUM of integer values is an int64 & an error is returned if it overflows.
+func AggregateRecords(reader Reader, query Query) (rows []map[string]any, err error) {
+	if reader == nil {
+		panic("reader is a required parameter, got nil")
+	}
+	columns := query.Columns()
+	if len(columns) == 0 {
+		return nil, errors.New("aggregate query should have at least 1 column")
+	}
+	groupBy := query.GroupBy()
+
+	var groups []*aggregateGroup
+	groupsByKey := make(map[string]*aggregateGroup)
+
+	for {
+		var record Record
+		if record, err = reader.Next(); err != nil {
+			if errors.Is(err, ErrNoMoreRecords) {
+				err = nil
+				break
+			}
+			return nil, err
+		}
+		groupValues := make([]any, len(groupBy))
+		for i, expr := range groupBy {
+			if groupValues[i], err = evaluateExpression(record, expr); err != nil {
+				return nil, fmt.Errorf("failed to evaluate GROUP BY expression #%d: %w", i, err)
+			}
+		}
+		key := aggregateGroupKey(groupValues)
+		group := groupsByKey[key]
+		if group == nil {
+			if group, err = newAggregateGroup(columns, record); err != nil {
+				return nil, err
+			}
+			groupsByKey[key] = group
+			groups = append(groups, group)
+		}
+		if err = group.add(record); err != nil {
+			return nil, err
+		}
+	}
+	if len(groups) == 0 && len(groupBy) == 0 {
+		// Same as in SQL an aggregate query without GROUP BY returns a single row for an empty input
+		var group *aggregateGroup
+		if group, err = newAggregateGroup(columns, nil); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	i := 0
+	next := func() (map[string]any, error) {
+		if i >= len(groups) {
+			return nil, ErrNoMoreRecords
+		}
+		i++
+		return groups[i-1].row()
+	}
+	return sortItems(next, aggregateRowOrderKeys, query.OrderBy(), query.Offset(), query.Limit())
+}
+
+// aggregateRowOrderKeys resolves order expressions by column aliases
+func aggregateRowOrderKeys(row map[string]any, orderBy []OrderExpression) ([]any, error) {
+	keys := make([]any, len(orderBy))
+	for i, o := range orderBy {
+		expr := o.Expression()
+		name := expr.String()
+		if field, ok := expr.(FieldRef); ok {
+			name = field.Name
+		}
+		value, ok := row[name]
+		if !ok {
+			return nil, fmt.Errorf("ORDER BY expression #%d %v does not match any column alias", i, expr)
+		}
+		keys[i] = value
+	}
+	return keys, nil
+}
+
+func applyOffsetAndLimit[T any](items []T, offset, limit int) []T {
+	if offset > 0 {
+		if offset >= len(items) {
+			return items[:0]
+		}
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func aggregateGroupKey(values []any) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = aggregateGroupKeyValue(v)
+	}
+	return strings.Join(s, "\x00")
+}
+
+// aggregateGroupKeyValue normalizes numbers, so equal values of different types
+// (e.g. int64(1), int(1) & 1.0) fall into the same group as in SQL
+func aggregateGroupKeyValue(value any) string {
+	v := indirectValue(value)
+	switch {
+	case !v.IsValid():
+		return "<nil>"
+	case v.CanInt():
+		return "number:" + strconv.FormatInt(v.Int(), 10)
+	case v.CanUint():
+		return "number:" + strconv.FormatUint(v.Uint(), 10)
+	case v.CanFloat():
+		f := v.Float()
+		if f == 0 {
+			f = 0 // -0 is equal to 0
+		}
+		return "number:" + strconv.FormatFloat(f, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%T:%v", v.Interface(), v.Interface())
+	}
+}
+
+// ColumnAlias returns alias of a column or text of its expression if alias is empty
+func ColumnAlias(column Column) string {
+	if column.Alias != "" {
+		return column.Alias
+	}
+	if column.Expression == nil {
+		return ""
+	}
+	return column.Expression.String()
+}
+
+type aggregateGroup struct {
+	columns     []Column
+	aggregators []*aggregator // nil for non-aggregate columns
+	values      []any         // values of non-aggregate columns taken from the first record of a group
+}
+
+func newAggregateGroup(columns []Column, first Record) (group *aggregateGroup, err error) {
+	group = &aggregateGroup{
+		columns:     columns,
+		aggregators: make([]*aggregator, len(columns)),
+		values:      make([]any, len(columns)),
+	}
+	for i, col := range columns {
+		if f, ok := col.Expression.(function); ok {
+			if group.aggregators[i], err = newAggregator(f); err != nil {
+				return nil, fmt.Errorf("invalid column #%d %v: %w", i, col, err)
+			}
+			continue
+		}
+		if first != nil {
+			if group.values[i], err = evaluateExpression(first, col.Expression); err != nil {
+				return nil, fmt.Errorf("failed to evaluate column #%d %v: %w", i, col, err)
+			}
+		}
+	}
+	return group, nil
+}
+
+func (g *aggregateGroup) add(record Record) error {
+	for i, a := range g.aggregators {
+		if a == nil {
+			continue
+		}
+		if err := a.add(record); err != nil {
+			return fmt.Errorf("failed to aggregate column #%d %v: %w", i, g.columns[i], err)
+		}
+	}
+	return nil
+}
+
+func (g *aggregateGroup) row() (map[string]any, error) {
+	row := make(map[string]any, len(g.columns))
+	for i, col := range g.columns {
+		if a := g.aggregators[i]; a != nil {
+			result, err := a.result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to aggregate column #%d %v: %w", i, col, err)
+			}
+			row[ColumnAlias(col)] = result
+		} else {
+			row[ColumnAlias(col)] = g.values[i]
+		}
+	}
+	return row, nil
+}
+
+type aggregator struct {
+	function   string
+	arg        Expression
+	countRows  bool
+	count      int
+	isFloat    bool
+	intSum     int64
+	overflow   bool // of intSum, reported if the sum has no float values
+	floatSum   float64
+	extreme    any
+	hasExtreme bool
+}
+
+func newAggregator(f function) (*aggregator, error) {
+	switch f.Name {
+	case SUM, COUNT, MIN, MAX, AVERAGE:
+	default:
+		return nil, fmt.Errorf("%w: function %v() is not an aggregate function", ErrNotSupported, f.Name)
+	}
+	if len(f.Args) != 1 {
+		return nil, fmt.Errorf("aggregate function %v() expects 1 argument, got %d", f.Name, len(f.Args))
+	}
+	a := &aggregator{function: f.Name, arg: f.Args[0]}
+	if field, ok := a.arg.(FieldRef); ok && field.Name == "*" {
+		if f.Name != COUNT {
+			return nil, fmt.Errorf("%v(*) is not supported, only COUNT(*) is", f.Name)
+		}
+		a.countRows = true
+	}
+	return a, nil
+}
+
+func (a *aggregator) add(record Record) error {
+	if a.countRows {
+		a.count++
+		return nil
+	}
+	value, err := evaluateExpression(record, a.arg)
+	if err != nil {
+		return err
+	}
+	v := indirectValue(value)
+	if !v.IsValid() { // NULL values are ignored by aggregate functions
+		return nil
+	}
+	a.count++
+	switch a.function {
+	case SUM, AVERAGE:
+		switch {
+		case v.CanInt():
+			a.addInt(v.Int())
+			a.floatSum += float64(v.Int())
+		case v.CanUint():
+			if u := v.Uint(); u > math.MaxInt64 {
+				a.overflow = true
+			} else {
+				a.addInt(int64(u))
+			}
+			a.floatSum += float64(v.Uint())
+		case v.CanFloat():
+			a.isFloat = true
+			a.floatSum += v.Float()
+		default:
+			return fmt.Errorf("%v() expects numeric values, got %T", a.function, v.Interface())
+		}
+	case MIN, MAX:
+		if !a.hasExtreme {
+			a.extreme, a.hasExtreme = v.Interface(), true
+			return nil
+		}
+		c := compareValues(v.Interface(), a.extreme)
+		if a.function == MIN && c < 0 || a.function == MAX && c > 0 {
+			a.extreme = v.Interface()
+		}
+	}
+	return nil
+}
+
+func (a *aggregator) addInt(n int64) {
+	sum := a.intSum + n
+	if n > 0 && sum < a.intSum || n < 0 && sum > a.intSum {
+		a.overflow = true
+	}
+	a.intSum = sum
+}
+
+func (a *aggregator) result() (any, error) {
+	switch a.function {
+	case COUNT:
+		return a.count, nil
+	case SUM:
+		if a.count == 0 {
+			return nil, nil
+		}
+		if a.isFloat {
+			return a.floatSum, nil
+		}
+		if a.overflow {
+			return nil, fmt.Errorf("%v() of integer values overflows int64", a.function)
+		}
+		return a.intSum, nil
+	case AVERAGE:
+		if a.count == 0 {
+			return nil, nil
+		}
+		return a.floatSum / float64(a.count), nil
+	default: // MIN, MAX
+		return a.extreme, nil
+	}
+}
@@ -0,0 +1,260 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type testOrder struct {
+	Country string
+	Amount  int
+	Rating  float64 `dalgo:"rating"`
+}
+
+func newTestOrdersReader() Reader {
+	orders := []testOrder{
+		{Country: "IE", Amount: 10, Rating: 4},
+		{Country: "UK", Amount: 20, Rating: 3},
+		{Country: "IE", Amount: 30, Rating: 5},
+		{Country: "US", Amount: 5, Rating: 1},
+		{Country: "UK", Amount: 40, Rating: 2},
+	}
+	records := make([]Record, len(orders))
+	for i := range orders {
+		records[i] = NewRecordWithData(NewKeyWithID("orders", i+1), &orders[i]).SetError(nil)
+	}
+	return NewRecordsReader(records)
+}
+
+func newTestMapsReader(rows ...map[string]any) Reader {
+	records := make([]Record, len(rows))
+	for i := range rows {
+		records[i] = NewRecordWithData(NewKeyWithID("rows", i+1), &rows[i]).SetError(nil)
+	}
+	return NewRecordsReader(records)
+}
+
+func TestAggregateRecords(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		query    theQuery
+		reader   Reader
+		expected []map[string]any
+		err      string
+	}{
+		{
+			name:   "no_columns",
+			query:  theQuery{},
+			reader: newTestOrdersReader(),
+			err:    "at least 1 column",
+		},
+		{
+			name: "no_group_by",
+			query: theQuery{columns: []Column{
+				CountAs(Field("*"), "cnt"),
+				SumAs(Field("Amount"), "total"),
+				MinAs(Field("Amount"), "min"),
+				MaxAs(Field("Amount"), "max"),
+				AverageAs(Field("rating"), "avg"),
+			}},
+			reader: newTestOrdersReader(),
+			expected: []map[string]any{
+				{"cnt": 5, "total": int64(105), "min": 5, "max": 40, "avg": 3.0},
+			},
+		},
+		{
+			name: "empty_input_without_group_by",
+			query: theQuery{columns: []Column{
+				CountAs(Field("*"), "cnt"),
+				SumAs(Field("Amount"), "total"),
+			}},
+			reader:   &EmptyReader{},
+			expected: []map[string]any{{"cnt": 0, "total": nil}},
+		},
+		{
+			name: "empty_input_with_group_by",
+			query: theQuery{
+				groupBy: []Expression{Field("Country")},
+				columns: []Column{CountAs(Field("*"), "cnt")},
+			},
+			reader:   &EmptyReader{},
+			expected: []map[string]any{},
+		},
+		{
+			name: "group_by_with_offset_and_limit",
+			query: theQuery{
+				groupBy: []Expression{Field("Country")},
+				columns: []Column{
+					{Expression: Field("Country")},
+					SumAs(Field("Amount"), "total"),
+					SumAs(Field("rating"), "ratings"),
+				},
+				offset: 1,
+				limit:  1,
+			},
+			reader: newTestOrdersReader(),
+			expected: []map[string]any{
+				{"Country": "UK", "total": int64(60), "ratings": 5.0},
+			},
+		},
+		{
+			name: "unsupported_group_by_expression",
+			query: theQuery{
+				groupBy: []Expression{function{Name: "UPPER", Args: []Expression{Field("Country")}}},
+				columns: []Column{CountAs(Field("*"), "cnt")},
+			},
+			reader: newTestOrdersReader(),
+			err:    "GROUP BY expression #0",
+		},
+		{
+			name: "group_by_with_order_by",
+			query: theQuery{
+				groupBy: []Expression{Field("Country")},
+				columns: []Column{
+					{Expression: Field("Country")},
+					SumAs(Field("Amount"), "total"),
+				},
+				orderBy: []OrderExpression{DescendingField("total")},
+			},
+			reader: newTestOrdersReader(),
+			expected: []map[string]any{
+				{"Country": "UK", "total": int64(60)},
+				{"Country": "IE", "total": int64(40)},
+				{"Country": "US", "total": int64(5)},
+			},
+		},
+		{
+			name: "order_by_unknown_alias",
+			query: theQuery{
+				columns: []Column{SumAs(Field("Amount"), "total")},
+				orderBy: []OrderExpression{AscendingField("Amount")},
+			},
+			reader: newTestOrdersReader(),
+			err:    "does not match any column alias",
+		},
+		{
+			name:   "sum_of_strings",
+			query:  theQuery{columns: []Column{SumAs(Field("Country"), "total")}},
+			reader: newTestOrdersReader(),
+			err:    "expects numeric values",
+		},
+		{
+			name: "group_by_normalizes_numbers",
+			query: theQuery{
+				groupBy: []Expression{Field("n")},
+				columns: []Column{{Expression: Field("n")}, CountAs(Field("*"), "cnt")},
+			},
+			reader: newTestMapsReader(
+				map[string]any{"n": int64(1)},
+				map[string]any{"n": 1},
+				map[string]any{"n": uint8(1)},
+				map[string]any{"n": 1.0},
+				map[string]any{"n": "1"},
+				map[string]any{"n": 1.5},
+			),
+			expected: []map[string]any{
+				{"n": int64(1), "cnt": 4},
+				{"n": "1", "cnt": 1},
+				{"n": 1.5, "cnt": 1},
+			},
+		},
+		{
+			name:   "sum_overflow",
+			query:  theQuery{columns: []Column{SumAs(Field("v"), "total")}},
+			reader: newTestMapsReader(map[string]any{"v": int64(math.MaxInt64)}, map[string]any{"v": 1}),
+			err:    "SUM() of integer values overflows int64",
+		},
+		{
+			name:   "sum_negative_overflow",
+			query:  theQuery{columns: []Column{SumAs(Field("v"), "total")}},
+			reader: newTestMapsReader(map[string]any{"v": int64(math.MinInt64)}, map[string]any{"v": -1}),
+			err:    "overflows int64",
+		},
+		{
+			name:   "sum_uint_overflow",
+			query:  theQuery{columns: []Column{SumAs(Field("v"), "total")}},
+			reader: newTestMapsReader(map[string]any{"v": uint64(math.MaxUint64)}),
+			err:    "overflows int64",
+		},
+		{
+			name:   "sum_overflow_with_floats",
+			query:  theQuery{columns: []Column{SumAs(Field("v"), "total")}},
+			reader: newTestMapsReader(map[string]any{"v": int64(math.MaxInt64)}, map[string]any{"v": 1}, map[string]any{"v": 0.5}),
+			expected: []map[string]any{
+				{"total": float64(math.MaxInt64) + 1.5},
+			},
+		},
+		{
+			name:   "sum_of_all",
+			query:  theQuery{columns: []Column{SumAs(Field("*"), "total")}},
+			reader: newTestOrdersReader(),
+			err:    "SUM(*) is not supported",
+		},
+		{
+			name:   "not_aggregate_function",
+			query:  theQuery{columns: []Column{{Expression: function{Name: "UPPER", Args: []Expression{Field("Country")}}}}},
+			reader: newTestOrdersReader(),
+			err:    "not an aggregate function",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := AggregateRecords(tt.reader, tt.query)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, rows)
+		})
+	}
+	t.Run("panics_on_nil_reader", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_, _ = AggregateRecords(nil, theQuery{})
+		})
+	})
+}
+
+func TestQueryAggregates(t *testing.T) {
+	query := From("orders").
+		WhereField("Amount", GreaterThen, 1).
+		GroupBy(Field("Country")).
+		Columns(Column{Expression: Field("Country")}, CountAs(Field("*"), "cnt")).
+		Limit(2).
+		SelectInto(nil)
+
+	t.Run("should_pass", func(t *testing.T) {
+		var baseQuery Query
+		qe := NewQueryExecutor(func(ctx context.Context, query Query) (Reader, error) {
+			baseQuery = query
+			return newTestOrdersReader(), nil
+		})
+		rows, err := QueryAggregates(context.Background(), qe, query)
+		assert.Nil(t, err)
+		assert.Equal(t, []map[string]any{
+			{"Country": "IE", "cnt": 2},
+			{"Country": "UK", "cnt": 2},
+		}, rows)
+		assert.Equal(t, 0, len(baseQuery.Columns()))
+		assert.Equal(t, 0, len(baseQuery.GroupBy()))
+		assert.Equal(t, 0, baseQuery.Limit())
+		assert.Equal(t, query.Where(), baseQuery.Where())
+		assert.NotNil(t, baseQuery.Into())
+		assert.Equal(t, reflect.Interface, baseQuery.Into()().Key().IDKind)
+	})
+	t.Run("reader_error", func(t *testing.T) {
+		qe := NewQueryExecutor(func(ctx context.Context, query Query) (Reader, error) {
+			return nil, errors.New("test error")
+		})
+		_, err := QueryAggregates(context.Background(), qe, query)
+		assert.ErrorContains(t, err, "test error")
+	})
+	t.Run("panics_on_nil_executor", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_, _ = QueryAggregates(context.Background(), nil, query)
+		})
+	})
+}
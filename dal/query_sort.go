@@ -0,0 +1,182 @@
+package dal
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SortRecords reads all records from a reader and returns them ordered by query.OrderBy(),
+// with query.Offset() records skipped and limited to query.Limit() records.
+//
+// Records are compared expression by expression, a descending expression reverses the order.
+// NULL values (nil or missing fields) go before any other value,
+// e.g. first in ascending order and last in descending order (same as in Firestore, SQLite & MySQL).
+// Values of different types are ordered as: NULL < bool < number < timestamp < string < bytes.
+// Records that are equal by all expressions keep the order in which they were read.
+//
+// If a limit is specified only offset+limit records are kept in memory using a bounded heap.
+func SortRecords(reader Reader, query Query) (records []Record, err error) {
+	if reader == nil {
+		panic("reader is a required parameter, got nil")
+	}
+	next := func() (Record, error) {
+		return reader.Next()
+	}
+	keysOf := func(record Record, orderBy []OrderExpression) (keys []any, err error) {
+		keys = make([]any, len(orderBy))
+		for i, o := range orderBy {
+			if keys[i], err = evaluateExpression(record, o.Expression()); err != nil {
+				return nil, fmt.Errorf("failed to evaluate ORDER BY expression #%d: %w", i, err)
+			}
+		}
+		return keys, nil
+	}
+	return sortItems(next, keysOf, query.OrderBy(), query.Offset(), query.Limit())
+}
+
+// NewSortedReader creates a reader that returns records of the given reader
+// ordered by query.OrderBy() and limited by query.Offset() & query.Limit().
+// Source records are read & sorted on the first call to Next(). See SortRecords for ordering rules.
+func NewSortedReader(reader Reader, query Query) Reader {
+	if reader == nil {
+		panic("reader is a required parameter, got nil")
+	}
+	return &sortedReader{source: reader, query: query}
+}
+
+var _ Reader = (*sortedReader)(nil)
+
+type sortedReader struct {
+	source  Reader
+	query   Query
+	sorted  Reader
+	sortErr error
+}
+
+func (r *sortedReader) Next() (Record, error) {
+	if r.sorted == nil && r.sortErr == nil {
+		var records []Record
+		if records, r.sortErr = SortRecords(r.source, r.query); r.sortErr == nil {
+			if len(records) == 0 {
+				r.sorted = EmptyReader{}
+			} else {
+				r.sorted = NewRecordsReader(records)
+			}
+		}
+	}
+	if r.sortErr != nil {
+		return nil, r.sortErr
+	}
+	return r.sorted.Next()
+}
+
+func (r *sortedReader) Cursor() (string, error) {
+	if r.sorted == nil {
+		return "", ErrReaderNotStarted
+	}
+	return r.sorted.Cursor()
+}
+
+func (r *sortedReader) Close() error {
+	if r.sorted != nil {
+		_ = r.sorted.Close()
+	}
+	return r.source.Close()
+}
+
+// sortEntry keeps an item with pre-calculated values of order expressions
+type sortEntry[T any] struct {
+	item T
+	keys []any
+	seq  int // position in the source, makes ordering stable
+}
+
+func lessSortEntries[T any](orderBy []OrderExpression, a, b *sortEntry[T]) bool {
+	for i, o := range orderBy {
+		c := compareValues(a.keys[i], b.keys[i])
+		if o.Descending() {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return a.seq < b.seq
+}
+
+// sortEntriesHeap is a max-heap - the top entry is the last one in the requested order
+type sortEntriesHeap[T any] struct {
+	orderBy []OrderExpression
+	entries []*sortEntry[T]
+}
+
+func (h *sortEntriesHeap[T]) Len() int { return len(h.entries) }
+
+func (h *sortEntriesHeap[T]) Less(i, j int) bool {
+	return lessSortEntries(h.orderBy, h.entries[j], h.entries[i])
+}
+
+func (h *sortEntriesHeap[T]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *sortEntriesHeap[T]) Push(x any) {
+	h.entries = append(h.entries, x.(*sortEntry[T]))
+}
+
+func (h *sortEntriesHeap[T]) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// sortItems reads items until ErrNoMoreRecords and returns them ordered, with offset & limit applied
+func sortItems[T any](
+	next func() (T, error),
+	keysOf func(item T, orderBy []OrderExpression) ([]any, error),
+	orderBy []OrderExpression,
+	offset, limit int,
+) (items []T, err error) {
+	if offset < 0 {
+		offset = 0
+	}
+	bounded := limit > 0
+	h := &sortEntriesHeap[T]{orderBy: orderBy}
+	for seq := 0; ; seq++ {
+		if bounded && len(orderBy) == 0 && len(h.entries) == offset+limit {
+			break // no ordering requested, so we can stop reading as soon as we have enough items
+		}
+		var item T
+		if item, err = next(); err != nil {
+			if errors.Is(err, ErrNoMoreRecords) {
+				err = nil
+				break
+			}
+			return nil, err
+		}
+		entry := &sortEntry[T]{item: item, seq: seq}
+		if entry.keys, err = keysOf(item, orderBy); err != nil {
+			return nil, err
+		}
+		switch {
+		case !bounded:
+			h.entries = append(h.entries, entry)
+		case len(h.entries) < offset+limit:
+			heap.Push(h, entry)
+		case lessSortEntries(orderBy, entry, h.entries[0]):
+			h.entries[0] = entry
+			heap.Fix(h, 0)
+		}
+	}
+	sort.Slice(h.entries, func(i, j int) bool {
+		return lessSortEntries(orderBy, h.entries[i], h.entries[j])
+	})
+	entries := applyOffsetAndLimit(h.entries, offset, limit)
+	items = make([]T, len(entries))
+	for i, entry := range entries {
+		items[i] = entry.item
+	}
+	return items, nil
+}
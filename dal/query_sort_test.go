@@ -0,0 +1,172 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testSortItem struct {
+	Name  string
+	Score any
+}
+
+func newTestSortReader() Reader {
+	items := []testSortItem{
+		{Name: "a", Score: 3},
+		{Name: "b", Score: nil},
+		{Name: "c", Score: 1},
+		{Name: "d", Score: 3},
+		{Name: "e", Score: 2.5},
+		{Name: "f", Score: nil},
+	}
+	records := make([]Record, len(items))
+	for i := range items {
+		records[i] = NewRecordWithData(NewKeyWithID("items", items[i].Name), &items[i]).SetError(nil)
+	}
+	return NewRecordsReader(records)
+}
+
+func sortedIDs(t *testing.T, records []Record) (ids []string) {
+	t.Helper()
+	ids = make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.Key().ID.(string)
+	}
+	return ids
+}
+
+type failingReader struct {
+	EmptyReader
+	err error
+}
+
+func (r failingReader) Next() (Record, error) {
+	return nil, r.err
+}
+
+func TestSortRecords(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		query    theQuery
+		expected []string
+	}{
+		{
+			name:     "no_order",
+			query:    theQuery{},
+			expected: []string{"a", "b", "c", "d", "e", "f"},
+		},
+		{
+			name:     "no_order_with_limit",
+			query:    theQuery{offset: 1, limit: 2},
+			expected: []string{"b", "c"},
+		},
+		{
+			name:     "ascending_nulls_first_and_stable",
+			query:    theQuery{orderBy: []OrderExpression{AscendingField("Score")}},
+			expected: []string{"b", "f", "c", "e", "a", "d"},
+		},
+		{
+			name:     "descending_nulls_last",
+			query:    theQuery{orderBy: []OrderExpression{DescendingField("Score")}},
+			expected: []string{"a", "d", "e", "c", "b", "f"},
+		},
+		{
+			name: "multi_column",
+			query: theQuery{orderBy: []OrderExpression{
+				DescendingField("Score"),
+				DescendingField("Name"),
+			}},
+			expected: []string{"d", "a", "e", "c", "f", "b"},
+		},
+		{
+			name: "by_id",
+			query: theQuery{orderBy: []OrderExpression{
+				Descending(FieldRef{Name: "id", IsID: true}),
+			}},
+			expected: []string{"f", "e", "d", "c", "b", "a"},
+		},
+		{
+			name: "with_limit_uses_heap",
+			query: theQuery{
+				orderBy: []OrderExpression{AscendingField("Score")},
+				limit:   3,
+			},
+			expected: []string{"b", "f", "c"},
+		},
+		{
+			name: "with_offset_and_limit",
+			query: theQuery{
+				orderBy: []OrderExpression{DescendingField("Score")},
+				offset:  1,
+				limit:   3,
+			},
+			expected: []string{"d", "e", "c"},
+		},
+		{
+			name: "offset_beyond_end",
+			query: theQuery{
+				orderBy: []OrderExpression{AscendingField("Score")},
+				offset:  10,
+			},
+			expected: []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := SortRecords(newTestSortReader(), tt.query)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, sortedIDs(t, records))
+		})
+	}
+	t.Run("reader_error", func(t *testing.T) {
+		_, err := SortRecords(failingReader{err: errors.New("test error")}, theQuery{})
+		assert.ErrorContains(t, err, "test error")
+	})
+	t.Run("unsupported_expression", func(t *testing.T) {
+		query := theQuery{orderBy: []OrderExpression{Ascending(function{Name: "UPPER"})}}
+		_, err := SortRecords(newTestSortReader(), query)
+		assert.ErrorContains(t, err, "ORDER BY expression #0")
+	})
+	t.Run("panics_on_nil_reader", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_, _ = SortRecords(nil, theQuery{})
+		})
+	})
+}
+
+func TestNewSortedReader(t *testing.T) {
+	t.Run("panics_on_nil_reader", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewSortedReader(nil, theQuery{})
+		})
+	})
+	t.Run("should_pass", func(t *testing.T) {
+		query := From("items").OrderBy(DescendingField("Name")).Limit(2).SelectInto(nil)
+		reader := NewSortedReader(newTestSortReader(), query)
+
+		cursor, err := reader.Cursor()
+		assert.ErrorIs(t, err, ErrReaderNotStarted)
+		assert.Equal(t, "", cursor)
+
+		records, err := ReadAll(context.Background(), reader, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"f", "e"}, sortedIDs(t, records))
+		assert.Nil(t, reader.Close())
+	})
+	t.Run("empty", func(t *testing.T) {
+		reader := NewSortedReader(EmptyReader{}, theQuery{})
+		_, err := reader.Next()
+		assert.ErrorIs(t, err, ErrNoMoreRecords)
+		_, err = reader.Cursor()
+		assert.ErrorIs(t, err, ErrNotSupported)
+		assert.Nil(t, reader.Close())
+	})
+	t.Run("error", func(t *testing.T) {
+		reader := NewSortedReader(failingReader{err: errors.New("test error")}, theQuery{})
+		_, err := reader.Next()
+		assert.ErrorContains(t, err, "test error")
+		_, err = reader.Next()
+		assert.ErrorContains(t, err, "test error")
+	})
+}
@@ -0,0 +1,46 @@
+package dal
+
+import "fmt"
+
+// NewParentKeysReader wraps a reader of query results to make sure keys of returned records
+// have parent keys populated and belong to the queried collection.
+// For a child collection (see FromChildren) a missing parent is set to the parent of the collection.
+// For a collection group (see FromCollectionGroup) parents are expected to be populated by an adapter,
+// records of a root collection with the group name keep nil parents.
+//
+// The reader is not applied to query results automatically,
+// DB adapters or callers wrap readers of their queries themselves, for example:
+//
+//	reader, err := db.QueryReader(ctx, query)
+//	if err != nil {
+//		return err
+//	}
+//	reader = dal.NewParentKeysReader(reader, *query.From())
+func NewParentKeysReader(reader Reader, from CollectionRef) Reader {
+	if reader == nil {
+		panic("reader is a required parameter, got nil")
+	}
+	return parentKeysReader{Reader: reader, from: from}
+}
+
+type parentKeysReader struct {
+	Reader
+	from CollectionRef
+}
+
+func (r parentKeysReader) Next() (record Record, err error) {
+	if record, err = r.Reader.Next(); err != nil {
+		return record, err
+	}
+	key := record.Key()
+	if key == nil {
+		return record, fmt.Errorf("record of queried collection %v has no key", r.from)
+	}
+	if key.parent == nil && !r.from.IsGroup && r.from.Parent != nil {
+		key.parent = r.from.Parent
+	}
+	if !r.from.Contains(key) {
+		return record, fmt.Errorf("record key %v does not belong to queried collection %v", key, r.from)
+	}
+	return record, nil
+}
@@ -0,0 +1,65 @@
+package dal
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func TestNewParentKeysReader(t *testing.T) {
+	user1 := NewKeyWithID("users", "u1")
+	user2 := NewKeyWithID("users", "u2")
+
+	t.Run("panics_on_nil_reader", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewParentKeysReader(nil, CollectionRef{Name: "orders"})
+		})
+	})
+	t.Run("sets_missing_parent_for_child_collection", func(t *testing.T) {
+		record := NewRecordWithIncompleteKey("orders", reflect.Int, nil)
+		record.Key().ID = 1
+		reader := NewParentKeysReader(NewRecordsReader([]Record{record}), NewCollectionRef("orders", "", user1))
+		r, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "users/u1/orders/1", r.Key().String())
+		_, err = reader.Next()
+		assert.ErrorIs(t, err, ErrNoMoreRecords)
+	})
+	t.Run("collection_group_keeps_parents", func(t *testing.T) {
+		reader := NewParentKeysReader(NewRecordsReader([]Record{
+			NewRecord(NewKeyWithParentAndID(user1, "orders", 1)),
+			NewRecord(NewKeyWithParentAndID(user2, "orders", 2)),
+		}), NewCollectionGroupRef("orders", ""))
+		records, err := SelectAllRecords(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		assert.True(t, EqualKeys(user2, records[1].Key().Parent()))
+	})
+	t.Run("wrong_parent", func(t *testing.T) {
+		reader := NewParentKeysReader(NewRecordsReader([]Record{
+			NewRecord(NewKeyWithParentAndID(user2, "orders", 1)),
+		}), NewCollectionRef("orders", "", user1))
+		_, err := reader.Next()
+		assert.ErrorContains(t, err, "does not belong to queried collection")
+	})
+	t.Run("collection_group_with_root_collection", func(t *testing.T) {
+		reader := NewParentKeysReader(NewRecordsReader([]Record{
+			NewRecord(NewKeyWithID("orders", 1)),
+		}), NewCollectionGroupRef("orders", ""))
+		r, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Nil(t, r.Key().Parent())
+		assert.Equal(t, "orders/1", r.Key().String())
+	})
+	t.Run("nil_key", func(t *testing.T) {
+		reader := NewParentKeysReader(NewRecordsReader([]Record{recordWithKey{Record: NewRecord(user1)}}), NewCollectionRef("orders", "", user1))
+		_, err := reader.Next()
+		assert.ErrorContains(t, err, "has no key")
+	})
+	t.Run("reader_error", func(t *testing.T) {
+		reader := NewParentKeysReader(failingReader{err: errors.New("test error")}, CollectionRef{Name: "orders"})
+		_, err := reader.Next()
+		assert.ErrorContains(t, err, "test error")
+	})
+}
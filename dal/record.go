@@ -45,10 +45,11 @@ type Record interface {
 }
 
 type record struct {
-	key     *Key
-	err     error
-	changed bool
-	data    any
+	key      *Key
+	err      error
+	changed  bool
+	data     any
+	snapshot map[string]any // normalized copy of data taken by TakeSnapshot()
 	//dataTo func(target any) error
 }
 
@@ -0,0 +1,101 @@
+package dal
+
+import (
+	"reflect"
+	"strings"
+)
+
+// GetFieldValue returns value of a field from record data.
+// Data can be a map with string keys or a struct (or a pointer to either of them).
+// Struct fields are matched by the name in a `dalgo` tag and then by the Go field name.
+// A dot separated name is resolved as a path to a nested field if there is no field with exactly such name.
+func GetFieldValue(data any, name string) (value any, found bool) {
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	v, found := fieldValue(indirectValue(data), name)
+	if !found || !v.IsValid() || !v.CanInterface() {
+		return nil, found
+	}
+	return v.Interface(), true
+}
+
+func fieldValue(v reflect.Value, name string) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+	if fv, found := directFieldValue(v, name); found {
+		return fv, true
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if fv, found := directFieldValue(v, name[:i]); found {
+			return fieldValue(indirectReflectValue(fv), name[i+1:])
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func indirectReflectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func directFieldValue(v reflect.Value, name string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		fv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !fv.IsValid() {
+			return fv, false
+		}
+		return indirectReflectValue(fv), true
+	case reflect.Struct:
+		if index, found := structFieldIndex(v.Type(), name); found {
+			fv, err := v.FieldByIndexErr(index)
+			if err != nil { // nil embedded pointer
+				return reflect.Value{}, true
+			}
+			return indirectReflectValue(fv), true
+		}
+	default:
+		// not a container of fields
+	}
+	return reflect.Value{}, false
+}
+
+// structFieldIndex finds an exported struct field by a `dalgo` tag name or by the Go field name
+func structFieldIndex(t reflect.Type, name string) (index []int, found bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if tagName, _ := parseDalgoTag(field.Tag); tagName == name {
+			return field.Index, true
+		}
+	}
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous && field.Name == name {
+			if tagName, _ := parseDalgoTag(field.Tag); tagName == "" || tagName == name {
+				return field.Index, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// parseDalgoTag parses a `dalgo:"name,option1,option2"` struct tag
+func parseDalgoTag(tag reflect.StructTag) (name string, options []string) {
+	s, ok := tag.Lookup("dalgo")
+	if !ok {
+		return "", nil
+	}
+	parts := strings.Split(s, ",")
+	return parts[0], parts[1:]
+}
@@ -0,0 +1,60 @@
+package dal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetFieldValue(t *testing.T) {
+	type address struct {
+		City string `dalgo:"city"`
+	}
+	type Base struct {
+		CreatedBy string
+	}
+	type user struct {
+		Base
+		Name     string
+		Email    string `dalgo:"email"`
+		Title    string `dalgo:"Name"`
+		Address  *address
+		Contacts map[string]any
+		hidden   string
+	}
+	u := user{
+		Base:     Base{CreatedBy: "admin"},
+		Name:     "John",
+		Email:    "john@example.com",
+		Title:    "Mr",
+		Address:  &address{City: "Dublin"},
+		Contacts: map[string]any{"phone": "123", "a.b": 1},
+		hidden:   "secret",
+	}
+	for _, tt := range []struct {
+		name          string
+		data          any
+		field         string
+		expectedValue any
+		expectedFound bool
+	}{
+		{name: "nil_data", data: nil, field: "Name"},
+		{name: "not_a_container", data: 1, field: "Name"},
+		{name: "by_tag", data: u, field: "email", expectedValue: "john@example.com", expectedFound: true},
+		{name: "tag_has_priority", data: &u, field: "Name", expectedValue: "Mr", expectedFound: true},
+		{name: "renamed_by_tag", data: &u, field: "Email"},
+		{name: "embedded", data: &u, field: "CreatedBy", expectedValue: "admin", expectedFound: true},
+		{name: "unexported", data: &u, field: "hidden"},
+		{name: "nested_struct", data: &u, field: "Address.city", expectedValue: "Dublin", expectedFound: true},
+		{name: "nested_map", data: &u, field: "Contacts.phone", expectedValue: "123", expectedFound: true},
+		{name: "map_key_with_dot", data: u.Contacts, field: "a.b", expectedValue: 1, expectedFound: true},
+		{name: "nil_map_value", data: map[string]any{"a": nil}, field: "a", expectedFound: true},
+		{name: "map_with_int_keys", data: map[int]any{1: "a"}, field: "1"},
+		{name: "data_wrapper", data: MakeRecordData(&u), field: "Name", expectedValue: "Mr", expectedFound: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			value, found := GetFieldValue(tt.data, tt.field)
+			assert.Equal(t, tt.expectedFound, found)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ErrNoSnapshot indicates a snapshot of record data has not been taken, see TakeSnapshot()
+var ErrNoSnapshot = errors.New("record has no snapshot")
+
+// TakeSnapshot stores a deep copy of record data so changes can be detected later by DiffRecord().
+// Data should be a struct or a map with string keys (or a pointer to one of them).
+// Struct fields are named by a `dalgo` tag or by the Go field name, same as in GetFieldValue().
+//
+// Use TrackChanges() to take snapshots automatically after records are loaded.
+func TakeSnapshot(r Record) error {
+	v, ok := r.(*record)
+	if !ok {
+		return fmt.Errorf("%w: snapshots of records of type %T", ErrNotSupported, r)
+	}
+	snapshot, err := snapshotData(v.Data())
+	if err != nil {
+		return fmt.Errorf("failed to take snapshot of record data for key=%v: %w", v.key, err)
+	}
+	v.snapshot = snapshot
+	return nil
+}
+
+// HasSnapshot checks if a snapshot of record data has been taken
+func HasSnapshot(r Record) bool {
+	v, ok := r.(*record)
+	return ok && v.snapshot != nil
+}
+
+// DiffRecord compares current record data with a snapshot taken by TakeSnapshot()
+// and returns a minimal list of updates to apply the changes. See DiffData().
+func DiffRecord(r Record) ([]Update, error) {
+	v, ok := r.(*record)
+	if !ok || v.snapshot == nil {
+		return nil, fmt.Errorf("%w: key=%v", ErrNoSnapshot, r.Key())
+	}
+	current, err := snapshotData(v.Data())
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff record data for key=%v: %w", v.key, err)
+	}
+	return diffMaps(nil, v.snapshot, current), nil
+}
+
+// DiffData returns a minimal list of updates that changes `before` data into `after` data.
+// Nested structs & maps are compared field by field, slices are replaced as a whole.
+// Removed map keys are reported with DeleteField value. Updates are ordered by FieldPath.
+func DiffData(before, after any) ([]Update, error) {
+	b, err := snapshotData(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := snapshotData(after)
+	if err != nil {
+		return nil, err
+	}
+	return diffMaps(nil, b, a), nil
+}
+
+// TrackChanges registers an after load hook that takes a snapshot of each loaded record,
+// so SaveChanges() can write just changed fields. Use with a DB created by NewDBWithHooks().
+func TrackChanges(hooks *HookRegistry, scopes ...HookScope) {
+	hooks.AfterLoad(func(ctx context.Context, record Record) error {
+		return TakeSnapshot(record)
+	}, scopes...)
+}
+
+// SaveChanges writes changed records within a transaction.
+// For a record with a snapshot only changed fields are written using Update, unchanged records are skipped.
+// Records without a snapshot are written as a whole using SetMulti.
+// New snapshots are taken after a successful write.
+func SaveChanges(ctx context.Context, tx ReadwriteTransaction, records ...Record) error {
+	var toSet []Record
+	for _, r := range records {
+		if !HasSnapshot(r) {
+			toSet = append(toSet, r)
+			continue
+		}
+		updates, err := DiffRecord(r)
+		if err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if err = tx.Update(ctx, r.Key(), updates); err != nil {
+			return fmt.Errorf("failed to update record %v: %w", r.Key(), err)
+		}
+		if err = TakeSnapshot(r); err != nil {
+			return err
+		}
+	}
+	if len(toSet) > 0 {
+		if err := tx.SetMulti(ctx, toSet); err != nil {
+			return fmt.Errorf("failed to set %d records: %w", len(toSet), err)
+		}
+		for _, r := range toSet {
+			if _, ok := r.(*record); ok {
+				if err := TakeSnapshot(r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func snapshotData(data any) (map[string]any, error) {
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	v := indirectValue(data)
+	if !v.IsValid() {
+		return make(map[string]any), nil
+	}
+	m, ok := snapshotValue(v).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: data of type %T, expected a struct or a map with string keys", ErrNotSupported, data)
+	}
+	return m, nil
+}
+
+// snapshotValue returns a deep copy of a value with structs & maps converted into map[string]any
+// and slices converted into []any
+func snapshotValue(v reflect.Value) any {
+	v = indirectReflectValue(v)
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if _, isTime := v.Interface().(time.Time); isTime {
+			return v.Interface()
+		}
+		m := make(map[string]any)
+		for _, field := range reflect.VisibleFields(v.Type()) {
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			name, _ := parseDalgoTag(field.Tag)
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fv, err := v.FieldByIndexErr(field.Index)
+			if err != nil { // nil embedded pointer
+				continue
+			}
+			m[name] = snapshotValue(fv)
+		}
+		if len(m) == 0 { // a struct without exported fields is treated as a scalar value
+			return v.Interface()
+		}
+		return m
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = snapshotValue(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 { // bytes
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			s[i] = snapshotValue(v.Index(i))
+		}
+		return s
+	default:
+		return v.Interface()
+	}
+}
+
+func diffMaps(path FieldPath, before, after map[string]any) (updates []Update) {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := append(path[:len(path):len(path)], name)
+		b, inBefore := before[name]
+		a, inAfter := after[name]
+		switch {
+		case !inAfter:
+			updates = append(updates, Update{FieldPath: fieldPath, Value: DeleteField})
+		case !inBefore:
+			updates = append(updates, Update{FieldPath: fieldPath, Value: a})
+		default:
+			bm, bIsMap := b.(map[string]any)
+			am, aIsMap := a.(map[string]any)
+			if bIsMap && aIsMap {
+				updates = append(updates, diffMaps(fieldPath, bm, am)...)
+			} else if !reflect.DeepEqual(a, b) {
+				updates = append(updates, Update{FieldPath: fieldPath, Value: a})
+			}
+		}
+	}
+	return updates
+}
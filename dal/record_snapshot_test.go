@@ -0,0 +1,141 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSnapshotAddress struct {
+	City string `dalgo:"city" json:"city"`
+	Zip  string `dalgo:"zip" json:"zip"`
+}
+
+type testSnapshotUser struct {
+	Name     string               `dalgo:"name" json:"name"`
+	Age      int                  `dalgo:"age" json:"age"`
+	Address  *testSnapshotAddress `dalgo:"address" json:"address"`
+	Tags     []string             `dalgo:"tags" json:"tags"`
+	Props    map[string]any       `dalgo:"props" json:"props"`
+	Internal string               `dalgo:"-" json:"-"`
+}
+
+func TestDiffData(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		name          string
+		before, after any
+		expected      []Update
+		expectedErr   error
+	}{
+		{name: "nil", before: nil, after: nil},
+		{name: "not_supported", before: 1, after: 2, expectedErr: ErrNotSupported},
+		{
+			name:   "unchanged",
+			before: testSnapshotUser{Name: "Ann", Tags: []string{"a"}, Address: &testSnapshotAddress{City: "Cork"}},
+			after:  &testSnapshotUser{Name: "Ann", Tags: []string{"a"}, Address: &testSnapshotAddress{City: "Cork"}, Internal: "x"},
+		},
+		{
+			name: "changed",
+			before: testSnapshotUser{
+				Name: "Ann", Age: 30, Tags: []string{"a"},
+				Address: &testSnapshotAddress{City: "Cork", Zip: "T12"},
+				Props:   map[string]any{"keep": 1, "drop": 2, "nested": map[string]any{"x": 1, "y": 2}},
+			},
+			after: testSnapshotUser{
+				Name: "Ann", Age: 31, Tags: []string{"a", "b"},
+				Address: &testSnapshotAddress{City: "Dublin", Zip: "T12"},
+				Props:   map[string]any{"keep": 1, "add": created, "nested": map[string]any{"x": 1}},
+			},
+			expected: []Update{
+				{FieldPath: FieldPath{"address", "city"}, Value: "Dublin"},
+				{FieldPath: FieldPath{"age"}, Value: 31},
+				{FieldPath: FieldPath{"props", "add"}, Value: created},
+				{FieldPath: FieldPath{"props", "drop"}, Value: DeleteField},
+				{FieldPath: FieldPath{"props", "nested", "y"}, Value: DeleteField},
+				{FieldPath: FieldPath{"tags"}, Value: []any{"a", "b"}},
+			},
+		},
+		{
+			name:     "nested_struct_added",
+			before:   testSnapshotUser{},
+			after:    testSnapshotUser{Address: &testSnapshotAddress{City: "Cork"}},
+			expected: []Update{{FieldPath: FieldPath{"address"}, Value: map[string]any{"city": "Cork", "zip": ""}}},
+		},
+		{
+			name:     "maps",
+			before:   map[string]any{"a": 1, "b": 2},
+			after:    map[string]any{"a": 1, "c": 3},
+			expected: []Update{{FieldPath: FieldPath{"b"}, Value: DeleteField}, {FieldPath: FieldPath{"c"}, Value: 3}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := DiffData(tt.before, tt.after)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, updates)
+			for _, u := range updates {
+				assert.Nil(t, u.Validate())
+			}
+		})
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	data := &testSnapshotUser{Name: "Ann", Tags: []string{"a"}}
+	record := NewRecordWithData(NewKeyWithID("users", "u1"), data).SetError(nil)
+	assert.False(t, HasSnapshot(record))
+	_, err := DiffRecord(record)
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+
+	assert.Nil(t, TakeSnapshot(record))
+	assert.True(t, HasSnapshot(record))
+	data.Tags[0] = "b" // snapshot is a deep copy
+	updates, err := DiffRecord(record)
+	assert.Nil(t, err)
+	assert.Equal(t, []Update{{FieldPath: FieldPath{"tags"}, Value: []any{"b"}}}, updates)
+
+	record = NewRecordWithData(NewKeyWithID("users", "u1"), new(int)).SetError(nil)
+	assert.ErrorIs(t, TakeSnapshot(record), ErrNotSupported)
+}
+
+func TestSaveChanges(t *testing.T) {
+	ctx := context.Background()
+	memDB := newMemoryDB()
+	memDB.putData(NewKeyWithID("users", "u1"), testSnapshotUser{Name: "Ann", Age: 30, Props: map[string]any{"a": "1", "b": "2"}})
+	memDB.putData(NewKeyWithID("users", "u2"), testSnapshotUser{Name: "Bob"})
+	hooks := NewHookRegistry()
+	TrackChanges(hooks)
+	db := NewDBWithHooks(memDB, hooks)
+
+	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx ReadwriteTransaction) error {
+		u1 := NewRecordWithData(NewKeyWithID("users", "u1"), new(testSnapshotUser))
+		u2 := NewRecordWithData(NewKeyWithID("users", "u2"), new(testSnapshotUser))
+		if err := tx.GetMulti(ctx, []Record{u1, u2}); err != nil {
+			return err
+		}
+		user1 := u1.Data().(*testSnapshotUser)
+		user1.Age = 31
+		delete(user1.Props, "b")
+		u3 := NewRecordWithData(NewKeyWithID("users", "u3"), &testSnapshotUser{Name: "Cid"})
+		memDB.calls = nil
+		if err := SaveChanges(ctx, tx, u1, u2, u3); err != nil {
+			return err
+		}
+		if !HasSnapshot(u3) {
+			return errors.New("expected snapshot of u3")
+		}
+		return SaveChanges(ctx, tx, u1, u2, u3) // nothing has changed
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Update:1", "SetMulti:1"}, memDB.calls)
+	assert.Equal(t, map[string]any{"name": "Ann", "age": float64(31), "address": nil, "tags": nil, "props": map[string]any{"a": "1"}},
+		memDB.getData(NewKeyWithID("users", "u1")))
+	assert.Equal(t, "Cid", memDB.getData(NewKeyWithID("users", "u3"))["name"])
+}
@@ -0,0 +1,327 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrValidationFailed indicates record data does not satisfy validation rules
+var ErrValidationFailed = errors.New("validation failed")
+
+// FieldError describes a single failed validation rule of a record field
+type FieldError struct {
+	Key       *Key      // Key of the record, can be nil
+	FieldPath FieldPath // FieldPath to the field, slice & map items are referenced by index or map key
+	Rule      string    // Rule that failed, e.g. "nonzero" or "max"
+	Param     string    // Param of the rule, e.g. "10" for "max=10"
+	Message   string
+}
+
+// Error implements error interface
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(e.FieldPath, "."), e.Message)
+}
+
+// ValidationError is returned when record data does not satisfy rules defined by `dalgo` struct tags
+type ValidationError struct {
+	Key    *Key
+	Errors []FieldError
+}
+
+// Error implements error interface
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		s[i] = fieldErr.Error()
+	}
+	return fmt.Sprintf("%v for key=%v: %s", ErrValidationFailed, e.Key, strings.Join(s, "; "))
+}
+
+// Unwrap allows to check with errors.Is(err, ErrValidationFailed) & errors.As(err, &FieldError{})
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors)+1)
+	errs = append(errs, ErrValidationFailed)
+	for _, fieldErr := range e.Errors {
+		errs = append(errs, fieldErr)
+	}
+	return errs
+}
+
+// ValidateData validates struct data by rules defined in `dalgo` struct tags, for example:
+//
+//	type User struct {
+//		Email  string   `dalgo:"email,nonzero,email"`
+//		Role   string   `dalgo:"role,oneof=admin user"`
+//		Age    int      `dalgo:"age,nonzero,min=18,max=150"`
+//		Code   string   `dalgo:"code,len=3,regexp=^[A-Z]+$"`
+//		Tags   []string `dalgo:"tags,max=10,dive,nonzero,max=20"`
+//		Parent *Address `dalgo:"address,required"`
+//	}
+//
+// Supported rules are `required`, `nonzero`, `min`, `max`, `len`, `oneof` (space separated values), `email` & `regexp`.
+// The `required` rule means "not nil" as in DDL & updates generated by the orm package,
+// so it fails just for nil pointers & interfaces and can be combined with `default=`.
+// The `nonzero` rule fails for zero values, including empty strings, slices & maps.
+// Other rules are not checked for zero values, so `min=18` passes for 0 & `oneof=a b` passes for "" -
+// add `nonzero` to reject them, like for the `age` field above.
+// For strings min, max & len compare number of characters, for slices & maps number of items.
+// A `regexp` rule should be the last one in a tag as its pattern can contain commas.
+// Rules listed after `dive` are applied to items of a slice, an array or a map.
+// Nested structs (including items of slices & maps) are validated recursively.
+// Options unknown to the validator are ignored, so tags can hold options for other features.
+//
+// Returns a *ValidationError or nil. It is called by BeforeSave().
+func ValidateData(key *Key, data any) error {
+	if wrapper, ok := data.(DataWrapper); ok {
+		data = wrapper.Data()
+	}
+	v := indirectValue(data)
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return nil
+	}
+	validationErr := &ValidationError{Key: key}
+	validateStruct(v, nil, validationErr)
+	if len(validationErr.Errors) == 0 {
+		return nil
+	}
+	return validationErr
+}
+
+type validationRule struct {
+	name    string
+	param   string
+	number  float64
+	pattern *regexp.Regexp
+	values  []string
+}
+
+type validatedField struct {
+	index []int
+	name  string
+	rules []validationRule
+	dive  []validationRule
+}
+
+type validatedStruct struct {
+	fields []validatedField
+	err    error // invalid rules
+}
+
+var validatedStructs sync.Map // reflect.Type => *validatedStruct
+
+func getValidatedStruct(t reflect.Type) *validatedStruct {
+	if cached, ok := validatedStructs.Load(t); ok {
+		return cached.(*validatedStruct)
+	}
+	vs := &validatedStruct{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, options := parseDalgoTag(field.Tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		vf := validatedField{index: field.Index, name: name}
+		var err error
+		if vf.rules, vf.dive, err = parseValidationRules(options); err != nil {
+			vs.err = fmt.Errorf("invalid validation rules for field %v.%v: %w", t.Name(), field.Name, err)
+			break
+		}
+		vs.fields = append(vs.fields, vf)
+	}
+	cached, _ := validatedStructs.LoadOrStore(t, vs)
+	return cached.(*validatedStruct)
+}
+
+func parseValidationRules(options []string) (rules, dive []validationRule, err error) {
+	target := &rules
+	for i := 0; i < len(options); i++ {
+		name, param, _ := strings.Cut(strings.TrimSpace(options[i]), "=")
+		rule := validationRule{name: name, param: param}
+		switch name {
+		case "dive":
+			target = &dive
+			continue
+		case "required", "nonzero", "email":
+		case "min", "max", "len":
+			if rule.number, err = strconv.ParseFloat(param, 64); err != nil {
+				return nil, nil, fmt.Errorf("rule %v expects a number, got %q", name, param)
+			}
+		case "oneof":
+			rule.values = strings.Fields(param)
+		case "regexp":
+			rule.param = strings.Join(append([]string{param}, options[i+1:]...), ",")
+			if rule.pattern, err = regexp.Compile(rule.param); err != nil {
+				return nil, nil, err
+			}
+			i = len(options)
+		default:
+			continue // not a validation option
+		}
+		*target = append(*target, rule)
+	}
+	return rules, dive, nil
+}
+
+func validateStruct(v reflect.Value, path FieldPath, validationErr *ValidationError) {
+	vs := getValidatedStruct(v.Type())
+	if vs.err != nil {
+		validationErr.Errors = append(validationErr.Errors, FieldError{
+			Key: validationErr.Key, FieldPath: path, Rule: "tag", Message: vs.err.Error(),
+		})
+		return
+	}
+	for _, field := range vs.fields {
+		fv, err := v.FieldByIndexErr(field.index)
+		if err != nil { // nil embedded pointer
+			fv = reflect.Value{}
+		}
+		fieldPath := append(path[:len(path):len(path)], field.name)
+		validateValue(fv, fieldPath, field.rules, field.dive, validationErr)
+	}
+}
+
+func validateValue(v reflect.Value, path FieldPath, rules, dive []validationRule, validationErr *ValidationError) {
+	if !checkRules(v, path, rules, validationErr) {
+		return
+	}
+	v = indirectReflectValue(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(v, path, validationErr)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)), dive, nil, validationErr)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+		}
+		sort.Sort(mapKeysByName{keys: keys, names: names}) // for a deterministic order of errors
+		for i, k := range keys {
+			validateValue(v.MapIndex(k), append(path[:len(path):len(path)], names[i]), dive, nil, validationErr)
+		}
+	default:
+		// a scalar value
+	}
+}
+
+// checkRules returns false if the value is empty, so nested values should not be validated.
+// Zero structs are still validated as they can have non-zero fields.
+func checkRules(v reflect.Value, path FieldPath, rules []validationRule, validationErr *ValidationError) bool {
+	v = indirectReflectValue(v)
+	isNil := !v.IsValid() // nil pointers & interfaces
+	isZero := isNil || v.IsZero() || (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+	for _, rule := range rules {
+		var message string
+		switch {
+		case rule.name == "required":
+			if isNil {
+				message = "is required"
+			}
+		case rule.name == "nonzero":
+			if isZero {
+				message = "should not be empty"
+			}
+		case !isZero:
+			message = checkRule(v, rule)
+		}
+		if message != "" {
+			validationErr.Errors = append(validationErr.Errors, FieldError{
+				Key:       validationErr.Key,
+				FieldPath: path,
+				Rule:      rule.name,
+				Param:     rule.param,
+				Message:   message,
+			})
+		}
+	}
+	return !isNil && (v.Kind() == reflect.Struct || !isZero)
+}
+
+// checkRule returns an error message if the value does not satisfy the rule
+func checkRule(v reflect.Value, rule validationRule) string {
+	switch rule.name {
+	case "min", "max", "len":
+		size, what, ok := validationSize(v)
+		if !ok {
+			return fmt.Sprintf("rule %v is not applicable to values of type %v", rule.name, v.Type())
+		}
+		switch {
+		case rule.name == "min" && size < rule.number:
+			return fmt.Sprintf("%s should be at least %v, got %v", what, rule.param, size)
+		case rule.name == "max" && size > rule.number:
+			return fmt.Sprintf("%s should be at most %v, got %v", what, rule.param, size)
+		case rule.name == "len" && size != rule.number:
+			return fmt.Sprintf("%s should be exactly %v, got %v", what, rule.param, size)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, value := range rule.values {
+			if s == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("should be one of [%s], got %q", strings.Join(rule.values, " "), s)
+	case "email":
+		if v.Kind() != reflect.String {
+			return fmt.Sprintf("rule email is not applicable to values of type %v", v.Type())
+		}
+		if address, err := mail.ParseAddress(v.String()); err != nil || address.Address != v.String() {
+			return fmt.Sprintf("should be a valid email address, got %q", v.String())
+		}
+	case "regexp":
+		if v.Kind() != reflect.String {
+			return fmt.Sprintf("rule regexp is not applicable to values of type %v", v.Type())
+		}
+		if !rule.pattern.MatchString(v.String()) {
+			return fmt.Sprintf("should match %v, got %q", rule.param, v.String())
+		}
+	}
+	return ""
+}
+
+// validationSize returns a value to be compared by min, max & len rules
+func validationSize(v reflect.Value) (size float64, what string, ok bool) {
+	switch {
+	case v.Kind() == reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "length", true
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array || v.Kind() == reflect.Map:
+		return float64(v.Len()), "number of items", true
+	case v.CanInt():
+		return float64(v.Int()), "value", true
+	case v.CanUint():
+		return float64(v.Uint()), "value", true
+	case v.CanFloat():
+		return v.Float(), "value", true
+	}
+	return 0, "", false
+}
+
+type mapKeysByName struct {
+	keys  []reflect.Value
+	names []string
+}
+
+func (v mapKeysByName) Len() int { return len(v.keys) }
+
+func (v mapKeysByName) Less(i, j int) bool { return v.names[i] < v.names[j] }
+
+func (v mapKeysByName) Swap(i, j int) {
+	v.keys[i], v.keys[j] = v.keys[j], v.keys[i]
+	v.names[i], v.names[j] = v.names[j], v.names[i]
+}
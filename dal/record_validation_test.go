@@ -0,0 +1,157 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValidationAddress struct {
+	City string `dalgo:"city,nonzero"`
+	Zip  string `dalgo:"zip,regexp=^[0-9]{3,5}$"`
+}
+
+type testValidationUser struct {
+	Email     string                           `dalgo:"email,nonzero,email"`
+	Role      string                           `dalgo:"role,oneof=admin user"`
+	Age       int                              `dalgo:"age,min=18,max=150"`
+	Code      string                           `dalgo:"code,len=3"`
+	Tags      []string                         `dalgo:"tags,max=2,dive,nonzero,max=5"`
+	Address   *testValidationAddress           `dalgo:"address"`
+	Addresses map[string]testValidationAddress `dalgo:"addresses"`
+	Nickname  string                           `dalgo:"-"`
+	Note      string
+}
+
+func validUser() testValidationUser {
+	return testValidationUser{
+		Email:   "ann@example.com",
+		Role:    "admin",
+		Age:     30,
+		Code:    "ABC",
+		Tags:    []string{"a", "b"},
+		Address: &testValidationAddress{City: "Dublin", Zip: "12345"},
+	}
+}
+
+func TestValidateData(t *testing.T) {
+	key := NewKeyWithID("users", "u1")
+	for _, tt := range []struct {
+		name     string
+		data     any
+		expected []FieldError
+	}{
+		{name: "nil", data: nil},
+		{name: "map", data: map[string]any{"a": 1}},
+		{name: "valid", data: validUser()},
+		{name: "valid_pointer", data: func() any { u := validUser(); return &u }()},
+		{name: "valid_wrapped", data: MakeRecordData(validUser())},
+		{
+			name: "empty",
+			data: testValidationUser{},
+			expected: []FieldError{
+				{Key: key, FieldPath: FieldPath{"email"}, Rule: "nonzero", Message: "should not be empty"},
+			},
+		},
+		{
+			name: "nil_pointer",
+			data: struct {
+				Age *int `dalgo:"age,min=18"`
+			}{},
+		},
+		{
+			name: "required_is_not_nil",
+			data: struct {
+				Count   int                    `dalgo:"count,required,default=0"`
+				Tags    []string               `dalgo:"tags,required"`
+				Address *testValidationAddress `dalgo:"address,required"`
+				Value   any                    `dalgo:"value,required"`
+			}{},
+			expected: []FieldError{
+				{Key: key, FieldPath: FieldPath{"address"}, Rule: "required", Message: "is required"},
+				{Key: key, FieldPath: FieldPath{"value"}, Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "nonzero_skips_other_rules",
+			data: struct {
+				Age  int    `dalgo:"age,nonzero,min=18"`
+				Role string `dalgo:"role,nonzero,oneof=admin user"`
+			}{},
+			expected: []FieldError{
+				{Key: key, FieldPath: FieldPath{"age"}, Rule: "nonzero", Message: "should not be empty"},
+				{Key: key, FieldPath: FieldPath{"role"}, Rule: "nonzero", Message: "should not be empty"},
+			},
+		},
+		{
+			name: "invalid",
+			data: testValidationUser{
+				Email:   "not an email",
+				Role:    "guest",
+				Age:     10,
+				Code:    "ABCD",
+				Tags:    []string{"a", "", "toolong"},
+				Address: &testValidationAddress{Zip: "12"},
+				Addresses: map[string]testValidationAddress{
+					"home": {City: "Cork"},
+					"work": {},
+				},
+			},
+			expected: []FieldError{
+				{Key: key, FieldPath: FieldPath{"email"}, Rule: "email", Message: `should be a valid email address, got "not an email"`},
+				{Key: key, FieldPath: FieldPath{"role"}, Rule: "oneof", Param: "admin user", Message: `should be one of [admin user], got "guest"`},
+				{Key: key, FieldPath: FieldPath{"age"}, Rule: "min", Param: "18", Message: "value should be at least 18, got 10"},
+				{Key: key, FieldPath: FieldPath{"code"}, Rule: "len", Param: "3", Message: "length should be exactly 3, got 4"},
+				{Key: key, FieldPath: FieldPath{"tags"}, Rule: "max", Param: "2", Message: "number of items should be at most 2, got 3"},
+				{Key: key, FieldPath: FieldPath{"tags", "1"}, Rule: "nonzero", Message: "should not be empty"},
+				{Key: key, FieldPath: FieldPath{"tags", "2"}, Rule: "max", Param: "5", Message: "length should be at most 5, got 7"},
+				{Key: key, FieldPath: FieldPath{"address", "city"}, Rule: "nonzero", Message: "should not be empty"},
+				{Key: key, FieldPath: FieldPath{"address", "zip"}, Rule: "regexp", Param: "^[0-9]{3,5}$", Message: `should match ^[0-9]{3,5}$, got "12"`},
+				{Key: key, FieldPath: FieldPath{"addresses", "work", "city"}, Rule: "nonzero", Message: "should not be empty"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateData(key, tt.data)
+			if tt.expected == nil {
+				assert.Nil(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.True(t, errors.Is(err, ErrValidationFailed))
+			assert.Equal(t, key, validationErr.Key)
+			assert.Equal(t, tt.expected, validationErr.Errors)
+		})
+	}
+}
+
+func TestValidateData_errorMessage(t *testing.T) {
+	err := ValidateData(NewKeyWithID("users", "u1"), testValidationUser{Role: "x", Age: 20, Code: "ABC"})
+	assert.EqualError(t, err, `validation failed for key=users/u1: email: should not be empty; role: should be one of [admin user], got "x"`)
+	var fieldErr FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, FieldPath{"email"}, fieldErr.FieldPath)
+}
+
+func TestValidateData_invalidTag(t *testing.T) {
+	type invalid struct {
+		Count int `dalgo:"count,min=abc"`
+	}
+	err := ValidateData(nil, invalid{Count: 1})
+	assert.ErrorContains(t, err, "rule min expects a number")
+}
+
+func TestBeforeSave_validatesTags(t *testing.T) {
+	record := NewRecordWithData(NewKeyWithID("users", "u1"), &testValidationUser{})
+	err := BeforeSave(context.Background(), nil, record)
+	assert.ErrorIs(t, err, ErrValidationFailed)
+
+	type counter struct {
+		Count int `dalgo:"count,required,default=0"`
+	}
+	record = NewRecordWithData(NewKeyWithID("counters", "c1"), &counter{})
+	assert.Nil(t, BeforeSave(context.Background(), nil, record))
+}
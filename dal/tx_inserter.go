@@ -2,6 +2,7 @@ package dal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 )
 
@@ -26,14 +27,29 @@ type InsertOptions interface {
 	IDGenerator() IDGenerator
 }
 
+// InsertAttempts returns max number of attempts to generate a unique ID set by WithRandomID(),
+// it is 1 for options that do not define it
+func InsertAttempts(options InsertOptions) int {
+	if withAttempts, ok := options.(interface{ Attempts() int }); ok && withAttempts.Attempts() > 0 {
+		return withAttempts.Attempts()
+	}
+	return 1
+}
+
 type insertOptions struct {
 	idGenerator IDGenerator
+	attempts    int
 }
 
 func (v insertOptions) IDGenerator() IDGenerator {
 	return v.idGenerator
 }
 
+// Attempts returns max number of attempts to generate a unique ID, see InsertAttempts()
+func (v insertOptions) Attempts() int {
+	return v.attempts
+}
+
 var _ InsertOptions = (*insertOptions)(nil)
 
 // NewInsertOptions creates insert options
@@ -48,6 +64,22 @@ func NewInsertOptions(opts ...InsertOption) InsertOptions {
 // InsertOption defines a contract for an insert option
 type InsertOption func(options *insertOptions)
 
+// WithRandomID requests to generate an ID for an inserted record.
+// A generated ID is checked for collision with existing records and regenerated up to `attempts` times.
+// See NewRandomIDInserter for a wrapper that implements this for any adapter.
+func WithRandomID(generator IDGenerator, attempts int) InsertOption {
+	if generator == nil {
+		panic("generator is a required parameter, got nil")
+	}
+	if attempts < 1 {
+		panic(fmt.Sprintf("attempts should be a positive number, got %d", attempts))
+	}
+	return func(options *insertOptions) {
+		options.idGenerator = generator
+		options.attempts = attempts
+	}
+}
+
 type randomStringOptions struct {
 	length int
 	prefix string
@@ -128,3 +160,80 @@ func InsertWithRandomID(
 }
 
 var ErrExceedsMaxNumberOfAttempts = fmt.Errorf("exceeds max number of attempts")
+
+// InsertSession defines methods required to insert records with generated IDs
+type InsertSession interface {
+	Getter
+	Inserter
+	MultiInserter
+}
+
+// NewRandomIDInserter wraps a session so Insert & InsertMulti generate IDs for records
+// if the WithRandomID option is passed. Collisions are checked using Get of the session itself.
+// Calls without an ID generator are passed to the session as is.
+// This is intended to be used by DALgo DB drivers so they do not need to implement the generate/check/insert loop.
+func NewRandomIDInserter(session InsertSession) InsertSession {
+	if session == nil {
+		panic("session is a required parameter, got nil")
+	}
+	return randomIDInserter{InsertSession: session}
+}
+
+type randomIDInserter struct {
+	InsertSession
+}
+
+func (v randomIDInserter) Insert(ctx context.Context, record Record, opts ...InsertOption) error {
+	options := NewInsertOptions(opts...)
+	generateID := options.IDGenerator()
+	if generateID == nil {
+		return v.InsertSession.Insert(ctx, record, opts...)
+	}
+	exists := func(key *Key) error {
+		return v.exists(ctx, key)
+	}
+	insert := func(record Record) error {
+		return v.InsertSession.Insert(ctx, record)
+	}
+	return InsertWithRandomID(ctx, record, generateID, InsertAttempts(options), exists, insert)
+}
+
+func (v randomIDInserter) InsertMulti(ctx context.Context, records []Record, opts ...InsertOption) error {
+	options := NewInsertOptions(opts...)
+	generateID := options.IDGenerator()
+	if generateID == nil {
+		return v.InsertSession.InsertMulti(ctx, records, opts...)
+	}
+	generated := make(map[string]struct{}, len(records))
+	for i, record := range records {
+		exists := func(key *Key) error {
+			if _, ok := generated[key.Canonical()]; ok {
+				return nil // collision with a record of the same batch
+			}
+			return v.exists(ctx, key)
+		}
+		insert := func(record Record) error { // the actual insert is done below for all records at once
+			generated[record.Key().Canonical()] = struct{}{}
+			return nil
+		}
+		if err := InsertWithRandomID(ctx, record, generateID, InsertAttempts(options), exists, insert); err != nil {
+			for _, r := range records[:i+1] {
+				r.Key().ID = nil
+			}
+			return fmt.Errorf("failed to generate ID for record #%d: %w", i, err)
+		}
+	}
+	return v.InsertSession.InsertMulti(ctx, records)
+}
+
+// exists returns nil if a record exists, ErrRecordNotFound if it does not or an error of a failed read
+func (v randomIDInserter) exists(ctx context.Context, key *Key) error {
+	r := &record{key: key, data: new(map[string]any)}
+	if err := v.Get(ctx, r); err != nil {
+		return err
+	}
+	if errors.Is(r.err, NoError) {
+		return nil
+	}
+	return r.err
+}
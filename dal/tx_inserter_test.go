@@ -227,3 +227,146 @@ func TestWithIDGenerator(t *testing.T) {
 		})
 	}
 }
+
+type insertSessionMock struct {
+	existing map[string]bool
+	inserted []Record
+	readErr  error // set as an error of read records
+}
+
+func (v *insertSessionMock) Get(_ context.Context, record Record) error {
+	if v.readErr != nil {
+		record.SetError(v.readErr)
+	} else if v.existing[record.Key().String()] {
+		record.SetError(nil)
+	} else {
+		record.SetError(NewErrNotFoundByKey(record.Key(), nil))
+	}
+	return nil
+}
+
+func (v *insertSessionMock) Insert(_ context.Context, record Record, _ ...InsertOption) error {
+	v.inserted = append(v.inserted, record)
+	return nil
+}
+
+func (v *insertSessionMock) InsertMulti(_ context.Context, records []Record, _ ...InsertOption) error {
+	v.inserted = append(v.inserted, records...)
+	return nil
+}
+
+// sequenceIDGenerator returns a generator that assigns given IDs one by one
+func sequenceIDGenerator(ids ...string) IDGenerator {
+	i := 0
+	return func(ctx context.Context, record Record) error {
+		if i >= len(ids) {
+			return errors.New("no more IDs")
+		}
+		record.Key().ID = ids[i]
+		i++
+		return nil
+	}
+}
+
+func TestWithRandomID(t *testing.T) {
+	t.Run("nil_generator", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithRandomID(nil, 1)
+		})
+	})
+	t.Run("zero_attempts", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithRandomID(sequenceIDGenerator("id1"), 0)
+		})
+	})
+	t.Run("options", func(t *testing.T) {
+		options := NewInsertOptions(WithRandomID(sequenceIDGenerator("id1"), 3))
+		assert.NotNil(t, options.IDGenerator())
+		assert.Equal(t, 3, InsertAttempts(options))
+		assert.Equal(t, 1, InsertAttempts(NewInsertOptions()))
+	})
+}
+
+func TestNewRandomIDInserter(t *testing.T) {
+	ctx := context.Background()
+	newRecord := func() Record {
+		return NewRecordWithData(&Key{collection: "c1"}, new(map[string]any))
+	}
+
+	t.Run("nil_session", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewRandomIDInserter(nil)
+		})
+	})
+
+	t.Run("without_generator", func(t *testing.T) {
+		session := &insertSessionMock{}
+		record := NewRecordWithData(NewKeyWithID("c1", "id0"), new(map[string]any))
+		assert.Nil(t, NewRandomIDInserter(session).Insert(ctx, record))
+		assert.Equal(t, []Record{record}, session.inserted)
+	})
+
+	t.Run("insert_skips_existing_ids", func(t *testing.T) {
+		session := &insertSessionMock{existing: map[string]bool{"c1/id1": true}}
+		record := newRecord()
+		err := NewRandomIDInserter(session).Insert(ctx, record, WithRandomID(sequenceIDGenerator("id1", "id2"), 2))
+		assert.Nil(t, err)
+		assert.Equal(t, "id2", record.Key().ID)
+		assert.Equal(t, []Record{record}, session.inserted)
+	})
+
+	t.Run("insert_exceeds_attempts", func(t *testing.T) {
+		session := &insertSessionMock{existing: map[string]bool{"c1/id1": true, "c1/id2": true}}
+		record := newRecord()
+		err := NewRandomIDInserter(session).Insert(ctx, record, WithRandomID(sequenceIDGenerator("id1", "id2", "id3"), 2))
+		assert.ErrorIs(t, err, ErrExceedsMaxNumberOfAttempts)
+		assert.Nil(t, record.Key().ID)
+		assert.Empty(t, session.inserted)
+	})
+
+	t.Run("insert_multi_avoids_collisions_within_batch", func(t *testing.T) {
+		session := &insertSessionMock{existing: map[string]bool{"c1/id1": true}}
+		records := []Record{newRecord(), newRecord()}
+		err := NewRandomIDInserter(session).InsertMulti(ctx, records, WithRandomID(sequenceIDGenerator("id1", "id2", "id2", "id3"), 2))
+		assert.Nil(t, err)
+		assert.Equal(t, "id2", records[0].Key().ID)
+		assert.Equal(t, "id3", records[1].Key().ID)
+		assert.Equal(t, records, session.inserted)
+	})
+
+	t.Run("insert_multi_fails", func(t *testing.T) {
+		session := &insertSessionMock{}
+		records := []Record{newRecord(), newRecord()}
+		err := NewRandomIDInserter(session).InsertMulti(ctx, records, WithRandomID(sequenceIDGenerator("id1"), 1))
+		assert.NotNil(t, err)
+		assert.Nil(t, records[0].Key().ID)
+		assert.Empty(t, session.inserted)
+	})
+
+	t.Run("insert_multi_resets_id_of_failed_record", func(t *testing.T) {
+		session := &insertSessionMock{}
+		records := []Record{newRecord(), newRecord()}
+		generator := func(ctx context.Context, record Record) error {
+			if record.Key() == records[1].Key() {
+				record.Key().ID = "partial"
+				return errors.New("generator failed")
+			}
+			record.Key().ID = "id1"
+			return nil
+		}
+		err := NewRandomIDInserter(session).InsertMulti(ctx, records, WithRandomID(generator, 1))
+		assert.ErrorContains(t, err, "record #1")
+		assert.Nil(t, records[0].Key().ID)
+		assert.Nil(t, records[1].Key().ID)
+	})
+
+	t.Run("read_error", func(t *testing.T) {
+		session := &insertSessionMock{readErr: errors.New("db is down")}
+		record := newRecord()
+		err := NewRandomIDInserter(session).Insert(ctx, record, WithRandomID(sequenceIDGenerator("id1", "id2"), 2))
+		assert.ErrorContains(t, err, "db is down")
+		assert.NotErrorIs(t, err, ErrExceedsMaxNumberOfAttempts)
+		assert.Nil(t, record.Key().ID)
+		assert.Empty(t, session.inserted)
+	})
+}
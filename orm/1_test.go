@@ -38,6 +38,10 @@ func (v UserCollection) Query() dal.QueryBuilder {
 	return dal.From(Users.CollectionRef().Name)
 }
 
+func (v UserCollection) Update(key *dal.Key, updates ...dal.Update) (RecordUpdate, error) {
+	return NewRecordUpdate(v, key, updates...)
+}
+
 func (v UserCollection) IDKind() reflect.Kind {
 	return reflect.String
 }
@@ -0,0 +1,406 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// ErrUnsupportedDDL indicates a collection can't be represented by SQL DDL, e.g. a default value of an unsupported type
+var ErrUnsupportedDDL = errors.New("unsupported DDL")
+
+// columnType is a category of SQL column types a Go type is mapped to
+type columnType int
+
+const (
+	columnString columnType = iota
+	columnKeyString
+	columnBool
+	columnSmallInt
+	columnInt
+	columnBigInt
+	columnUnsignedBigInt
+	columnFloat
+	columnDouble
+	columnTime
+	columnBytes
+	columnJSON
+)
+
+// SQLDialect defines SQL syntax & column types of a database, see PostgreSQL, MySQL, SQLite & SQLServer
+type SQLDialect struct {
+	name        string
+	quoteLeft   string
+	quoteRight  string
+	types       map[columnType]string
+	noDefaults  map[columnType]bool // types of columns that can't have literal default values
+	boolLiteral func(v bool) string
+	timeSuffix  string // appended to time literals in UTC, e.g. a time zone offset
+}
+
+// Name returns a name of a database
+func (d SQLDialect) Name() string {
+	return d.name
+}
+
+func (d SQLDialect) quote(name string) string {
+	return d.quoteLeft + strings.ReplaceAll(name, d.quoteRight, d.quoteRight+d.quoteRight) + d.quoteRight
+}
+
+func upperBool(v bool) string {
+	return strings.ToUpper(strconv.FormatBool(v))
+}
+
+func bitBool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+var (
+	// PostgreSQL dialect
+	PostgreSQL = SQLDialect{
+		name:      "PostgreSQL",
+		quoteLeft: `"`, quoteRight: `"`,
+		types: map[columnType]string{
+			columnString:         "TEXT",
+			columnKeyString:      "TEXT",
+			columnBool:           "BOOLEAN",
+			columnSmallInt:       "SMALLINT",
+			columnInt:            "INTEGER",
+			columnBigInt:         "BIGINT",
+			columnUnsignedBigInt: "NUMERIC(20)",
+			columnFloat:          "REAL",
+			columnDouble:         "DOUBLE PRECISION",
+			columnTime:           "TIMESTAMP WITH TIME ZONE",
+			columnBytes:          "BYTEA",
+			columnJSON:           "JSONB",
+		},
+		timeSuffix:  "+00:00",
+		boolLiteral: upperBool,
+	}
+
+	// MySQL dialect
+	MySQL = SQLDialect{
+		name:      "MySQL",
+		quoteLeft: "`", quoteRight: "`",
+		types: map[columnType]string{
+			columnString:         "VARCHAR(255)",
+			columnKeyString:      "VARCHAR(255)",
+			columnBool:           "BOOLEAN",
+			columnSmallInt:       "SMALLINT",
+			columnInt:            "INT",
+			columnBigInt:         "BIGINT",
+			columnUnsignedBigInt: "BIGINT UNSIGNED",
+			columnFloat:          "FLOAT",
+			columnDouble:         "DOUBLE",
+			columnTime:           "DATETIME(6)",
+			columnBytes:          "BLOB",
+			columnJSON:           "JSON",
+		},
+		noDefaults:  map[columnType]bool{columnBytes: true, columnJSON: true},
+		boolLiteral: upperBool,
+	}
+
+	// SQLite dialect
+	SQLite = SQLDialect{
+		name:      "SQLite",
+		quoteLeft: `"`, quoteRight: `"`,
+		types: map[columnType]string{
+			columnString:         "TEXT",
+			columnKeyString:      "TEXT",
+			columnBool:           "INTEGER",
+			columnSmallInt:       "INTEGER",
+			columnInt:            "INTEGER",
+			columnBigInt:         "INTEGER",
+			columnUnsignedBigInt: "NUMERIC(20)",
+			columnFloat:          "REAL",
+			columnDouble:         "REAL",
+			columnTime:           "DATETIME",
+			columnBytes:          "BLOB",
+			columnJSON:           "TEXT",
+		},
+		boolLiteral: bitBool,
+	}
+
+	// SQLServer dialect
+	SQLServer = SQLDialect{
+		name:      "SQL Server",
+		quoteLeft: "[", quoteRight: "]",
+		types: map[columnType]string{
+			columnString:         "NVARCHAR(MAX)",
+			columnKeyString:      "NVARCHAR(255)",
+			columnBool:           "BIT",
+			columnSmallInt:       "SMALLINT",
+			columnInt:            "INT",
+			columnBigInt:         "BIGINT",
+			columnUnsignedBigInt: "NUMERIC(20)",
+			columnFloat:          "REAL",
+			columnDouble:         "FLOAT",
+			columnTime:           "DATETIMEOFFSET",
+			columnBytes:          "VARBINARY(MAX)",
+			columnJSON:           "NVARCHAR(MAX)",
+		},
+		timeSuffix:  "+00:00",
+		boolLiteral: bitBool,
+	}
+)
+
+// DDLOption configures DDL generation
+type DDLOption func(options *ddlOptions)
+
+type ddlOptions struct {
+	idColumn       string
+	parentIDColumn func(parentCollection string) string
+}
+
+// IDColumn sets a name of a primary key column with IDs of records, defaults to "ID"
+func IDColumn(name string) DDLOption {
+	if name == "" {
+		panic("name is a required parameter, got empty string")
+	}
+	return func(options *ddlOptions) {
+		options.idColumn = name
+	}
+}
+
+// ParentIDColumn sets names of columns with IDs of parent records of subcollections,
+// by default it is a name of a parent collection with an "ID" suffix, e.g. "usersID"
+func ParentIDColumn(name func(parentCollection string) string) DDLOption {
+	if name == nil {
+		panic("name is a required parameter, got nil")
+	}
+	return func(options *ddlOptions) {
+		options.parentIDColumn = name
+	}
+}
+
+func newDDLOptions(options []DDLOption) ddlOptions {
+	o := ddlOptions{
+		idColumn: "ID",
+		parentIDColumn: func(parentCollection string) string {
+			return parentCollection + "ID"
+		},
+	}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// CreateTable generates a `CREATE TABLE` statement for a collection:
+//   - a column is created for each field of Fields() with a type mapped from Type(),
+//     NOT NULL for required fields & DEFAULT for fields with default values;
+//   - a primary key column has a type mapped from IDKind() if the collection has such method, otherwise it is a string;
+//   - for a subcollection (CollectionRef().Parent is not nil) columns with IDs of parent records
+//     are added & included into the primary key, their types are mapped from types of IDs of the parent key.
+//
+// Types of fields that are not mapped to SQL types directly (e.g. slices, maps & structs) are stored as JSON.
+// Default values are written as literals, ErrUnsupportedDDL is returned for columns that can't have them
+// (e.g. BLOB & JSON columns of MySQL). Default times are written in UTC.
+func CreateTable(dialect SQLDialect, collection Collection, options ...DDLOption) (string, error) {
+	if collection == nil {
+		panic("collection is a required parameter, got nil")
+	}
+	o := newDDLOptions(options)
+	ref := collection.CollectionRef()
+	var columns, primaryKey []string
+	for _, parent := range parentKeys(ref.Parent) {
+		name := dialect.quote(o.parentIDColumn(parent.Collection()))
+		columns = append(columns, name+" "+dialect.types[keyColumnType(idKindOf(parent.ID))]+" NOT NULL")
+		primaryKey = append(primaryKey, name)
+	}
+	idKind := reflect.String
+	if withIDKind, ok := collection.(interface{ IDKind() reflect.Kind }); ok {
+		idKind = withIDKind.IDKind()
+	}
+	idColumn := dialect.quote(o.idColumn)
+	columns = append(columns, idColumn+" "+dialect.types[keyColumnType(idKind)]+" NOT NULL")
+	primaryKey = append(primaryKey, idColumn)
+	for _, field := range collection.Fields() {
+		column, err := columnDefinition(dialect, field)
+		if err != nil {
+			return "", fmt.Errorf("%w: collection %s: %w", ErrUnsupportedDDL, ref.Name, err)
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, "PRIMARY KEY ("+strings.Join(primaryKey, ", ")+")")
+	return fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", dialect.quote(ref.Name), strings.Join(columns, ",\n\t")), nil
+}
+
+// AddColumns generates an `ALTER TABLE ... ADD COLUMN` statement for each of named fields of a collection,
+// a statement per column as some databases (e.g. SQLite) do not support adding multiple columns at once.
+// Note that databases reject adding a required column without a default value to a table with records.
+func AddColumns(dialect SQLDialect, collection Collection, fieldNames ...string) ([]string, error) {
+	if collection == nil {
+		panic("collection is a required parameter, got nil")
+	}
+	table := collection.CollectionRef().Name
+	fields := make(map[string]Field)
+	for _, field := range collection.Fields() {
+		fields[field.Name()] = field
+	}
+	addColumn := "ADD COLUMN"
+	if dialect.name == SQLServer.name {
+		addColumn = "ADD"
+	}
+	statements := make([]string, len(fieldNames))
+	for i, name := range fieldNames {
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: collection %s has no field %s", ErrUnsupportedDDL, table, name)
+		}
+		column, err := columnDefinition(dialect, field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: collection %s: %w", ErrUnsupportedDDL, table, err)
+		}
+		statements[i] = fmt.Sprintf("ALTER TABLE %s %s %s", dialect.quote(table), addColumn, column)
+	}
+	return statements, nil
+}
+
+// parentKeys returns a key & its parents starting from the root
+func parentKeys(key *dal.Key) (keys []*dal.Key) {
+	for ; key != nil; key = key.Parent() {
+		keys = append([]*dal.Key{key}, keys...)
+	}
+	return keys
+}
+
+func idKindOf(id any) reflect.Kind {
+	if id == nil {
+		return reflect.String
+	}
+	return reflect.TypeOf(id).Kind()
+}
+
+func keyColumnType(kind reflect.Kind) columnType {
+	if kind == reflect.String {
+		return columnKeyString
+	}
+	return kindColumnType(kind)
+}
+
+func kindColumnType(kind reflect.Kind) columnType {
+	switch kind {
+	case reflect.String:
+		return columnString
+	case reflect.Bool:
+		return columnBool
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return columnSmallInt
+	case reflect.Int32, reflect.Uint16:
+		return columnInt
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return columnBigInt
+	case reflect.Uint, reflect.Uint64:
+		return columnUnsignedBigInt
+	case reflect.Float32:
+		return columnFloat
+	case reflect.Float64:
+		return columnDouble
+	default:
+		return columnJSON
+	}
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+func reflectColumnType(t reflect.Type) columnType {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return columnTime
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return columnBytes
+	default:
+		return kindColumnType(t.Kind())
+	}
+}
+
+// typedField is implemented by FieldDefinition, other implementations of Field are mapped by Type() names
+type typedField interface {
+	Field
+	reflectType() reflect.Type
+	defaultValue() (any, bool)
+}
+
+func fieldColumnType(field Field) columnType {
+	if f, ok := field.(typedField); ok {
+		return reflectColumnType(f.reflectType())
+	}
+	switch t := strings.TrimPrefix(field.Type(), "*"); t {
+	case "time.Time":
+		return columnTime
+	case "[]byte", "[]uint8":
+		return columnBytes
+	default:
+		for kind := reflect.Bool; kind <= reflect.UnsafePointer; kind++ {
+			if kind.String() == t {
+				return kindColumnType(kind)
+			}
+		}
+		return columnJSON
+	}
+}
+
+func columnDefinition(dialect SQLDialect, field Field) (string, error) {
+	colType := fieldColumnType(field)
+	column := dialect.quote(field.Name()) + " " + dialect.types[colType]
+	if field.IsRequired() {
+		column += " NOT NULL"
+	}
+	if f, ok := field.(typedField); ok {
+		if value, hasDefault := f.defaultValue(); hasDefault {
+			literal, err := defaultLiteral(dialect, value)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %w", field.Name(), err)
+			}
+			if dialect.noDefaults[colType] && literal != "NULL" {
+				return "", fmt.Errorf("field %s: %s does not support default values of %s columns",
+					field.Name(), dialect.name, dialect.types[colType])
+			}
+			column += " DEFAULT " + literal
+		}
+	}
+	return column, nil
+}
+
+func defaultLiteral(dialect SQLDialect, value any) (string, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "NULL", nil
+		}
+		v = v.Elem()
+	}
+	if v.IsValid() && v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		return "'" + t.UTC().Format("2006-01-02 15:04:05.999999") + dialect.timeSuffix + "'", nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return "'" + strings.ReplaceAll(v.String(), "'", "''") + "'", nil
+	case reflect.Bool:
+		return dialect.boolLiteral(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("default value of type %T", value)
+	}
+}
@@ -0,0 +1,253 @@
+package orm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOrderStatus string
+
+type testOrderCollection struct {
+	Status    FieldDefinition[testOrderStatus]
+	Total     FieldDefinition[float64]
+	Paid      FieldDefinition[bool]
+	Items     FieldDefinition[int16]
+	Note      FieldDefinition[*string]
+	CreatedAt FieldDefinition[time.Time]
+	Tags      FieldDefinition[[]string]
+	Signature FieldDefinition[[]byte]
+}
+
+func (v testOrderCollection) CollectionRef() dal.CollectionRef {
+	return dal.CollectionRef{Name: "orders", Parent: dal.NewKeyWithID("users", "")}
+}
+
+func (v testOrderCollection) Fields() []Field {
+	return []Field{v.Status, v.Total, v.Paid, v.Items, v.Note, v.CreatedAt, v.Tags, v.Signature}
+}
+
+func (v testOrderCollection) IDKind() reflect.Kind {
+	return reflect.Int64
+}
+
+var testOrders = testOrderCollection{
+	Status:    NewField("status", Required[testOrderStatus](), Default[testOrderStatus]("new")),
+	Total:     NewField("total", Default(0.5)),
+	Paid:      NewField("paid", Required[bool](), Default(false)),
+	Items:     NewField("items", Default[int16](1)),
+	Note:      NewField[*string]("note"),
+	CreatedAt: NewField("createdAt", Required[time.Time]()),
+	Tags:      NewField[[]string]("tags"),
+	Signature: NewField[[]byte]("signature"),
+}
+
+// testCustomField is an implementation of Field other than FieldDefinition
+type testCustomField string
+
+func (f testCustomField) Name() string     { return "custom" }
+func (f testCustomField) Type() string     { return string(f) }
+func (f testCustomField) IsRequired() bool { return false }
+func (f testCustomField) CompareTo(operator dal.Operator, v dal.Expression) dal.Condition {
+	return dal.NewComparison(dal.Field("custom"), operator, v)
+}
+
+type testFieldsCollection []Field
+
+func (v testFieldsCollection) CollectionRef() dal.CollectionRef {
+	return dal.CollectionRef{Name: "things"}
+}
+
+func (v testFieldsCollection) Fields() []Field {
+	return v
+}
+
+func TestCreateTable(t *testing.T) {
+	assert.Panics(t, func() {
+		_, _ = CreateTable(PostgreSQL, nil)
+	})
+	for _, tt := range []struct {
+		dialect  SQLDialect
+		expected string
+	}{
+		{PostgreSQL, `CREATE TABLE "orders" (
+	"usersID" TEXT NOT NULL,
+	"ID" BIGINT NOT NULL,
+	"status" TEXT NOT NULL DEFAULT 'new',
+	"total" DOUBLE PRECISION DEFAULT 0.5,
+	"paid" BOOLEAN NOT NULL DEFAULT FALSE,
+	"items" SMALLINT DEFAULT 1,
+	"note" TEXT,
+	"createdAt" TIMESTAMP WITH TIME ZONE NOT NULL,
+	"tags" JSONB,
+	"signature" BYTEA,
+	PRIMARY KEY ("usersID", "ID")
+)`},
+		{MySQL, "CREATE TABLE `orders` (\n" +
+			"\t`usersID` VARCHAR(255) NOT NULL,\n" +
+			"\t`ID` BIGINT NOT NULL,\n" +
+			"\t`status` VARCHAR(255) NOT NULL DEFAULT 'new',\n" +
+			"\t`total` DOUBLE DEFAULT 0.5,\n" +
+			"\t`paid` BOOLEAN NOT NULL DEFAULT FALSE,\n" +
+			"\t`items` SMALLINT DEFAULT 1,\n" +
+			"\t`note` VARCHAR(255),\n" +
+			"\t`createdAt` DATETIME(6) NOT NULL,\n" +
+			"\t`tags` JSON,\n" +
+			"\t`signature` BLOB,\n" +
+			"\tPRIMARY KEY (`usersID`, `ID`)\n" +
+			")"},
+		{SQLite, `CREATE TABLE "orders" (
+	"usersID" TEXT NOT NULL,
+	"ID" INTEGER NOT NULL,
+	"status" TEXT NOT NULL DEFAULT 'new',
+	"total" REAL DEFAULT 0.5,
+	"paid" INTEGER NOT NULL DEFAULT 0,
+	"items" INTEGER DEFAULT 1,
+	"note" TEXT,
+	"createdAt" DATETIME NOT NULL,
+	"tags" TEXT,
+	"signature" BLOB,
+	PRIMARY KEY ("usersID", "ID")
+)`},
+		{SQLServer, `CREATE TABLE [orders] (
+	[usersID] NVARCHAR(255) NOT NULL,
+	[ID] BIGINT NOT NULL,
+	[status] NVARCHAR(MAX) NOT NULL DEFAULT 'new',
+	[total] FLOAT DEFAULT 0.5,
+	[paid] BIT NOT NULL DEFAULT 0,
+	[items] SMALLINT DEFAULT 1,
+	[note] NVARCHAR(MAX),
+	[createdAt] DATETIMEOFFSET NOT NULL,
+	[tags] NVARCHAR(MAX),
+	[signature] VARBINARY(MAX),
+	PRIMARY KEY ([usersID], [ID])
+)`},
+	} {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			sql, err := CreateTable(tt.dialect, testOrders)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, sql)
+		})
+	}
+}
+
+func TestCreateTable_Options(t *testing.T) {
+	sql, err := CreateTable(PostgreSQL, testOrders, IDColumn("id"), ParentIDColumn(func(parent string) string {
+		return parent + "_id"
+	}))
+	assert.Nil(t, err)
+	assert.Contains(t, sql, `"users_id" TEXT NOT NULL,`)
+	assert.Contains(t, sql, `PRIMARY KEY ("users_id", "id")`)
+	assert.Panics(t, func() {
+		IDColumn("")
+	})
+	assert.Panics(t, func() {
+		ParentIDColumn(nil)
+	})
+}
+
+func TestCreateTable_NotTypedFields(t *testing.T) {
+	sql, err := CreateTable(SQLite, Users)
+	assert.Nil(t, err)
+	assert.Equal(t, `CREATE TABLE "Users" (
+	"ID" TEXT NOT NULL,
+	"Email" TEXT NOT NULL,
+	PRIMARY KEY ("ID")
+)`, sql)
+
+	for fieldType, expected := range map[string]string{
+		"int32":     `"custom" INTEGER`,
+		"*float32":  `"custom" REAL`,
+		"time.Time": `"custom" TIMESTAMP WITH TIME ZONE`,
+		"[]byte":    `"custom" BYTEA`,
+		"struct":    `"custom" JSONB`,
+		"pkg.Type":  `"custom" JSONB`,
+	} {
+		sql, err = CreateTable(PostgreSQL, testFieldsCollection{testCustomField(fieldType)})
+		assert.Nil(t, err)
+		assert.Contains(t, sql, expected, fieldType)
+	}
+
+	_, err = CreateTable(PostgreSQL, testFieldsCollection{NewField("tags", Default([]string{"a"}))})
+	assert.ErrorIs(t, err, ErrUnsupportedDDL)
+}
+
+func TestAddColumns(t *testing.T) {
+	statements, err := AddColumns(PostgreSQL, testOrders, "note", "status")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`ALTER TABLE "orders" ADD COLUMN "note" TEXT`,
+		`ALTER TABLE "orders" ADD COLUMN "status" TEXT NOT NULL DEFAULT 'new'`,
+	}, statements)
+
+	statements, err = AddColumns(SQLServer, testOrders, "paid")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`ALTER TABLE [orders] ADD [paid] BIT NOT NULL DEFAULT 0`}, statements)
+
+	_, err = AddColumns(MySQL, testOrders, "unknown")
+	assert.ErrorIs(t, err, ErrUnsupportedDDL)
+	assert.Panics(t, func() {
+		_, _ = AddColumns(MySQL, nil)
+	})
+}
+
+func Test_defaultLiteral(t *testing.T) {
+	s := "it's"
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	for _, tt := range []struct {
+		dialect  SQLDialect
+		value    any
+		expected string
+	}{
+		{PostgreSQL, "it's", "'it''s'"},
+		{PostgreSQL, &s, "'it''s'"},
+		{PostgreSQL, (*string)(nil), "NULL"},
+		{PostgreSQL, uint8(7), "7"},
+		{PostgreSQL, -3, "-3"},
+		{PostgreSQL, true, "TRUE"},
+		{PostgreSQL, createdAt, "'2024-01-02 02:04:05+00:00'"},
+		{PostgreSQL, &createdAt, "'2024-01-02 02:04:05+00:00'"},
+		{PostgreSQL, (*time.Time)(nil), "NULL"},
+		{SQLServer, createdAt, "'2024-01-02 02:04:05+00:00'"},
+		{MySQL, createdAt, "'2024-01-02 02:04:05'"},
+		{SQLite, &createdAt, "'2024-01-02 02:04:05'"},
+	} {
+		literal, err := defaultLiteral(tt.dialect, tt.value)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, literal)
+	}
+}
+
+func TestCreateTable_UnsignedBigInt(t *testing.T) {
+	for _, tt := range []struct {
+		dialect  SQLDialect
+		expected string
+	}{
+		{PostgreSQL, `"counter" NUMERIC(20) DEFAULT 18446744073709551615`},
+		{MySQL, "`counter` BIGINT UNSIGNED DEFAULT 18446744073709551615"},
+		{SQLite, `"counter" NUMERIC(20) DEFAULT 18446744073709551615`},
+		{SQLServer, `[counter] NUMERIC(20) DEFAULT 18446744073709551615`},
+	} {
+		sql, err := CreateTable(tt.dialect, testFieldsCollection{NewField("counter", Default(uint64(math.MaxUint64)))})
+		assert.Nil(t, err)
+		assert.Contains(t, sql, tt.expected, tt.dialect.Name())
+	}
+}
+
+func TestCreateTable_NotSupportedDefaults(t *testing.T) {
+	meta := NewFieldWithType("meta", "any", Default[any]("n/a")) // stored as JSON
+	_, err := CreateTable(MySQL, testFieldsCollection{meta})
+	assert.ErrorIs(t, err, ErrUnsupportedDDL)
+	assert.ErrorContains(t, err, "MySQL does not support default values of JSON columns")
+	sql, err := CreateTable(PostgreSQL, testFieldsCollection{meta})
+	assert.Nil(t, err)
+	assert.Contains(t, sql, `"meta" JSONB DEFAULT 'n/a'`)
+
+	sql, err = CreateTable(MySQL, testFieldsCollection{NewField("signature", Default[*[]byte](nil))})
+	assert.Nil(t, err)
+	assert.Contains(t, sql, "`signature` BLOB DEFAULT NULL")
+}
@@ -10,6 +10,7 @@ type FieldDefinition[T any] struct {
 	name       string
 	valueType  string
 	isRequired bool
+	hasDefault bool
 	defaultVal T
 }
 
@@ -17,6 +18,11 @@ func (v FieldDefinition[T]) DefaultValue() T {
 	return v.defaultVal
 }
 
+// HasDefaultValue checks if a default value has been set by the Default() option
+func (v FieldDefinition[T]) HasDefaultValue() bool {
+	return v.hasDefault
+}
+
 func (v FieldDefinition[T]) Name() string {
 	return v.name
 }
@@ -29,6 +35,15 @@ func (v FieldDefinition[T]) Type() string {
 	return v.valueType
 }
 
+// reflectType & defaultValue are used to generate DDL, see CreateTable()
+func (v FieldDefinition[T]) reflectType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (v FieldDefinition[T]) defaultValue() (any, bool) {
+	return v.defaultVal, v.hasDefault
+}
+
 func (v FieldDefinition[T]) CompareTo(operator dal.Operator, expression dal.Expression) dal.Condition {
 	return dal.NewComparison(dal.FieldRef{Name: v.name}, operator, expression)
 }
@@ -37,6 +52,71 @@ func (v FieldDefinition[T]) EqualTo(value T) dal.Condition {
 	return v.CompareTo(dal.Equal, dal.Constant{Value: value})
 }
 
+func (v FieldDefinition[T]) NotEqualTo(value T) dal.Condition {
+	return v.CompareTo(dal.NotEqual, dal.Constant{Value: value})
+}
+
+func (v FieldDefinition[T]) GreaterThan(value T) dal.Condition {
+	return v.CompareTo(dal.GreaterThen, dal.Constant{Value: value})
+}
+
+func (v FieldDefinition[T]) GreaterOrEqual(value T) dal.Condition {
+	return v.CompareTo(dal.GreaterOrEqual, dal.Constant{Value: value})
+}
+
+func (v FieldDefinition[T]) LessThan(value T) dal.Condition {
+	return v.CompareTo(dal.LessThen, dal.Constant{Value: value})
+}
+
+func (v FieldDefinition[T]) LessOrEqual(value T) dal.Condition {
+	return v.CompareTo(dal.LessOrEqual, dal.Constant{Value: value})
+}
+
+// In creates a condition that matches any of the values
+func (v FieldDefinition[T]) In(values ...T) dal.Condition {
+	return v.CompareTo(dal.In, dal.Constant{Value: values})
+}
+
+// Between creates a condition that matches values in the inclusive range
+func (v FieldDefinition[T]) Between(from, to T) dal.Condition {
+	return dal.NewGroupCondition(dal.And, v.GreaterOrEqual(from), v.LessOrEqual(to))
+}
+
+// IsNull creates a condition that matches nil values
+func (v FieldDefinition[T]) IsNull() dal.Condition {
+	return v.CompareTo(dal.Equal, dal.Constant{Value: nil})
+}
+
+// Set creates an update that sets the field to a value
+func (v FieldDefinition[T]) Set(value T) dal.Update {
+	return dal.Update{Field: v.name, Value: value}
+}
+
+// Delete creates an update that deletes the field, see NewRecordUpdate() for validation of required fields
+func (v FieldDefinition[T]) Delete() dal.Update {
+	return dal.Update{Field: v.name, Value: dal.DeleteField}
+}
+
+func (v FieldDefinition[T]) Asc() dal.OrderExpression {
+	return dal.AscendingField(v.name)
+}
+
+func (v FieldDefinition[T]) Desc() dal.OrderExpression {
+	return dal.DescendingField(v.name)
+}
+
+func (v FieldDefinition[T]) Count(alias string) dal.Column {
+	return dal.CountAs(dal.Field(v.name), alias)
+}
+
+func (v FieldDefinition[T]) Min(alias string) dal.Column {
+	return dal.MinAs(dal.Field(v.name), alias)
+}
+
+func (v FieldDefinition[T]) Max(alias string) dal.Column {
+	return dal.MaxAs(dal.Field(v.name), alias)
+}
+
 func NewField[T any](name string, options ...FieldOption[T]) FieldDefinition[T] {
 	var v T
 	return NewFieldWithType(name, reflect.TypeOf(v).String(), options...)
@@ -47,9 +127,9 @@ func NewFieldWithType[T any](name, valueType string, options ...FieldOption[T])
 		panic("name cannot be empty")
 	}
 	var v T
-	kind := reflect.TypeOf(v).Kind()
-	if kindName := kind.String(); kindName != "any" && kindName != valueType {
-		panic("valueType must be " + kind.String())
+	// valueType can be a kind (e.g. "struct") or a full type name (e.g. "time.Time") as given by NewField()
+	if t := reflect.TypeOf(v); t != nil && t.Kind().String() != valueType && t.String() != valueType {
+		panic("valueType must be " + t.Kind().String() + " or " + t.String())
 	}
 	f := FieldDefinition[T]{
 		name:      name,
@@ -1,8 +1,10 @@
 package orm
 
 import (
+	"github.com/dal-go/dalgo/dal"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewField(t *testing.T) {
@@ -71,3 +73,59 @@ func TestNewFieldWithType(t *testing.T) {
 	}()
 	NewFieldWithType[string]("name", "ABC")
 }
+
+func TestNewField_NotBasicType(t *testing.T) {
+	type status string
+	assert.Equal(t, "orm.status", NewField[status]("status").Type())
+	assert.Equal(t, "[]string", NewField[[]string]("tags").Type())
+	assert.Equal(t, "time.Time", NewField[time.Time]("createdAt").Type())
+	assert.Equal(t, "struct", NewFieldWithType[time.Time]("createdAt", "struct").Type())
+	assert.Equal(t, "any", NewFieldWithType[any]("value", "any").Type())
+}
+
+func TestFieldDefinition_Conditions(t *testing.T) {
+	age := NewField[int]("age")
+	field := dal.FieldRef{Name: "age"}
+	for _, tt := range []struct {
+		condition dal.Condition
+		operator  dal.Operator
+		value     any
+	}{
+		{age.EqualTo(1), dal.Equal, 1},
+		{age.NotEqualTo(1), dal.NotEqual, 1},
+		{age.GreaterThan(1), dal.GreaterThen, 1},
+		{age.GreaterOrEqual(1), dal.GreaterOrEqual, 1},
+		{age.LessThan(1), dal.LessThen, 1},
+		{age.LessOrEqual(1), dal.LessOrEqual, 1},
+		{age.In(1, 2), dal.In, []int{1, 2}},
+		{age.IsNull(), dal.Equal, nil},
+	} {
+		assert.Equal(t, dal.NewComparison(field, tt.operator, dal.Constant{Value: tt.value}), tt.condition)
+	}
+
+	between := age.Between(18, 65).(dal.GroupCondition)
+	assert.Equal(t, dal.Operator(dal.And), between.Operator())
+	assert.Equal(t, []dal.Condition{age.GreaterOrEqual(18), age.LessOrEqual(65)}, between.Conditions())
+}
+
+func TestFieldDefinition_OrderAndAggregates(t *testing.T) {
+	age := NewField[int]("age")
+	assert.Equal(t, "age", age.Asc().String())
+	assert.False(t, age.Asc().Descending())
+	assert.Equal(t, "age DESC", age.Desc().String())
+	assert.True(t, age.Desc().Descending())
+	assert.Equal(t, "COUNT(age) AS n", age.Count("n").String())
+	assert.Equal(t, "MIN(age) AS youngest", age.Min("youngest").String())
+	assert.Equal(t, "MAX(age) AS oldest", age.Max("oldest").String())
+
+	query := Users.Query().
+		Where(Users.Field.Email.In("a@example.com", "b@example.com")).
+		OrderBy(Users.Field.Email.Desc()).
+		SelectKeysOnly(Users.IDKind())
+	assert.Equal(t, "SELECT * FROM [Users] WHERE Email In [\"a@example.com\",\"b@example.com\"]\nORDER BY Email DESC", query.String())
+}
+
+func TestFieldDefinition_HasDefaultValue(t *testing.T) {
+	assert.False(t, NewField[int]("count").HasDefaultValue())
+	assert.True(t, NewField("count", Default(0)).HasDefaultValue())
+}
@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"unicode/utf8"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// Functions in this file are applicable to fields of specific types only,
+// so type mismatches are caught at compile time, e.g. orm.Sum(Users.Field.Email, "x") does not compile.
+
+// Number is a constraint for numeric field types
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// Sum creates an aggregate column with sum of values of a numeric field
+func Sum[T Number](field FieldDefinition[T], alias string) dal.Column {
+	return dal.SumAs(dal.Field(field.Name()), alias)
+}
+
+// Average creates an aggregate column with average of values of a numeric field
+func Average[T Number](field FieldDefinition[T], alias string) dal.Column {
+	return dal.AverageAs(dal.Field(field.Name()), alias)
+}
+
+// StartsWith creates a condition that matches strings with a prefix.
+// It is a range condition `>= prefix AND < prefix + utf8.MaxRune`, so it is supported by databases without LIKE.
+func StartsWith[T ~string](field FieldDefinition[T], prefix T) dal.Condition {
+	return dal.NewGroupCondition(dal.And, field.GreaterOrEqual(prefix), field.LessThan(prefix+T(string(utf8.MaxRune))))
+}
+
+// Contains creates a condition that matches slices that contain a value
+func Contains[S ~[]E, E any](field FieldDefinition[S], value E) dal.Condition {
+	return field.CompareTo(dal.ArrayContains, dal.Constant{Value: value})
+}
+
+// Increment creates an update that increments a numeric field by n, see dal.Increment()
+func Increment[T Number](field FieldDefinition[T], n int) dal.Update {
+	return dal.Update{Field: field.Name(), Value: dal.Increment(n)}
+}
+
+// ArrayUnion creates an update that adds elements that are not present in a slice field, see dal.ArrayUnion()
+func ArrayUnion[S ~[]E, E any](field FieldDefinition[S], elements ...E) dal.Update {
+	values := make([]any, len(elements))
+	for i, e := range elements {
+		values[i] = e
+	}
+	return dal.Update{Field: field.Name(), Value: dal.ArrayUnion(values...)}
+}
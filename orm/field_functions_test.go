@@ -0,0 +1,33 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSum(t *testing.T) {
+	age := NewField[int]("age")
+	assert.Equal(t, "SUM(age) AS total", Sum(age, "total").String())
+	assert.Equal(t, "AVG(age) AS avg", Average(age, "avg").String())
+}
+
+func TestStartsWith(t *testing.T) {
+	type name string
+	condition := StartsWith(NewField[name]("name"), "Jo")
+	group, ok := condition.(dal.GroupCondition)
+	assert.True(t, ok)
+	assert.Equal(t, dal.Operator(dal.And), group.Operator())
+	assert.Equal(t, []dal.Condition{
+		dal.NewComparison(dal.FieldRef{Name: "name"}, dal.GreaterOrEqual, dal.Constant{Value: name("Jo")}),
+		dal.NewComparison(dal.FieldRef{Name: "name"}, dal.LessThen, dal.Constant{Value: name("Jo\U0010FFFF")}),
+	}, group.Conditions())
+}
+
+func TestContains(t *testing.T) {
+	tags := NewField[[]string]("tags")
+	assert.Equal(t,
+		dal.NewComparison(dal.FieldRef{Name: "tags"}, dal.ArrayContains, dal.Constant{Value: "go"}),
+		Contains(tags, "go"))
+}
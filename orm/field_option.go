@@ -12,6 +12,7 @@ func Required[T any]() FieldOption[T] {
 func Default[T any](value T) FieldOption[T] {
 	return func(f FieldDefinition[T]) FieldDefinition[T] {
 		f.defaultVal = value
+		f.hasDefault = true
 		return f
 	}
 }
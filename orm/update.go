@@ -0,0 +1,152 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// ErrInvalidUpdate indicates an update does not match fields of a collection
+var ErrInvalidUpdate = errors.New("invalid update")
+
+// RecordUpdate holds validated updates of a record, see NewRecordUpdate()
+type RecordUpdate struct {
+	Key     *dal.Key
+	Updates []dal.Update
+}
+
+// Apply updates the record within a transaction
+func (v RecordUpdate) Apply(ctx context.Context, tx dal.ReadwriteTransaction, preconditions ...dal.Precondition) error {
+	return tx.Update(ctx, v.Key, v.Updates, preconditions...)
+}
+
+// NewRecordUpdate validates updates of a record of a collection:
+//   - the key should belong to the collection;
+//   - updated fields (or parents of updated nested fields) should be defined by the collection,
+//     paths to nested fields are checked against types of fields defined by FieldDefinition;
+//   - required fields can't be deleted or set to nil.
+func NewRecordUpdate(collection Collection, key *dal.Key, updates ...dal.Update) (RecordUpdate, error) {
+	if collection == nil {
+		panic("collection is a required parameter, got nil")
+	}
+	if key == nil {
+		panic("key is a required parameter, got nil")
+	}
+	if name := collection.CollectionRef().Name; key.Collection() != name {
+		return RecordUpdate{}, fmt.Errorf("%w: key %v does not belong to collection %s", ErrInvalidUpdate, key, name)
+	}
+	if len(updates) == 0 {
+		return RecordUpdate{}, fmt.Errorf("%w: no updates for %v", ErrInvalidUpdate, key)
+	}
+	fields := make(map[string]Field)
+	for _, f := range collection.Fields() {
+		fields[f.Name()] = f
+	}
+	for _, u := range updates {
+		if err := u.Validate(); err != nil {
+			return RecordUpdate{}, fmt.Errorf("%w: %w", ErrInvalidUpdate, err)
+		}
+		if err := validateFieldUpdate(fields, u); err != nil {
+			return RecordUpdate{}, fmt.Errorf("%w of %v: %w", ErrInvalidUpdate, key, err)
+		}
+	}
+	return RecordUpdate{Key: key, Updates: updates}, nil
+}
+
+func validateFieldUpdate(fields map[string]Field, u dal.Update) error {
+	path := u.Field
+	if path == "" {
+		path = strings.Join(u.FieldPath, ".")
+	}
+	if f, ok := fields[path]; ok {
+		if f.IsRequired() && (u.Value == dal.DeleteField || isNil(u.Value)) {
+			return fmt.Errorf("required field %s can't be deleted or set to nil", path)
+		}
+		return nil
+	}
+	var parent string
+	var nested []string
+	if len(u.FieldPath) > 0 {
+		parent, nested = u.FieldPath[0], u.FieldPath[1:]
+	} else {
+		nested = strings.Split(u.Field, ".")
+		parent, nested = nested[0], nested[1:]
+	}
+	f, ok := fields[parent]
+	if !ok || len(nested) == 0 {
+		return fmt.Errorf("unknown field %s", path)
+	}
+	if typed, ok := f.(typedField); ok {
+		if err := checkNestedPath(typed.reflectType(), nested); err != nil {
+			return fmt.Errorf("unknown field %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// checkNestedPath checks a path to a nested field against a type of a field.
+// Items of maps with string keys can have any names & values of interfaces can have any nested fields.
+func checkNestedPath(t reflect.Type, path []string) error {
+	for i, name := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Interface:
+			return nil
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return fmt.Errorf("map with keys of type %v has no named items", t.Key())
+			}
+			t = t.Elem()
+		case reflect.Struct:
+			index, found := structFieldIndex(t, name)
+			if !found {
+				return fmt.Errorf("%v has no field %s", t, name)
+			}
+			t = t.FieldByIndex(index).Type
+		default:
+			return fmt.Errorf("%s of type %v has no nested fields", strings.Join(path[:i], "."), t)
+		}
+	}
+	return nil
+}
+
+// structFieldIndex finds an exported struct field by a `dalgo` tag name or by the Go field name
+// the same way as the dal package does
+func structFieldIndex(t reflect.Type, name string) (index []int, found bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if tagName, _, _ := strings.Cut(field.Tag.Get("dalgo"), ","); tagName == name {
+			return field.Index, true
+		}
+	}
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous && field.Name == name {
+			if tagName, _, _ := strings.Cut(field.Tag.Get("dalgo"), ","); tagName == "" || tagName == name {
+				return field.Index, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// isNil checks for nil & typed nil values, e.g. (*string)(nil).
+// A nil slice is not nil as it is an empty slice, so it can be set to a required field.
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,129 @@
+package orm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/stretchr/testify/assert"
+)
+
+type updatingTx struct {
+	dal.ReadwriteTransaction
+	key     *dal.Key
+	updates []dal.Update
+}
+
+func (tx *updatingTx) Update(_ context.Context, key *dal.Key, updates []dal.Update, _ ...dal.Precondition) error {
+	tx.key, tx.updates = key, updates
+	return nil
+}
+
+type testItemAddress struct {
+	City     string `dalgo:"city"`
+	PostCode string
+	Geo      *struct {
+		Lat float64 `dalgo:"lat"`
+	} `dalgo:"geo"`
+}
+
+type testItemCollection struct {
+	Title   FieldDefinition[string]
+	Count   FieldDefinition[int]
+	Tags    FieldDefinition[[]string]
+	Meta    FieldDefinition[map[string]string]
+	Labels  FieldDefinition[[]string]
+	Address FieldDefinition[*testItemAddress]
+	Extra   FieldDefinition[any]
+}
+
+func (v testItemCollection) CollectionRef() dal.CollectionRef {
+	return dal.CollectionRef{Name: "items"}
+}
+
+func (v testItemCollection) Fields() []Field {
+	return []Field{v.Title, v.Count, v.Tags, v.Meta, v.Labels, v.Address, v.Extra}
+}
+
+var testItems = testItemCollection{
+	Title:   NewField[string]("title", Required[string]()),
+	Count:   NewField[int]("count"),
+	Tags:    NewField[[]string]("tags"),
+	Meta:    NewField[map[string]string]("meta"),
+	Labels:  NewField[[]string]("labels", Required[[]string]()),
+	Address: NewField[*testItemAddress]("address"),
+	Extra:   NewFieldWithType[any]("extra", "interface {}"),
+}
+
+func TestFieldDefinition_Updates(t *testing.T) {
+	assert.Equal(t, dal.Update{Field: "title", Value: "abc"}, testItems.Title.Set("abc"))
+	assert.Equal(t, dal.Update{Field: "count", Value: dal.DeleteField}, testItems.Count.Delete())
+	assert.Equal(t, dal.Update{Field: "count", Value: dal.Increment(2)}, Increment(testItems.Count, 2))
+	assert.Equal(t, dal.Update{Field: "tags", Value: dal.ArrayUnion("a", "b")}, ArrayUnion(testItems.Tags, "a", "b"))
+}
+
+func TestNewRecordUpdate(t *testing.T) {
+	key := dal.NewKeyWithID("items", "i1")
+	assert.Panics(t, func() {
+		_, _ = NewRecordUpdate(nil, key)
+	})
+	assert.Panics(t, func() {
+		_, _ = NewRecordUpdate(testItems, nil)
+	})
+
+	updates := []dal.Update{
+		testItems.Title.Set("abc"),
+		testItems.Count.Delete(),
+		Increment(testItems.Count, 1),
+		{Field: "meta.color", Value: "red"},
+		{FieldPath: dal.FieldPath{"meta", "size"}, Value: dal.DeleteField},
+		{Field: "address.city", Value: "Dublin"},
+		{FieldPath: dal.FieldPath{"address", "PostCode"}, Value: "D01"},
+		{Field: "address.geo.lat", Value: 53.3},
+		{Field: "extra.any.path", Value: 1},
+		{Field: "labels", Value: []string(nil)},
+	}
+	update, err := NewRecordUpdate(testItems, key, updates...)
+	assert.Nil(t, err)
+	title := "abc"
+	_, err = NewRecordUpdate(testItems, key, dal.Update{Field: "title", Value: &title})
+	assert.Nil(t, err, "non-nil pointer to a required value")
+	assert.Equal(t, RecordUpdate{Key: key, Updates: updates}, update)
+
+	tx := &updatingTx{}
+	assert.Nil(t, update.Apply(context.Background(), tx))
+	assert.Equal(t, key, tx.key)
+	assert.Equal(t, updates, tx.updates)
+
+	for name, tt := range map[string]struct {
+		key     *dal.Key
+		updates []dal.Update
+	}{
+		"another_collection":    {key: dal.NewKeyWithID("users", "u1"), updates: []dal.Update{testItems.Title.Set("abc")}},
+		"no_updates":            {key: key},
+		"invalid_update":        {key: key, updates: []dal.Update{{Value: 1}}},
+		"unknown_field":         {key: key, updates: []dal.Update{{Field: "titel", Value: "abc"}}},
+		"unknown_parent":        {key: key, updates: []dal.Update{{Field: "info.color", Value: "red"}}},
+		"delete_required":       {key: key, updates: []dal.Update{testItems.Title.Delete()}},
+		"nil_required":          {key: key, updates: []dal.Update{{FieldPath: dal.FieldPath{"title"}, Value: nil}}},
+		"typed_nil_required":    {key: key, updates: []dal.Update{{Field: "title", Value: (*string)(nil)}}},
+		"nil_map_required":      {key: key, updates: []dal.Update{{Field: "title", Value: map[string]any(nil)}}},
+		"unknown_nested":        {key: key, updates: []dal.Update{{Field: "address.ctiy", Value: "Dublin"}}},
+		"unknown_deep":          {key: key, updates: []dal.Update{{FieldPath: dal.FieldPath{"address", "geo", "lng"}, Value: 1}}},
+		"nested_of_scalar":      {key: key, updates: []dal.Update{{Field: "count.value", Value: 1}}},
+		"nested_of_slice":       {key: key, updates: []dal.Update{{Field: "tags.0", Value: "a"}}},
+		"delete_required_slice": {key: key, updates: []dal.Update{testItems.Labels.Delete()}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewRecordUpdate(testItems, tt.key, tt.updates...)
+			assert.ErrorIs(t, err, ErrInvalidUpdate)
+		})
+	}
+}
+
+func TestUserCollection_Update(t *testing.T) {
+	_, err := Users.Update(dal.NewKeyWithID("Users", "u1"), Users.Field.Email.Delete())
+	assert.ErrorIs(t, err, ErrInvalidUpdate)
+	_, err = Users.Update(dal.NewKeyWithID("Users", "u1"), Users.Field.Email.Set("u1@example.com"))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,233 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// memoryDB is a minimal in-memory DB with serialized readwrite transactions.
+// Records are stored as Message structs & fields are resolved by `dalgo` tags like adapters that store structs do.
+// Queries support just AND of `==` & `<=` conditions and return records ordered by createdAt & ID.
+type memoryDB struct {
+	dal.DB     // not implemented methods panic
+	txMutex    sync.Mutex
+	mutex      sync.Mutex
+	records    map[string]Message // by ID of records of the outbox collection
+	collection string
+	queries    []string
+}
+
+func newMemoryDB(collection string) *memoryDB {
+	return &memoryDB{records: make(map[string]Message), collection: collection}
+}
+
+func (db *memoryDB) message(id string) *Message {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	m, ok := db.records[id]
+	if !ok {
+		return nil
+	}
+	m.ID = id
+	return &m
+}
+
+func (db *memoryDB) ids() (ids []string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for id := range db.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (db *memoryDB) get(record dal.Record) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	m, ok := db.records[record.Key().ID.(string)]
+	if !ok {
+		err := dal.NewErrNotFoundByKey(record.Key(), nil)
+		record.SetError(err)
+		return err
+	}
+	record.SetError(nil)
+	*record.Data().(*Message) = m
+	return nil
+}
+
+func (db *memoryDB) Get(_ context.Context, record dal.Record) error {
+	return db.get(record)
+}
+
+func (db *memoryDB) GetMulti(_ context.Context, records []dal.Record) error {
+	for _, record := range records {
+		if err := db.get(record); err != nil && !dal.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *memoryDB) QueryReader(_ context.Context, query dal.Query) (dal.Reader, error) {
+	db.mutex.Lock()
+	db.queries = append(db.queries, query.String())
+	db.mutex.Unlock()
+	if query.From().Name != db.collection {
+		return dal.EmptyReader{}, nil
+	}
+	if orderBy := query.OrderBy(); len(orderBy) != 1 || orderBy[0].String() != FieldCreatedAt {
+		panic("unsupported order: " + query.String())
+	}
+	ids := db.ids()
+	sort.SliceStable(ids, func(i, j int) bool {
+		return db.fieldValue(ids[i], FieldCreatedAt).(time.Time).Before(db.fieldValue(ids[j], FieldCreatedAt).(time.Time))
+	})
+	var records []dal.Record
+	for _, id := range ids {
+		if !db.matches(id, query.Where()) {
+			continue
+		}
+		if query.Limit() > 0 && len(records) == query.Limit() {
+			break
+		}
+		record := query.Into()()
+		record.Key().ID = id
+		if err := db.get(record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return dal.EmptyReader{}, nil
+	}
+	return dal.NewRecordsReader(records), nil
+}
+
+// fieldValue returns a value of a message field by a name used in queries
+func (db *memoryDB) fieldValue(id, name string) any {
+	db.mutex.Lock()
+	m := db.records[id]
+	db.mutex.Unlock()
+	value, found := dal.GetFieldValue(&m, name)
+	if !found {
+		panic(fmt.Sprintf("message has no field %q", name))
+	}
+	return value
+}
+
+// matches checks conditions like `field == "value" AND field <= time`
+func (db *memoryDB) matches(id string, condition dal.Condition) bool {
+	switch c := condition.(type) {
+	case nil:
+		return true
+	case dal.GroupCondition:
+		if c.Operator() != dal.And {
+			panic("unsupported condition: " + condition.String())
+		}
+		for _, item := range c.Conditions() {
+			if !db.matches(id, item) {
+				return false
+			}
+		}
+		return true
+	case dal.Comparison:
+		value, expected := db.fieldValue(id, c.Left.(dal.FieldRef).Name), c.Right.(dal.Constant).Value
+		switch c.Operator {
+		case dal.Equal:
+			return fmt.Sprint(value) == fmt.Sprint(expected)
+		case dal.LessOrEqual:
+			return !value.(time.Time).After(expected.(time.Time))
+		}
+	}
+	panic("unsupported condition: " + condition.String())
+}
+
+func (db *memoryDB) RunReadwriteTransaction(ctx context.Context, f dal.RWTxWorker, _ ...dal.TransactionOption) error {
+	db.txMutex.Lock()
+	defer db.txMutex.Unlock()
+	db.mutex.Lock()
+	snapshot := make(map[string]Message, len(db.records))
+	for id, m := range db.records {
+		snapshot[id] = m
+	}
+	db.mutex.Unlock()
+	if err := f(ctx, memoryTx{db: db}); err != nil {
+		db.mutex.Lock()
+		db.records = snapshot
+		db.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+type memoryTx struct {
+	dal.ReadwriteTransaction // not implemented methods panic
+	db                       *memoryDB
+}
+
+func (tx memoryTx) Get(ctx context.Context, record dal.Record) error {
+	return tx.db.Get(ctx, record)
+}
+
+func (tx memoryTx) GetMulti(ctx context.Context, records []dal.Record) error {
+	return tx.db.GetMulti(ctx, records)
+}
+
+func (tx memoryTx) InsertMulti(_ context.Context, records []dal.Record, _ ...dal.InsertOption) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	for _, record := range records {
+		id := record.Key().ID.(string)
+		if _, exists := tx.db.records[id]; exists {
+			return dal.NewErrAlreadyExists(record.Key(), nil)
+		}
+		record.SetError(nil)
+		m := *record.Data().(*Message)
+		m.ID = ""
+		tx.db.records[id] = m
+	}
+	return nil
+}
+
+// Update sets fields of a message found by names in `dalgo` tags
+func (tx memoryTx) Update(_ context.Context, key *dal.Key, updates []dal.Update, _ ...dal.Precondition) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	id := key.ID.(string)
+	m, ok := tx.db.records[id]
+	if !ok {
+		return dal.NewErrNotFoundByKey(key, nil)
+	}
+	v := reflect.ValueOf(&m).Elem()
+	for _, u := range updates {
+		name := strings.Join(append([]string{u.Field}, u.FieldPath...), "")
+		field, found := reflect.StructField{}, false
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			if tagName, _, _ := strings.Cut(f.Tag.Get("dalgo"), ","); tagName == name {
+				field, found = f, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("message has no field %q", name)
+		}
+		v.FieldByIndex(field.Index).Set(reflect.ValueOf(u.Value).Convert(field.Type))
+	}
+	tx.db.records[id] = m
+	return nil
+}
+
+func (tx memoryTx) Delete(_ context.Context, key *dal.Key) error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	delete(tx.db.records, key.ID.(string))
+	return nil
+}
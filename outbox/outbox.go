@@ -0,0 +1,140 @@
+// Package outbox implements the transactional outbox pattern on top of dalgo interfaces.
+//
+// Messages are enqueued within a readwrite transaction together with business data,
+// so they are stored if and only if the transaction is committed.
+// A Relay polls the outbox collection, publishes pending messages & marks them as published (or deletes them).
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// DefaultCollection is a name of the outbox collection used if no other is specified
+const DefaultCollection = "outbox"
+
+// Status of an outbox message
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPublished Status = "published"
+)
+
+// Names of message fields as stored in DB
+const (
+	FieldTopic       = "topic"
+	FieldPayload     = "payload"
+	FieldHeaders     = "headers"
+	FieldStatus      = "status"
+	FieldCreatedAt   = "createdAt"
+	FieldAttempts    = "attempts"
+	FieldLeaseOwner  = "leaseOwner"
+	FieldLeasedUntil = "leasedUntil"
+	FieldPublishedAt = "publishedAt"
+	FieldLastError   = "lastError"
+)
+
+// Message is an entry of the outbox collection,
+// names of fields in `dalgo` tags match the Field* constants used by queries & updates of a Relay
+type Message struct {
+	ID          string            `json:"-" dalgo:"-"` // ID of the record, assigned by Enqueue()
+	Topic       string            `json:"topic" dalgo:"topic"`
+	Payload     []byte            `json:"payload,omitempty" dalgo:"payload"`
+	Headers     map[string]string `json:"headers,omitempty" dalgo:"headers"`
+	Status      Status            `json:"status" dalgo:"status"`
+	CreatedAt   time.Time         `json:"createdAt" dalgo:"createdAt"`
+	Attempts    int               `json:"attempts,omitempty" dalgo:"attempts"`
+	LeaseOwner  string            `json:"leaseOwner,omitempty" dalgo:"leaseOwner"`
+	LeasedUntil time.Time         `json:"leasedUntil" dalgo:"leasedUntil"`
+	PublishedAt time.Time         `json:"publishedAt" dalgo:"publishedAt"`
+	LastError   string            `json:"lastError,omitempty" dalgo:"lastError"`
+}
+
+// Validate returns an error if a message can't be enqueued
+func (m Message) Validate() error {
+	if m.Topic == "" {
+		return fmt.Errorf("%w: topic is required", dal.ErrValidationFailed)
+	}
+	return nil
+}
+
+// Outbox enqueues messages into an outbox collection
+type Outbox struct {
+	collection string
+	newID      func() string
+	now        func() time.Time
+}
+
+// Option configures an Outbox
+type Option func(o *Outbox)
+
+// WithCollection sets a name of the outbox collection
+func WithCollection(collection string) Option {
+	if collection == "" {
+		panic("collection is a required parameter, got empty string")
+	}
+	return func(o *Outbox) {
+		o.collection = collection
+	}
+}
+
+// WithIDGenerator sets a generator of message IDs, by default ULIDs are used.
+// Messages are relayed in order of their creation time regardless of IDs.
+func WithIDGenerator(newID func() string) Option {
+	if newID == nil {
+		panic("newID is a required parameter, got nil")
+	}
+	return func(o *Outbox) {
+		o.newID = newID
+	}
+}
+
+// New creates an outbox
+func New(options ...Option) *Outbox {
+	o := &Outbox{collection: DefaultCollection, newID: dal.NewULID, now: time.Now}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// Collection returns a name of the outbox collection
+func (o *Outbox) Collection() string {
+	return o.collection
+}
+
+// NewKey creates a key of a message record
+func (o *Outbox) NewKey(id string) *dal.Key {
+	return dal.NewKeyWithID(o.collection, id)
+}
+
+// Enqueue inserts messages into the outbox within the transaction.
+// IDs, creation time & pending status are assigned to the messages.
+func (o *Outbox) Enqueue(ctx context.Context, tx dal.ReadwriteTransaction, messages ...*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	now := o.now()
+	records := make([]dal.Record, len(messages))
+	for i, m := range messages {
+		if m == nil {
+			panic(fmt.Sprintf("messages[%d] is nil", i))
+		}
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("invalid outbox message #%d: %w", i, err)
+		}
+		m.ID = o.newID()
+		m.Status = StatusPending
+		m.CreatedAt = now
+		m.Attempts, m.LeaseOwner, m.LeasedUntil, m.PublishedAt, m.LastError = 0, "", time.Time{}, time.Time{}, ""
+		records[i] = dal.NewRecordWithData(o.NewKey(m.ID), m)
+	}
+	if err := tx.InsertMulti(ctx, records); err != nil {
+		return fmt.Errorf("failed to enqueue %d outbox messages: %w", len(messages), err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOutbox(db *memoryDB) *Outbox {
+	var i int
+	o := New(WithCollection(db.collection), WithIDGenerator(func() string {
+		i++
+		return fmt.Sprintf("m%d", i)
+	}))
+	o.now = func() time.Time {
+		return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+	return o
+}
+
+func TestNew(t *testing.T) {
+	o := New()
+	assert.Equal(t, DefaultCollection, o.Collection())
+	assert.Equal(t, dal.NewKeyWithID(DefaultCollection, "m1"), o.NewKey("m1"))
+	assert.Equal(t, "events", New(WithCollection("events")).Collection())
+	assert.Panics(t, func() {
+		WithCollection("")
+	})
+	assert.Panics(t, func() {
+		WithIDGenerator(nil)
+	})
+}
+
+func TestOutbox_Enqueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("committed", func(t *testing.T) {
+		db := newMemoryDB("outbox")
+		o := newTestOutbox(db)
+		m1 := &Message{Topic: "users", Payload: []byte(`{"id":1}`), Attempts: 5}
+		m2 := &Message{Topic: "orders", Headers: map[string]string{"h": "v"}}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return o.Enqueue(ctx, tx, m1, m2)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"m1", "m2"}, db.ids())
+		assert.Equal(t, &Message{
+			ID:        "m1",
+			Topic:     "users",
+			Payload:   []byte(`{"id":1}`),
+			Status:    StatusPending,
+			CreatedAt: o.now(),
+		}, db.message("m1"))
+		assert.Equal(t, map[string]string{"h": "v"}, db.message("m2").Headers)
+	})
+
+	t.Run("rolled_back", func(t *testing.T) {
+		db := newMemoryDB("outbox")
+		o := newTestOutbox(db)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := o.Enqueue(ctx, tx, &Message{Topic: "users"}); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		assert.EqualError(t, err, "rollback")
+		assert.Empty(t, db.ids())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		db := newMemoryDB("outbox")
+		o := newTestOutbox(db)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return o.Enqueue(ctx, tx, &Message{Topic: "users"}, &Message{})
+		})
+		assert.ErrorIs(t, err, dal.ErrValidationFailed)
+		assert.ErrorContains(t, err, "invalid outbox message #1")
+		assert.Empty(t, db.ids())
+	})
+}
+
+func TestMessage_fieldNames(t *testing.T) {
+	m := &Message{Topic: "users"}
+	for _, name := range []string{
+		FieldTopic, FieldPayload, FieldHeaders, FieldStatus, FieldCreatedAt, FieldAttempts,
+		FieldLeaseOwner, FieldLeasedUntil, FieldPublishedAt, FieldLastError,
+	} {
+		_, found := dal.GetFieldValue(m, name)
+		assert.True(t, found, name)
+	}
+}
@@ -0,0 +1,324 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// ErrLeaseLost indicates a message has been published but its lease expired & was taken by another relay,
+// so the message can be published more than once.
+var ErrLeaseLost = errors.New("lease of outbox message has been lost")
+
+// PublishFunc publishes a message to a message broker, e.g. a queue or a topic.
+// As delivery is "at least once", consumers should deduplicate messages by Message.ID.
+type PublishFunc func(ctx context.Context, message Message) error
+
+// Relay publishes pending messages of an outbox.
+// Each message is leased before publishing, so concurrent relays do not publish the same message
+// unless the lease expires before the message is published.
+type Relay struct {
+	db                 dal.DB
+	outbox             *Outbox
+	publish            PublishFunc
+	id                 string
+	batchSize          int
+	leaseDuration      time.Duration
+	retryBackoff       time.Duration
+	pollInterval       time.Duration
+	deleteAfterPublish bool
+	onError            func(err error)
+	now                func() time.Time
+}
+
+// RelayOption configures a Relay
+type RelayOption func(r *Relay)
+
+// WithRelayID sets an ID of a relay that is stored as an owner of leases, by default a ULID is used
+func WithRelayID(id string) RelayOption {
+	if id == "" {
+		panic("id is a required parameter, got empty string")
+	}
+	return func(r *Relay) {
+		r.id = id
+	}
+}
+
+// WithBatchSize sets max number of messages processed by a single ProcessBatch() call, defaults to 100
+func WithBatchSize(size int) RelayOption {
+	if size < 1 {
+		panic(fmt.Sprintf("size should be positive, got %d", size))
+	}
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithLeaseDuration sets for how long a message is leased for publishing, defaults to 1 minute.
+// It should be longer than the time needed to publish a batch of messages.
+func WithLeaseDuration(d time.Duration) RelayOption {
+	if d <= 0 {
+		panic(fmt.Sprintf("lease duration should be positive, got %v", d))
+	}
+	return func(r *Relay) {
+		r.leaseDuration = d
+	}
+}
+
+// WithRetryBackoff sets for how long a message that failed to be published is not retried.
+// Defaults to 0, so the message is retried by the next ProcessBatch() call.
+func WithRetryBackoff(d time.Duration) RelayOption {
+	if d < 0 {
+		panic(fmt.Sprintf("retry backoff should not be negative, got %v", d))
+	}
+	return func(r *Relay) {
+		r.retryBackoff = d
+	}
+}
+
+// WithPollInterval sets for how long Run() waits when there are no pending messages, defaults to 1 second
+func WithPollInterval(d time.Duration) RelayOption {
+	if d <= 0 {
+		panic(fmt.Sprintf("poll interval should be positive, got %v", d))
+	}
+	return func(r *Relay) {
+		r.pollInterval = d
+	}
+}
+
+// DeleteAfterPublish makes a relay to delete published messages instead of marking them as published
+func DeleteAfterPublish() RelayOption {
+	return func(r *Relay) {
+		r.deleteAfterPublish = true
+	}
+}
+
+// WithErrorHandler sets a handler of errors of ProcessBatch() called by Run(), by default errors are ignored
+func WithErrorHandler(onError func(err error)) RelayOption {
+	if onError == nil {
+		panic("onError is a required parameter, got nil")
+	}
+	return func(r *Relay) {
+		r.onError = onError
+	}
+}
+
+// NewRelay creates a relay of outbox messages
+func NewRelay(db dal.DB, outbox *Outbox, publish PublishFunc, options ...RelayOption) *Relay {
+	if db == nil {
+		panic("db is a required parameter, got nil")
+	}
+	if outbox == nil {
+		panic("outbox is a required parameter, got nil")
+	}
+	if publish == nil {
+		panic("publish is a required parameter, got nil")
+	}
+	r := &Relay{
+		db:            db,
+		outbox:        outbox,
+		publish:       publish,
+		id:            dal.NewULID(),
+		batchSize:     100,
+		leaseDuration: time.Minute,
+		pollInterval:  time.Second,
+		onError:       func(error) {},
+		now:           time.Now,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// ID returns an ID of the relay
+func (r *Relay) ID() string {
+	return r.id
+}
+
+// Run processes batches of messages until the context is done & returns the context error
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		processed, err := r.ProcessBatch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.onError(err)
+		}
+		if processed == r.batchSize && err == nil {
+			continue // there can be more pending messages
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// ProcessBatch leases a batch of pending messages, publishes them & marks them as published (or deletes them).
+// Returns number of successfully published messages.
+// Failure to publish a message does not stop processing of other messages,
+// a failed message is released & published again after the retry backoff (see WithRetryBackoff()).
+func (r *Relay) ProcessBatch(ctx context.Context) (published int, err error) {
+	candidates, err := r.selectPending(ctx)
+	if err != nil || len(candidates) == 0 {
+		return 0, err
+	}
+	leased, err := r.lease(ctx, candidates)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, m := range leased {
+		if err = r.publish(ctx, *m); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish outbox message %s: %w", m.ID, err))
+			if err = r.release(ctx, m, err); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err = r.complete(ctx, m); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		published++
+	}
+	return published, errors.Join(errs...)
+}
+
+func (r *Relay) newRecord(key *dal.Key) dal.Record {
+	return dal.NewRecordWithData(key, new(Message))
+}
+
+// selectPending queries pending messages that are not leased in order of enqueueing
+func (r *Relay) selectPending(ctx context.Context) (keys []*dal.Key, err error) {
+	now := r.now()
+	query := dal.From(r.outbox.collection).
+		WhereField(FieldStatus, dal.Equal, string(StatusPending)).
+		WhereField(FieldLeasedUntil, dal.LessOrEqual, now).
+		OrderBy(dal.AscendingField(FieldCreatedAt)).
+		Limit(r.batchSize).
+		SelectInto(func() dal.Record {
+			return dal.NewRecordWithIncompleteKey(r.outbox.collection, reflect.String, new(Message))
+		})
+	reader, err := r.db.QueryReader(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending outbox messages: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	records, err := dal.ReadAll(ctx, reader, r.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending outbox messages: %w", err)
+	}
+	for _, record := range records { // double-check in case the DB does not apply all conditions
+		if m := record.Data().(*Message); r.isLeasable(m, now) {
+			keys = append(keys, record.Key())
+		}
+	}
+	return keys, nil
+}
+
+func (r *Relay) isLeasable(m *Message, now time.Time) bool {
+	return m.Status == StatusPending && !m.LeasedUntil.After(now)
+}
+
+// lease re-reads messages within a transaction & leases those that are still pending & not leased
+func (r *Relay) lease(ctx context.Context, keys []*dal.Key) (leased []*Message, err error) {
+	err = r.db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		leased = nil // the worker can be retried
+		records := make([]dal.Record, len(keys))
+		for i, key := range keys {
+			records[i] = r.newRecord(key)
+		}
+		if err := tx.GetMulti(ctx, records); err != nil {
+			return err
+		}
+		now := r.now()
+		leasedUntil := now.Add(r.leaseDuration)
+		for _, record := range records {
+			if !record.Exists() {
+				continue // already deleted by another relay
+			}
+			m := record.Data().(*Message)
+			if !r.isLeasable(m, now) {
+				continue
+			}
+			m.ID = fmt.Sprint(record.Key().ID)
+			m.Attempts++
+			m.LeaseOwner, m.LeasedUntil = r.id, leasedUntil
+			if err := tx.Update(ctx, record.Key(), []dal.Update{
+				{Field: FieldAttempts, Value: m.Attempts},
+				{Field: FieldLeaseOwner, Value: m.LeaseOwner},
+				{Field: FieldLeasedUntil, Value: m.LeasedUntil},
+			}); err != nil {
+				return err
+			}
+			leased = append(leased, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease %d outbox messages: %w", len(keys), err)
+	}
+	return leased, nil
+}
+
+// withLease runs a transaction with the message record if it is still leased by the relay
+func (r *Relay) withLease(ctx context.Context, m *Message, f func(ctx context.Context, tx dal.ReadwriteTransaction, key *dal.Key) error) error {
+	key := r.outbox.NewKey(m.ID)
+	return r.db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		record := r.newRecord(key)
+		if err := tx.Get(ctx, record); err != nil {
+			if dal.IsNotFound(err) {
+				return fmt.Errorf("%w: message %s has been deleted", ErrLeaseLost, m.ID)
+			}
+			return err
+		}
+		if current := record.Data().(*Message); current.LeaseOwner != r.id || current.Status != StatusPending {
+			return fmt.Errorf("%w: message %s is leased by %q with status %q", ErrLeaseLost, m.ID, current.LeaseOwner, current.Status)
+		}
+		return f(ctx, tx, key)
+	})
+}
+
+// complete marks a published message as published or deletes it
+func (r *Relay) complete(ctx context.Context, m *Message) error {
+	err := r.withLease(ctx, m, func(ctx context.Context, tx dal.ReadwriteTransaction, key *dal.Key) error {
+		if r.deleteAfterPublish {
+			return tx.Delete(ctx, key)
+		}
+		return tx.Update(ctx, key, []dal.Update{
+			{Field: FieldStatus, Value: string(StatusPublished)},
+			{Field: FieldPublishedAt, Value: r.now()},
+			{Field: FieldLeaseOwner, Value: ""},
+			{Field: FieldLeasedUntil, Value: time.Time{}},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete published outbox message %s: %w", m.ID, err)
+	}
+	return nil
+}
+
+// release records a publishing error & replaces the lease with the retry backoff
+func (r *Relay) release(ctx context.Context, m *Message, publishErr error) error {
+	err := r.withLease(ctx, m, func(ctx context.Context, tx dal.ReadwriteTransaction, key *dal.Key) error {
+		return tx.Update(ctx, key, []dal.Update{
+			{Field: FieldLastError, Value: publishErr.Error()},
+			{Field: FieldLeaseOwner, Value: ""},
+			{Field: FieldLeasedUntil, Value: r.now().Add(r.retryBackoff)},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record error of outbox message %s: %w", m.ID, err)
+	}
+	return nil
+}
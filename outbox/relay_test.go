@@ -0,0 +1,257 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func setupRelayTest(t *testing.T, count int) (*memoryDB, *Outbox, *testClock) {
+	db := newMemoryDB("outbox")
+	o := newTestOutbox(db)
+	messages := make([]*Message, count)
+	for i := range messages {
+		messages[i] = &Message{Topic: "users"}
+	}
+	err := db.RunReadwriteTransaction(context.Background(), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		return o.Enqueue(ctx, tx, messages...)
+	})
+	assert.Nil(t, err)
+	return db, o, &testClock{now: o.now()}
+}
+
+func newTestRelay(db *memoryDB, o *Outbox, clock *testClock, publish PublishFunc, options ...RelayOption) *Relay {
+	r := NewRelay(db, o, publish, options...)
+	r.now = clock.Now
+	return r
+}
+
+func TestNewRelay(t *testing.T) {
+	db := newMemoryDB("outbox")
+	publish := func(ctx context.Context, message Message) error { return nil }
+	assert.Panics(t, func() {
+		NewRelay(nil, New(), publish)
+	})
+	assert.Panics(t, func() {
+		NewRelay(db, nil, publish)
+	})
+	assert.Panics(t, func() {
+		NewRelay(db, New(), nil)
+	})
+	assert.Panics(t, func() {
+		WithBatchSize(0)
+	})
+	assert.Panics(t, func() {
+		WithLeaseDuration(0)
+	})
+	assert.Panics(t, func() {
+		WithRetryBackoff(-time.Second)
+	})
+	r := NewRelay(db, New(), publish, WithRelayID("r1"))
+	assert.Equal(t, "r1", r.ID())
+	assert.NotEmpty(t, NewRelay(db, New(), publish).ID())
+}
+
+func TestRelay_ProcessBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mark_as_published", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 3)
+		var published []string
+		relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			published = append(published, message.ID)
+			assert.Equal(t, 1, message.Attempts)
+			return nil
+		}, WithBatchSize(2), WithRelayID("r1"))
+
+		count, err := relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"m1", "m2"}, published)
+		assert.Contains(t, db.queries[0], "status")
+		m1 := db.message("m1")
+		assert.Equal(t, StatusPublished, m1.Status)
+		assert.Equal(t, clock.now, m1.PublishedAt)
+		assert.Equal(t, "", m1.LeaseOwner)
+
+		count, err = relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []string{"m1", "m2", "m3"}, published)
+	})
+
+	t.Run("delete_after_publish", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 2)
+		relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			return nil
+		}, DeleteAfterPublish())
+		count, err := relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Empty(t, db.ids())
+	})
+
+	t.Run("concurrent_relays", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 2)
+		var published []string
+		var relay2 *Relay
+		relay1 := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			count, err := relay2.ProcessBatch(ctx) // messages are leased by relay1
+			assert.Nil(t, err)
+			assert.Equal(t, 0, count)
+			published = append(published, message.ID)
+			return nil
+		}, WithRelayID("r1"))
+		relay2 = newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			published = append(published, "r2:"+message.ID)
+			return nil
+		}, WithRelayID("r2"))
+		count, err := relay1.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"m1", "m2"}, published)
+	})
+
+	t.Run("publish_failed", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 2)
+		fail := true
+		relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			if fail && message.ID == "m1" {
+				return errors.New("broker is down")
+			}
+			return nil
+		}, WithLeaseDuration(time.Minute), WithRetryBackoff(10*time.Second), WithRelayID("r1"))
+		count, err := relay.ProcessBatch(ctx)
+		assert.Equal(t, 1, count)
+		assert.ErrorContains(t, err, "failed to publish outbox message m1: broker is down")
+		m1 := db.message("m1")
+		assert.Equal(t, StatusPending, m1.Status)
+		assert.Equal(t, "broker is down", m1.LastError)
+		assert.Equal(t, "", m1.LeaseOwner, "lease should be released")
+		assert.Equal(t, clock.now.Add(10*time.Second), m1.LeasedUntil)
+
+		clock.now = clock.now.Add(9 * time.Second)
+		count, err = relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count, "message should not be retried before the backoff passes")
+
+		fail = false
+		clock.now = clock.now.Add(time.Second)
+		count, err = relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count, "message should be retried after the backoff, not after the lease")
+		m1 = db.message("m1")
+		assert.Equal(t, StatusPublished, m1.Status)
+		assert.Equal(t, 2, m1.Attempts)
+	})
+
+	t.Run("publish_failed_without_backoff", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 1)
+		attempts := 0
+		relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			if attempts++; attempts == 1 {
+				return errors.New("broker is down")
+			}
+			return nil
+		})
+		_, err := relay.ProcessBatch(ctx)
+		assert.NotNil(t, err)
+		count, err := relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("skips_leased", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 3)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.Update(ctx, o.NewKey("m1"), []dal.Update{
+				{Field: FieldLeaseOwner, Value: "r2"},
+				{Field: FieldLeasedUntil, Value: clock.now.Add(time.Minute)},
+			})
+		})
+		assert.Nil(t, err)
+		var published []string
+		relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			published = append(published, message.ID)
+			return nil
+		}, WithBatchSize(1))
+		for i := 0; i < 2; i++ {
+			count, err := relay.ProcessBatch(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, count)
+		}
+		assert.Equal(t, []string{"m2", "m3"}, published)
+		assert.Contains(t, db.queries[0], FieldLeasedUntil)
+		assert.Contains(t, db.queries[0], FieldCreatedAt)
+	})
+
+	t.Run("enqueue_order", func(t *testing.T) {
+		db := newMemoryDB("outbox")
+		o := newTestOutbox(db)
+		createdAt := o.now()
+		for _, d := range []time.Duration{time.Second, 0} { // m1 is enqueued after m2
+			o.now = func() time.Time { return createdAt.Add(d) }
+			err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+				return o.Enqueue(ctx, tx, &Message{Topic: "users"})
+			})
+			assert.Nil(t, err)
+		}
+		var published []string
+		relay := newTestRelay(db, o, &testClock{now: createdAt.Add(time.Minute)}, func(ctx context.Context, message Message) error {
+			published = append(published, message.ID)
+			return nil
+		})
+		count, err := relay.ProcessBatch(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"m2", "m1"}, published)
+	})
+
+	t.Run("lease_lost", func(t *testing.T) {
+		db, o, clock := setupRelayTest(t, 1)
+		var relay2 *Relay
+		relay1 := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			clock.now = clock.now.Add(2 * time.Minute) // lease of relay1 expires
+			count, err := relay2.ProcessBatch(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, count)
+			return nil
+		}, WithRelayID("r1"))
+		relay2 = newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+			return nil
+		}, WithRelayID("r2"))
+		count, err := relay1.ProcessBatch(ctx)
+		assert.Equal(t, 0, count)
+		assert.ErrorIs(t, err, ErrLeaseLost)
+		assert.Equal(t, StatusPublished, db.message("m1").Status)
+	})
+}
+
+func TestRelay_Run(t *testing.T) {
+	db, o, clock := setupRelayTest(t, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	var published []string
+	relay := newTestRelay(db, o, clock, func(ctx context.Context, message Message) error {
+		published = append(published, message.ID)
+		if len(published) == 3 {
+			cancel()
+		}
+		return nil
+	}, WithBatchSize(2), WithPollInterval(time.Millisecond))
+	err := relay.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"m1", "m2", "m3"}, published)
+}
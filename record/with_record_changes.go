@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/dal-go/dalgo/dal"
+	"reflect"
+	"strings"
 )
 
 type WithRecordChanges struct {
 	recordsToInsert []dal.Record
+	insertKeys      map[string]struct{} // canonical keys of recordsToInsert, see dal.Key.Canonical()
 	RecordsToUpdate []*Updates
 	RecordsToDelete []*dal.Key
 }
@@ -33,55 +36,216 @@ func (v *WithRecordChanges) QueueForInsert(records ...dal.Record) {
 		if record.Data() == nil {
 			panic(fmt.Sprintf("record #%d.Data() is required", i))
 		}
-		for _, queuedRecord := range v.recordsToInsert {
-			if queuedRecord.Key().Equal(key) {
-				panic(fmt.Sprintf("record with key=%s is already queued for insert", key))
+		if v.insertKeys == nil || len(v.insertKeys) != len(v.recordsToInsert) {
+			v.insertKeys = make(map[string]struct{}, len(v.recordsToInsert)+len(records))
+			for _, queuedRecord := range v.recordsToInsert {
+				v.insertKeys[queuedRecord.Key().Canonical()] = struct{}{}
 			}
 		}
+		canonicalKey := key.Canonical()
+		if _, queued := v.insertKeys[canonicalKey]; queued {
+			panic(fmt.Sprintf("record with key=%s is already queued for insert", key))
+		}
+		v.insertKeys[canonicalKey] = struct{}{}
 		v.recordsToInsert = append(v.recordsToInsert, record)
 	}
 }
 
+// ApplyChangesPhase identifies a phase of WithRecordChanges.ApplyChanges()
+type ApplyChangesPhase string
+
+const (
+	// PhaseValidate validates queued changes before anything is written
+	PhaseValidate ApplyChangesPhase = "validate"
+
+	// PhaseInsert inserts queued records
+	PhaseInsert ApplyChangesPhase = "insert"
+
+	// PhaseUpdate applies queued updates
+	PhaseUpdate ApplyChangesPhase = "update"
+
+	// PhaseDelete deletes queued records
+	PhaseDelete ApplyChangesPhase = "delete"
+)
+
+// ApplyChangesError is returned by WithRecordChanges.ApplyChanges() and reports the phase that failed
+type ApplyChangesError struct {
+	Phase ApplyChangesPhase
+	Err   error
+}
+
+// Error implements error interface
+func (e *ApplyChangesError) Error() string {
+	return fmt.Sprintf("failed to apply changes at %s phase: %v", e.Phase, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ApplyChangesError) Unwrap() error {
+	return e.Err
+}
+
 func excludeRecords(records []dal.Record, excludeKeys []*dal.Key) (result []dal.Record) {
 	if len(excludeKeys) == 0 {
 		return records
 	}
+	excluded := make(map[string]struct{}, len(excludeKeys))
+	for _, key := range excludeKeys {
+		excluded[key.Canonical()] = struct{}{}
+	}
 	result = make([]dal.Record, 0, len(records))
-external:
 	for _, record := range records {
-		for _, excludeKey := range excludeKeys {
-			if record.Key() == excludeKey {
-				continue external
+		if _, ok := excluded[record.Key().Canonical()]; !ok {
+			result = append(result, record)
+		}
+	}
+	return
+}
+
+// keyUpdates is a merged list of updates of a single record
+type keyUpdates struct {
+	key     *dal.Key
+	updates []dal.Update
+	paths   []string // dot separated field paths of updates
+}
+
+// updateFieldPath returns a dot separated path of a field to be updated
+func updateFieldPath(u dal.Update) string {
+	if u.Field != "" {
+		return u.Field
+	}
+	return strings.Join(u.FieldPath, ".")
+}
+
+// add merges an update, identical updates are ignored and conflicting writes to the same field are an error
+func (v *keyUpdates) add(u dal.Update) error {
+	if err := u.Validate(); err != nil {
+		return fmt.Errorf("invalid update of record %v: %w", v.key, err)
+	}
+	path := updateFieldPath(u)
+	for i, p := range v.paths {
+		switch {
+		case p == path:
+			if reflect.DeepEqual(v.updates[i].Value, u.Value) {
+				return nil
 			}
+			return fmt.Errorf("conflicting updates of field %s of record %v: %v & %v", path, v.key, v.updates[i].Value, u.Value)
+		case strings.HasPrefix(path, p+"."), strings.HasPrefix(p, path+"."):
+			return fmt.Errorf("conflicting updates of fields %s & %s of record %v", p, path, v.key)
+		}
+	}
+	v.updates = append(v.updates, u)
+	v.paths = append(v.paths, path)
+	return nil
+}
+
+// mergeUpdates groups queued updates by keys of records and merges them
+func mergeUpdates(recordsToUpdate []*Updates) (merged []*keyUpdates, err error) {
+	byKey := make(map[string]*keyUpdates, len(recordsToUpdate))
+	for i, recordUpdates := range recordsToUpdate {
+		if recordUpdates == nil || recordUpdates.Record == nil || recordUpdates.Record.Key() == nil {
+			return nil, fmt.Errorf("RecordsToUpdate[%d] has no record key", i)
+		}
+		key := recordUpdates.Record.Key()
+		canonical := key.Canonical()
+		ku := byKey[canonical]
+		if ku == nil {
+			ku = &keyUpdates{key: key}
+			byKey[canonical] = ku
+			merged = append(merged, ku)
+		}
+		for _, u := range recordUpdates.Updates {
+			if err = ku.add(u); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return merged, nil
+}
+
+// groupByUpdates groups keys with identical lists of updates, so they can be updated by a single UpdateMulti call
+func groupByUpdates(merged []*keyUpdates) (groups [][]*keyUpdates) {
+	for _, ku := range merged {
+		if len(ku.updates) == 0 {
+			continue
+		}
+		found := false
+		for i, group := range groups {
+			if reflect.DeepEqual(group[0].updates, ku.updates) {
+				groups[i] = append(group, ku)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []*keyUpdates{ku})
 		}
-		result = append(result, record)
 	}
 	return
 }
 
+// validateChanges checks queued changes do not conflict with each other
+func validateChanges(inserts []dal.Record, updates []*keyUpdates, deletes []*dal.Key) error {
+	deleted := make(map[string]struct{}, len(deletes))
+	for i, key := range deletes {
+		if key == nil {
+			return fmt.Errorf("RecordsToDelete[%d] is nil", i)
+		}
+		deleted[key.Canonical()] = struct{}{}
+	}
+	for _, record := range inserts {
+		if _, ok := deleted[record.Key().Canonical()]; ok {
+			return fmt.Errorf("record %v is queued both for insert & delete", record.Key())
+		}
+	}
+	for _, ku := range updates {
+		if _, ok := deleted[ku.key.Canonical()]; ok {
+			return fmt.Errorf("record %v is queued both for update & delete", ku.key)
+		}
+	}
+	return nil
+}
+
+// ApplyChanges writes queued changes within a transaction: inserts, then updates & then deletes.
+// Records with keys from excludeKeys are not inserted.
+// Updates queued for the same record are merged, records with identical updates are updated with UpdateMulti.
+// All changes are validated before anything is written to the transaction.
+// Returns *ApplyChangesError that reports which phase failed. Queued changes are reset on success.
 func (v *WithRecordChanges) ApplyChanges(ctx context.Context, tx dal.ReadwriteTransaction, excludeKeys ...*dal.Key) (err error) {
+	inserts := excludeRecords(v.recordsToInsert, excludeKeys)
+	var updates []*keyUpdates
+	if updates, err = mergeUpdates(v.RecordsToUpdate); err != nil {
+		return &ApplyChangesError{Phase: PhaseValidate, Err: err}
+	}
+	if err = validateChanges(inserts, updates, v.RecordsToDelete); err != nil {
+		return &ApplyChangesError{Phase: PhaseValidate, Err: err}
+	}
 
-	if records := excludeRecords(v.recordsToInsert, excludeKeys); len(records) > 0 {
-		if err = tx.InsertMulti(ctx, records); err != nil {
-			err = fmt.Errorf("failed to insert records: %w", err)
-			return
+	if len(inserts) > 0 {
+		if err = tx.InsertMulti(ctx, inserts); err != nil {
+			return &ApplyChangesError{Phase: PhaseInsert, Err: fmt.Errorf("failed to insert %d records: %w", len(inserts), err)}
 		}
 	}
-	if len(v.RecordsToUpdate) > 0 {
-		for _, record2update := range v.RecordsToUpdate {
-			key := record2update.Record.Key()
-			if err = tx.Update(ctx, key, record2update.Updates); err != nil {
-				return fmt.Errorf("failed to update record %s: %w", key, err)
+	for _, group := range groupByUpdates(updates) {
+		if len(group) == 1 {
+			err = tx.Update(ctx, group[0].key, group[0].updates)
+		} else {
+			keys := make([]*dal.Key, len(group))
+			for i, ku := range group {
+				keys[i] = ku.key
 			}
+			err = tx.UpdateMulti(ctx, keys, group[0].updates)
+		}
+		if err != nil {
+			return &ApplyChangesError{Phase: PhaseUpdate, Err: fmt.Errorf("failed to update %d records: %w", len(group), err)}
 		}
 	}
 	if len(v.RecordsToDelete) > 0 {
 		if err = tx.DeleteMulti(ctx, v.RecordsToDelete); err != nil {
-			err = fmt.Errorf("failed to delete records: %w", err)
-			return
+			return &ApplyChangesError{Phase: PhaseDelete, Err: fmt.Errorf("failed to delete records: %w", err)}
 		}
 	}
 	v.recordsToInsert = nil
+	v.insertKeys = nil
 	v.RecordsToUpdate = nil
 	v.RecordsToDelete = nil
 	return
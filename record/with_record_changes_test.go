@@ -2,6 +2,8 @@ package record
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/dal-go/dalgo/dal"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -79,6 +81,17 @@ func TestWithRecordChanges_QueueForInsert(t *testing.T) {
 	}
 }
 
+func TestWithRecordChanges_QueueForInsert_duplicate(t *testing.T) {
+	newRecord := func() dal.Record {
+		key := dal.NewKeyWithFields("memberships", dal.FieldVal{Name: "user", Value: "u1"})
+		return dal.NewRecordWithData(key, map[string]any{}).SetError(nil)
+	}
+	v := &WithRecordChanges{recordsToInsert: []dal.Record{newRecord()}}
+	assert.PanicsWithValue(t, "record with key=memberships/%5B{user u1}%5D is already queued for insert", func() {
+		v.QueueForInsert(newRecord())
+	})
+}
+
 func TestWithRecordChanges_RecordsToInsert(t *testing.T) {
 	type fields struct {
 		recordsToInsert []dal.Record
@@ -107,3 +120,151 @@ func TestWithRecordChanges_RecordsToInsert(t *testing.T) {
 		})
 	}
 }
+
+// recordingTx is a transaction that records calls of write methods
+type recordingTx struct {
+	dal.ReadwriteTransaction
+	calls []string
+	err   error // returned by DeleteMulti
+}
+
+func (tx *recordingTx) InsertMulti(_ context.Context, records []dal.Record, _ ...dal.InsertOption) error {
+	tx.calls = append(tx.calls, fmt.Sprintf("InsertMulti:%d", len(records)))
+	return nil
+}
+
+func (tx *recordingTx) Update(_ context.Context, key *dal.Key, updates []dal.Update, _ ...dal.Precondition) error {
+	tx.calls = append(tx.calls, fmt.Sprintf("Update:%v:%d", key, len(updates)))
+	return nil
+}
+
+func (tx *recordingTx) UpdateMulti(_ context.Context, keys []*dal.Key, updates []dal.Update, _ ...dal.Precondition) error {
+	tx.calls = append(tx.calls, fmt.Sprintf("UpdateMulti:%v:%d", keys, len(updates)))
+	return nil
+}
+
+func (tx *recordingTx) DeleteMulti(_ context.Context, keys []*dal.Key) error {
+	tx.calls = append(tx.calls, fmt.Sprintf("DeleteMulti:%d", len(keys)))
+	return tx.err
+}
+
+func TestWithRecordChanges_ApplyChanges_mergesUpdates(t *testing.T) {
+	ctx := context.Background()
+	newRecord := func(id string) dal.Record {
+		return dal.NewRecordWithData(dal.NewKeyWithID("c1", id), map[string]any{}).SetError(nil)
+	}
+	v := &WithRecordChanges{}
+	v.QueueForInsert(newRecord("i1"), newRecord("i2"))
+	v.RecordsToUpdate = []*Updates{
+		{Record: newRecord("u1"), Updates: []dal.Update{{Field: "a", Value: 1}}},
+		{Record: newRecord("u2"), Updates: []dal.Update{{Field: "a", Value: 1}, {Field: "b.c", Value: 2}}},
+		{Record: newRecord("u1"), Updates: []dal.Update{{Field: "b.c", Value: 2}, {Field: "a", Value: 1}}},
+		{Record: newRecord("u3"), Updates: []dal.Update{{Field: "x", Value: 3}}},
+	}
+	v.RecordsToDelete = []*dal.Key{dal.NewKeyWithID("c1", "d1")}
+	tx := &recordingTx{}
+	err := v.ApplyChanges(ctx, tx, dal.NewKeyWithID("c1", "i2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"InsertMulti:1",
+		"UpdateMulti:[c1/u1 c1/u2]:2",
+		"Update:c1/u3:1",
+		"DeleteMulti:1",
+	}, tx.calls)
+	assert.Nil(t, v.RecordsToInsert())
+	assert.Nil(t, v.RecordsToUpdate)
+}
+
+func TestWithRecordChanges_ApplyChanges_errors(t *testing.T) {
+	ctx := context.Background()
+	newRecord := func(id string) dal.Record {
+		return dal.NewRecordWithData(dal.NewKeyWithID("c1", id), map[string]any{}).SetError(nil)
+	}
+	for _, tt := range []struct {
+		name    string
+		changes func() *WithRecordChanges
+		txErr   error
+		phase   ApplyChangesPhase
+		errText string
+	}{
+		{
+			name: "conflicting_values",
+			changes: func() *WithRecordChanges {
+				return &WithRecordChanges{RecordsToUpdate: []*Updates{
+					{Record: newRecord("u1"), Updates: []dal.Update{{Field: "a", Value: 1}}},
+					{Record: newRecord("u1"), Updates: []dal.Update{{FieldPath: dal.FieldPath{"a"}, Value: 2}}},
+				}}
+			},
+			phase:   PhaseValidate,
+			errText: "conflicting updates of field a of record c1/u1: 1 & 2",
+		},
+		{
+			name: "conflicting_paths",
+			changes: func() *WithRecordChanges {
+				return &WithRecordChanges{RecordsToUpdate: []*Updates{
+					{Record: newRecord("u1"), Updates: []dal.Update{{Field: "a", Value: 1}, {Field: "a.b", Value: 2}}},
+				}}
+			},
+			phase:   PhaseValidate,
+			errText: "conflicting updates of fields a & a.b of record c1/u1",
+		},
+		{
+			name: "invalid_update",
+			changes: func() *WithRecordChanges {
+				return &WithRecordChanges{RecordsToUpdate: []*Updates{{Record: newRecord("u1"), Updates: []dal.Update{{}}}}}
+			},
+			phase:   PhaseValidate,
+			errText: "invalid update of record c1/u1",
+		},
+		{
+			name: "insert_and_delete",
+			changes: func() *WithRecordChanges {
+				v := &WithRecordChanges{RecordsToDelete: []*dal.Key{dal.NewKeyWithID("c1", "r1")}}
+				v.QueueForInsert(newRecord("r1"))
+				return v
+			},
+			phase:   PhaseValidate,
+			errText: "record c1/r1 is queued both for insert & delete",
+		},
+		{
+			name: "update_and_delete",
+			changes: func() *WithRecordChanges {
+				return &WithRecordChanges{
+					RecordsToUpdate: []*Updates{{Record: newRecord("r1"), Updates: []dal.Update{{Field: "a", Value: 1}}}},
+					RecordsToDelete: []*dal.Key{dal.NewKeyWithID("c1", "r1")},
+				}
+			},
+			phase:   PhaseValidate,
+			errText: "record c1/r1 is queued both for update & delete",
+		},
+		{
+			name: "delete_failed",
+			changes: func() *WithRecordChanges {
+				return &WithRecordChanges{RecordsToDelete: []*dal.Key{dal.NewKeyWithID("c1", "r1")}}
+			},
+			txErr:   errors.New("tx failed"),
+			phase:   PhaseDelete,
+			errText: "tx failed",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &recordingTx{err: tt.txErr}
+			err := tt.changes().ApplyChanges(ctx, tx)
+			var applyErr *ApplyChangesError
+			assert.True(t, errors.As(err, &applyErr))
+			assert.Equal(t, tt.phase, applyErr.Phase)
+			assert.ErrorContains(t, err, tt.errText)
+			if tt.phase == PhaseValidate {
+				assert.Empty(t, tx.calls)
+			}
+		})
+	}
+}
+
+func Test_excludeRecords(t *testing.T) {
+	r1 := dal.NewRecord(dal.NewKeyWithID("c1", "r1"))
+	r2 := dal.NewRecord(dal.NewKeyWithID("c1", "r2"))
+	records := []dal.Record{r1, r2}
+	assert.Equal(t, records, excludeRecords(records, nil))
+	assert.Equal(t, []dal.Record{r2}, excludeRecords(records, []*dal.Key{dal.NewKeyWithID("c1", "r1")}))
+}